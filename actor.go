@@ -0,0 +1,82 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// maxFieldManagerLength is the maximum length of a field manager, as enforced
+// by the Kubernetes API.
+const maxFieldManagerLength = 128
+
+// fieldManager returns the field manager for changes made with the given
+// context, which is the actor carried by the context, if any. An empty field
+// manager causes the client default to be used.
+func fieldManager(ctx context.Context) string {
+	manager := ActorFrom(ctx)
+	if len(manager) > maxFieldManagerLength {
+		manager = manager[:maxFieldManagerLength]
+	}
+	return manager
+}
+
+// createOptions returns the options used when creating a backing object with
+// the given context.
+func createOptions(ctx context.Context) metav1.CreateOptions {
+	return metav1.CreateOptions{FieldManager: fieldManager(ctx)}
+}
+
+// updateOptions returns the options used when updating a backing object with
+// the given context.
+func updateOptions(ctx context.Context) metav1.UpdateOptions {
+	return metav1.UpdateOptions{FieldManager: fieldManager(ctx)}
+}
+
+// patchOptions returns the options used when patching a backing object with
+// the given context.
+func patchOptions(ctx context.Context) metav1.PatchOptions {
+	return metav1.PatchOptions{FieldManager: fieldManager(ctx)}
+}
+
+// impersonatedConfig returns a copy of the given config which impersonates the
+// actor carried by the context of each request, if configured to do so.
+func impersonatedConfig(config *rest.Config, o options) *rest.Config {
+	if !o.impersonate {
+		return config
+	}
+
+	config = rest.CopyConfig(config)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return impersonatingRoundTripper{rt: rt}
+	})
+	return config
+}
+
+// impersonatingRoundTripper is an http.RoundTripper that impersonates the
+// actor carried by the context of each request.
+type impersonatingRoundTripper struct {
+	rt http.RoundTripper
+}
+
+// RoundTrip sends the given request, impersonating the actor carried by its
+// context. Requests without an actor are sent as-is.
+func (r impersonatingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	actor := ActorFrom(req.Context())
+	if actor == "" {
+		return r.rt.RoundTrip(req)
+	}
+
+	// Requests must not be modified by a round tripper, so the header is set
+	// on a copy.
+	req = req.Clone(req.Context())
+	req.Header.Set(transport.ImpersonateUserHeader, actor)
+	return r.rt.RoundTrip(req)
+}
@@ -0,0 +1,112 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// managedByLabel is the label applied to every ConfigMap and Secret that is
+// created on-demand by a Store.
+const managedByLabel = "app.kubernetes.io/managed-by"
+
+// managedLabels returns the labels applied to every ConfigMap and Secret that
+// is created on-demand by a Store.
+func managedLabels() map[string]string {
+	return map[string]string{managedByLabel: annotationPrefix}
+}
+
+// ManagedObject describes a ConfigMap or Secret that backs a Store.
+type ManagedObject struct {
+	// Kind is either "ConfigMap" or "Secret".
+	Kind string `json:"kind"`
+
+	// Namespace is the namespace of the object.
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the object.
+	Name string `json:"name"`
+
+	// Keys is the number of keys stored in the object.
+	Keys int `json:"keys"`
+
+	// Size is the combined size of all keys and values stored in the object,
+	// in bytes.
+	Size int `json:"size"`
+}
+
+// ListManagedObjects returns every ConfigMap and Secret in the given namespace
+// that was created on-demand by a Store, as identified by the managed-by label,
+// along with the number of keys and size of each. An empty namespace lists
+// objects across all namespaces.
+//
+// This function is intended to be used when running inside of a pod, as it
+// depends on the presence of a service account in order to interact with the
+// Kubernetes API.
+func ListManagedObjects(ctx context.Context, namespace string) ([]ManagedObject, error) {
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a set of Kubernetes clients.
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return listManagedObjects(ctx, clientSet, namespace)
+}
+
+// listManagedObjects returns every ConfigMap and Secret in the given namespace
+// with the managed-by label, using the given clients.
+func listManagedObjects(ctx context.Context, clientSet kubernetes.Interface, namespace string) ([]ManagedObject, error) {
+	options := metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(metav1.SetAsLabelSelector(managedLabels())),
+	}
+
+	var objects []ManagedObject
+
+	configMaps, err := clientSet.CoreV1().ConfigMaps(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, configMap := range configMaps.Items {
+		object := ManagedObject{
+			Kind:      "ConfigMap",
+			Namespace: configMap.Namespace,
+			Name:      configMap.Name,
+			Keys:      len(configMap.Data),
+		}
+		for key, value := range configMap.Data {
+			object.Size += len(key) + len(value)
+		}
+		objects = append(objects, object)
+	}
+
+	secrets, err := clientSet.CoreV1().Secrets(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets.Items {
+		object := ManagedObject{
+			Kind:      "Secret",
+			Namespace: secret.Namespace,
+			Name:      secret.Name,
+			Keys:      len(secret.Data),
+		}
+		for key, value := range secret.Data {
+			object.Size += len(key) + len(value)
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,194 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AdmissionPolicy describes the rules enforced by an admission handler on the
+// ConfigMaps and Secrets that back a Store.
+type AdmissionPolicy struct {
+	// KeyPattern is a pattern that every key must match. A nil pattern
+	// permits any key.
+	KeyPattern *regexp.Regexp
+
+	// MaxValueBytes is the maximum size of any single value. A value of zero
+	// disables the limit.
+	MaxValueBytes int
+
+	// MaxTotalBytes is the maximum combined size of all keys and values. A
+	// value of zero disables the limit.
+	MaxTotalBytes int
+
+	// Validate is called with every key and value, such as for checking the
+	// value against a schema, and rejects the change if it returns an error.
+	// Values are always required to be valid JSON, regardless of Validate.
+	Validate func(key string, value json.RawMessage) error
+
+	// PreventDeletion rejects the deletion of objects, which would otherwise
+	// discard every key at once. The webhook must also be registered for
+	// DELETE operations for this to take effect.
+	PreventDeletion bool
+}
+
+// NewAdmissionHandler returns an http.Handler that serves a validating
+// admission webhook, which rejects changes to ConfigMaps and Secrets that were
+// created by a Store unless they follow the given policy. This guards against
+// out-of-band edits, such as those made using kubectl, corrupting the state
+// of an application.
+//
+// Only objects carrying the app.kubernetes.io/managed-by=kubestore label, or
+// that carried it prior to being updated or deleted, are validated, and all
+// other objects are allowed. The webhook should be
+// registered with an objectSelector matching the same label, so that the API
+// server only calls it for such objects. The handler must be served over TLS.
+func NewAdmissionHandler(policy AdmissionPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review admissionv1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+			http.Error(w, "malformed admission review", http.StatusBadRequest)
+			return
+		}
+
+		response := &admissionv1.AdmissionResponse{
+			UID:     review.Request.UID,
+			Allowed: true,
+		}
+		if err := policy.admit(review.Request); err != nil {
+			response.Allowed = false
+			response.Result = &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: err.Error(),
+				Reason:  metav1.StatusReasonInvalid,
+				Code:    http.StatusUnprocessableEntity,
+			}
+		}
+
+		review.Request = nil
+		review.Response = response
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(review)
+	})
+}
+
+// admit returns an error if the object in the given admission request does
+// not follow the policy.
+func (p AdmissionPolicy) admit(request *admissionv1.AdmissionRequest) error {
+	if request.Kind.Kind != "ConfigMap" && request.Kind.Kind != "Secret" {
+		return nil
+	}
+
+	data, labels, err := objectData(request.Kind.Kind, request.Object.Raw)
+	if err != nil {
+		return err
+	}
+
+	// An object that was managed before an update or deletion is still held
+	// to the policy, so that the label can not be removed in order to bypass
+	// it.
+	managed := labels[managedByLabel] == annotationPrefix
+	if request.Operation == admissionv1.Update || request.Operation == admissionv1.Delete {
+		_, oldLabels, err := objectData(request.Kind.Kind, request.OldObject.Raw)
+		if err != nil {
+			return err
+		}
+		managed = managed || oldLabels[managedByLabel] == annotationPrefix
+	}
+	if !managed {
+		return nil
+	}
+
+	if request.Operation == admissionv1.Delete {
+		if p.PreventDeletion {
+			return fmt.Errorf("%s %s is managed by kubestore and can not be deleted", request.Kind.Kind, request.Name)
+		}
+		return nil
+	}
+
+	return p.validate(data)
+}
+
+// objectData decodes the given ConfigMap or Secret, and returns its keys and
+// values along with its labels. An empty object has no keys or labels.
+func objectData(kind string, raw []byte) (map[string][]byte, map[string]string, error) {
+	if len(raw) == 0 {
+		return nil, nil, nil
+	}
+
+	switch kind {
+	case "ConfigMap":
+		var configMap apiv1.ConfigMap
+		if err := json.Unmarshal(raw, &configMap); err != nil {
+			return nil, nil, err
+		}
+		data := make(map[string][]byte, len(configMap.Data))
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+		return data, configMap.Labels, nil
+	default:
+		var secret apiv1.Secret
+		if err := json.Unmarshal(raw, &secret); err != nil {
+			return nil, nil, err
+		}
+		data := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+		for key, value := range secret.Data {
+			data[key] = value
+		}
+		for key, value := range secret.StringData {
+			data[key] = []byte(value)
+		}
+		return data, secret.Labels, nil
+	}
+}
+
+// validate returns an error if the given keys and values do not follow the
+// policy.
+func (p AdmissionPolicy) validate(data map[string][]byte) error {
+	// Validate keys in a stable order, so that the same error is reported for
+	// the same object.
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var total int
+	for _, key := range keys {
+		value := data[key]
+		total += len(key) + len(value)
+
+		if p.KeyPattern != nil && !p.KeyPattern.MatchString(key) {
+			return fmt.Errorf("key %s does not match the pattern %s", key, p.KeyPattern)
+		}
+		if p.MaxValueBytes > 0 && len(value) > p.MaxValueBytes {
+			return fmt.Errorf("%w: key %s has a value of %d bytes, exceeding the limit of %d bytes", ErrorValueTooLarge, key, len(value), p.MaxValueBytes)
+		}
+		if !json.Valid(value) {
+			return fmt.Errorf("key %s does not hold a valid JSON value", key)
+		}
+		if p.Validate != nil {
+			if err := p.Validate(key, value); err != nil {
+				return fmt.Errorf("key %s is invalid: %w", key, err)
+			}
+		}
+	}
+
+	if p.MaxTotalBytes > 0 && total > p.MaxTotalBytes {
+		return fmt.Errorf("%w: keys and values total %d bytes, exceeding the limit of %d bytes", ErrorValueTooLarge, total, p.MaxTotalBytes)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/rest"
+)
+
+// Assert that aggregateStore implements the Store interface.
+var _ Store = aggregateStore{}
+
+type aggregateStore struct {
+	stores map[string]Store
+}
+
+// NewAggregateStore returns a Store that aggregates the entries of each of the
+// given stores, which are keyed by namespace. Keys are prefixed with the
+// namespace of the Store that they belong to, as in "<namespace>/<key>".
+//
+// This Store is intended to give a consolidated view of per-tenant state, and
+// so is primarily used for reading. Calls to Store.Set and Store.Delete are
+// routed to the Store for the namespace in the key, and keys without a known
+// namespace result in the ErrorKeyNotFound sentinel error.
+func NewAggregateStore(stores map[string]Store) Store {
+	return aggregateStore{
+		stores: stores,
+	}
+}
+
+// NewAggregateConfigMapStore returns a Store that aggregates the entries of
+// the ConfigMaps with the given name in each of the given namespaces, as
+// described by NewAggregateStore.
+//
+// This Store is intended to be used when running inside of a pod, as it
+// depends on the presence of a service account in order to interact with the
+// Kubernetes API.
+func NewAggregateConfigMapStore(name string, namespaces []string, opts ...Option) (Store, error) {
+	return newAggregateStore(namespaces, func(config *rest.Config, namespace string) (Store, error) {
+		return newConfigMapStore(config, namespace, name, opts)
+	})
+}
+
+// NewAggregateSecretStore returns a Store that aggregates the entries of the
+// Secrets with the given name in each of the given namespaces, as described by
+// NewAggregateStore.
+//
+// This Store is intended to be used when running inside of a pod, as it
+// depends on the presence of a service account in order to interact with the
+// Kubernetes API.
+func NewAggregateSecretStore(name string, namespaces []string, opts ...Option) (Store, error) {
+	return newAggregateStore(namespaces, func(config *rest.Config, namespace string) (Store, error) {
+		return newSecretStore(config, namespace, name, opts)
+	})
+}
+
+// newAggregateStore returns a Store that aggregates a Store, constructed with
+// the given function, for each of the given namespaces.
+func newAggregateStore(namespaces []string, fn func(config *rest.Config, namespace string) (Store, error)) (Store, error) {
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	stores := make(map[string]Store, len(namespaces))
+	for _, namespace := range namespaces {
+		if stores[namespace], err = fn(config, namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewAggregateStore(stores), nil
+}
+
+// route splits the given key into the Store for its namespace, and the key
+// within that Store.
+func (s aggregateStore) route(key string) (Store, string, error) {
+	namespace, name := splitNamespacedKey(key)
+	store, found := s.stores[namespace]
+	if !found {
+		return nil, "", fmt.Errorf("%w: unknown namespace in key %s", ErrorKeyNotFound, key)
+	}
+	return store, name, nil
+}
+
+// splitNamespacedKey splits the given "<namespace>/<key>" into its parts.
+func splitNamespacedKey(key string) (string, string) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return "", key
+	}
+	return parts[0], parts[1]
+}
+
+// Get reads the named entry from the Store for the namespace in the key.
+func (s aggregateStore) Get(ctx context.Context, key string, value interface{}) error {
+	store, name, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return store.Get(ctx, name, value)
+}
+
+// Set writes the named entry and value into the Store for the namespace in
+// the key.
+func (s aggregateStore) Set(ctx context.Context, key string, value interface{}) error {
+	store, name, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return store.Set(ctx, name, value)
+}
+
+// List returns a list of all keys in every Store, each prefixed with the
+// namespace of its Store.
+func (s aggregateStore) List(ctx context.Context) ([]string, error) {
+	namespaces := make([]string, 0, len(s.stores))
+	for namespace := range s.stores {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var keys []string
+	for _, namespace := range namespaces {
+		names, err := s.stores[namespace].List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			keys = append(keys, namespace+"/"+name)
+		}
+	}
+
+	return keys, nil
+}
+
+// Delete removes the named entry from the Store for the namespace in the key.
+func (s aggregateStore) Delete(ctx context.Context, key string) error {
+	store, name, err := s.route(key)
+	if err != nil {
+		return err
+	}
+	return store.Delete(ctx, name)
+}
+
+// Describe returns a description of the aggregate Store.
+func (s aggregateStore) Describe() Description {
+	namespaces := make([]string, 0, len(s.stores))
+	for namespace := range s.stores {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var name string
+	for _, namespace := range namespaces {
+		if name = Describe(s.stores[namespace]).Name; name != "" {
+			break
+		}
+	}
+
+	return Description{
+		Backend: "aggregate",
+		Name:    name,
+		Options: map[string]string{
+			"namespaces": strings.Join(namespaces, ","),
+		},
+	}
+}
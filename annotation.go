@@ -8,8 +8,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -19,20 +23,36 @@ import (
 
 const annotationPrefix = "kubestore"
 
+// maxAnnotationSize is the maximum combined size of all annotation names and
+// values on a single resource, as enforced by the Kubernetes API.
+const maxAnnotationSize = 256 * 1024
+
 type annotationPatch struct {
 	Metadata metadataPatch `json:"metadata,omitempty"`
 }
 
 type metadataPatch struct {
-	Annotations map[string]interface{} `json:"annotations,omitempty"`
+	Annotations     map[string]interface{} `json:"annotations,omitempty"`
+	ResourceVersion string                 `json:"resourceVersion,omitempty"`
+}
+
+// jsonPatchOperation is a single RFC 6902 JSON Patch operation.
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
 }
 
 // Assert that annotationStore implements the Store interface.
 var _ Store = annotationStore{}
 
 type annotationStore struct {
-	client dynamic.ResourceInterface
-	name   string
+	client    annotationClient
+	dynclient dynamic.Interface
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+	options   options
 }
 
 // NewAnnotationStore returns a Store backed by the annotations on a resource.
@@ -40,7 +60,11 @@ type annotationStore struct {
 // This Store is intended to be used when running inside of a pod, as it
 // depends on the presence of a service account in order to interact with the
 // Kubernetes API.
-func NewAnnotationStore(group, version, resource, name string) (Store, error) {
+//
+// The combined size of all annotations on a resource is limited by the
+// Kubernetes API, and Store.Set will return the ErrorValueTooLarge sentinel
+// error if that limit would be exceeded.
+func NewAnnotationStore(group, version, resource, name string, opts ...Option) (Store, error) {
 	// Lookup the current pod's service account details.
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -53,45 +77,296 @@ func NewAnnotationStore(group, version, resource, name string) (Store, error) {
 		return nil, err
 	}
 
-	// Create a dynamic Kubernetes client.
+	gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+	return newAnnotationStore(config, gvr, namespace, name, opts)
+}
+
+// newAnnotationStore returns a Store backed by the annotations on the named
+// resource in the given namespace.
+func newAnnotationStore(config *rest.Config, gvr schema.GroupVersionResource, namespace, name string, opts []Option) (Store, error) {
+	o := newOptions(opts)
+	config = impersonatedConfig(config, o)
+
+	// We're only interested in the client for this specific resource.
+	client, err := newAnnotationClient(config, gvr, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a dynamic Kubernetes client, for use when watching the resource.
 	dynclient, err := dynamic.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	// We're only interested in the client for this specific resource.
-	gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
-	client := dynclient.Resource(gvr).Namespace(namespace)
+	return wrap(&annotationStore{
+		client:    client,
+		dynclient: dynclient,
+		gvr:       gvr,
+		namespace: namespace,
+		name:      name,
+		options:   o,
+	}, o), nil
+}
+
+// annotationName returns the name of the annotation used for the given key.
+func annotationName(key string) string {
+	return fmt.Sprintf("%s/%s", annotationPrefix, key)
+}
+
+// chunkAnnotationName returns the name of the annotation used for the given
+// chunk index of the given key.
+func chunkAnnotationName(key string, index int) string {
+	return fmt.Sprintf("%s/%s.%d", annotationPrefix, key, index)
+}
+
+// keyAnnotations returns the names of all existing annotations that hold
+// either the whole value, or a chunk of the value, for the given key.
+func keyAnnotations(annotations map[string]string, key string) []string {
+	var names []string
+	if _, found := annotations[annotationName(key)]; found {
+		names = append(names, annotationName(key))
+	}
+	for i := 0; ; i++ {
+		if _, found := annotations[chunkAnnotationName(key, i)]; !found {
+			break
+		}
+		names = append(names, chunkAnnotationName(key, i))
+	}
+	return names
+}
+
+// readAnnotation reassembles the value for the given key, whether that value
+// is stored in a single annotation or is split across multiple chunks.
+func readAnnotation(annotations map[string]string, key string) (string, bool) {
+	if data, found := annotations[annotationName(key)]; found {
+		return data, true
+	}
+
+	var builder strings.Builder
+	for i := 0; ; i++ {
+		chunk, found := annotations[chunkAnnotationName(key, i)]
+		if !found {
+			// The value was found if at least one chunk was read.
+			return builder.String(), i > 0
+		}
+		builder.WriteString(chunk)
+	}
+}
+
+// chunkKey returns the key that the given chunk annotation key belongs to.
+// Returns false if the given key does not name a chunk.
+func chunkKey(annotations map[string]string, key string) (string, bool) {
+	index := strings.LastIndex(key, ".")
+	if index < 0 {
+		return "", false
+	}
+
+	// Chunk keys are suffixed with a numeric index.
+	if _, err := strconv.Atoi(key[index+1:]); err != nil {
+		return "", false
+	}
+
+	// Chunks are always numbered starting at zero.
+	base := key[:index]
+	if _, found := annotations[chunkAnnotationName(base, 0)]; !found {
+		return "", false
+	}
+
+	return base, true
+}
+
+// annotationSize calculates the combined size of the given annotations, after
+// the given changes have been applied. Changes with a nil value represent the
+// deletion of that annotation.
+func annotationSize(annotations map[string]string, changes map[string]interface{}) int {
+	var size int
+	for name, value := range annotations {
+		if _, changed := changes[name]; !changed {
+			size += len(name) + len(value)
+		}
+	}
+	for name, value := range changes {
+		if value, ok := value.(string); ok {
+			size += len(name) + len(value)
+		}
+	}
+	return size
+}
+
+// modify reads the backing resource, and then patches it with the annotation
+// changes returned by the given function, where a change with a nil value
+// represents the deletion of that annotation. The given function is called
+// with the existing annotations, and no patch is made if it returns no changes.
+//
+// A merge patch only applies if the backing resource has not been modified
+// since it was read, and is otherwise retried against a fresh read, so that
+// changes are never computed from stale annotations, such as when removing the
+// chunks of a previous value.
+func (c annotationStore) modify(ctx context.Context, fn func(existing map[string]string) (map[string]interface{}, error)) error {
+	for {
+		// Use the Kuberneties API to get the backing resource.
+		resource, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		changes, err := fn(resource.GetAnnotations())
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+
+		// Use the Kuberneties API to patch the backing resource.
+		err = c.patch(ctx, resource, changes)
+		if isConflictError(err) {
+			// The backing resource was modified concurrently, so try again.
+			continue
+		}
+		return err
+	}
+}
+
+// patch applies the given annotation changes to the given backing resource,
+// where a change with a nil value represents the deletion of that annotation.
+// The given resource is the one that was read immediately prior, and the patch
+// is rejected if it has since been modified.
+func (c annotationStore) patch(ctx context.Context, resource metav1.Object, changes map[string]interface{}) error {
+	if c.options.jsonPatch {
+		return c.jsonPatch(ctx, resource.GetAnnotations(), changes)
+	}
+
+	// Construct a merge patch for the annotation changes, that only applies
+	// if the backing resource has not been modified since it was read.
+	patch := annotationPatch{
+		Metadata: metadataPatch{
+			Annotations:     changes,
+			ResourceVersion: resource.GetResourceVersion(),
+		},
+	}
+
+	// Convert the patch to JSON.
+	payload, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
+	return err
+}
 
-	return &annotationStore{
-		client: client,
-		name:   name,
-	}, nil
+// jsonPatch applies the given annotation changes to the backing resource
+// using a JSON Patch. Every annotation that is modified or removed is first
+// tested against its previously read value, or tested to be absent if it did
+// not previously exist, so that the patch is rejected if that annotation has
+// since been changed by another writer.
+func (c annotationStore) jsonPatch(ctx context.Context, existing map[string]string, changes map[string]interface{}) error {
+	// Apply the changes in a stable order.
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var operations []jsonPatchOperation
+
+	// The annotations field must exist before individual annotations can be
+	// added to it. Since adding it replaces any existing value, it is first
+	// tested to still be absent, so that annotations added concurrently by
+	// another writer are not wiped out.
+	if existing == nil {
+		operations = append(operations,
+			jsonPatchOperation{Op: "test", Path: "/metadata/annotations", Value: json.RawMessage("null")},
+			jsonPatchOperation{Op: "add", Path: "/metadata/annotations", Value: map[string]string{}},
+		)
+	}
+
+	for _, name := range names {
+		path := "/metadata/annotations/" + escapeJSONPointer(name)
+		old, found := existing[name]
+
+		// Ensure that the annotation still holds the value that was read. A
+		// test against null ensures that the annotation is still absent.
+		if found {
+			operations = append(operations, jsonPatchOperation{Op: "test", Path: path, Value: old})
+		} else {
+			operations = append(operations, jsonPatchOperation{Op: "test", Path: path, Value: json.RawMessage("null")})
+		}
+
+		switch value := changes[name]; {
+		case value != nil:
+			operations = append(operations, jsonPatchOperation{Op: "add", Path: path, Value: value})
+		case found:
+			operations = append(operations, jsonPatchOperation{Op: "remove", Path: path})
+		}
+	}
+
+	// Convert the patch to JSON.
+	payload, err := json.Marshal(operations)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.Patch(ctx, c.name, types.JSONPatchType, payload, patchOptions(ctx))
+	if err != nil {
+		// A failed test operation indicates that the annotations were
+		// modified concurrently.
+		if isPatchConflictError(err) {
+			return fmt.Errorf("%w: %v", ErrorConflict, err)
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	return nil
+}
+
+// escapeJSONPointer escapes the given name for use as a single RFC 6901 JSON
+// Pointer reference token.
+func escapeJSONPointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// isPatchConflictError returns true if the given error indicates that a JSON
+// Patch was rejected because one of its test operations failed.
+func isPatchConflictError(err error) bool {
+	if sterr, ok := err.(*errors.StatusError); ok {
+		switch sterr.ErrStatus.Code {
+		case http.StatusConflict:
+			return true
+		case http.StatusUnprocessableEntity:
+			// Validation failures, such as an invalid annotation name, are
+			// also reported as unprocessable, so only a failed test
+			// operation indicates a conflict.
+			return strings.Contains(sterr.ErrStatus.Message, "test failed")
+		}
+	}
+	return false
 }
 
 // Get reads the named annotation from the backing resource and stores the
 // contents into the given value pointer.
 //
-// If the backing resource does not exist, the ErrorKeyNotFound sentinel error
-// is returned.
+// If the backing resource does not exist, an error matching both the
+// ErrorResourceMissing and ErrorKeyNotFound sentinel errors is returned, which
+// can be distinguished using errors.Is.
 func (c annotationStore) Get(ctx context.Context, key string, value interface{}) error {
-	// Construct the full annotation.
-	annotation := fmt.Sprintf("%s/%s", annotationPrefix, key)
-
 	// Use the Kuberneties API to get the backing resource.
-	resource, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	resource, err := c.client.Get(ctx, c.name, c.options.getOptions())
 	if err != nil {
 		// If the backing resource does not exist, then the key also does not
-		// exist, so return the not found sentinel error.
+		// exist, so return an error matching both sentinel errors.
 		if isResourceMissingError(err) {
-			return ErrorKeyNotFound
+			return resourceMissingError{err: err, keyNotFound: true}
 		}
 		// Some other kind of error was encountered.
 		return err
 	}
 
-	// Lookup the desired resource annotation.
-	data, found := resource.GetAnnotations()[annotation]
+	// Lookup the desired resource annotation, or annotation chunks.
+	data, found := readAnnotation(resource.GetAnnotations(), key)
 	if !found {
 		// The desired annotation does not exist, so return the not found
 		// sentinel error.
@@ -103,34 +378,71 @@ func (c annotationStore) Get(ctx context.Context, key string, value interface{})
 }
 
 // Set writes the named entry and value into the backing resource annotations.
+//
+// If chunking is enabled, and the value is larger than the chunk size, then it
+// is split across multiple annotations.
+//
+// If the backing resource does not exist, an error matching the
+// ErrorResourceMissing sentinel error is returned.
 func (c annotationStore) Set(ctx context.Context, key string, value interface{}) error {
-	// Construct the full annotation.
-	annotation := fmt.Sprintf("%s/%s", annotationPrefix, key)
-
 	// Marshal the the given value as JSON.
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	// Construct a patch for setting the annotation value.
-	patch := annotationPatch{
-		Metadata: metadataPatch{
-			Annotations: map[string]interface{}{
-				annotation: string(data),
-			},
-		},
-	}
-
-	// Convert the patch to JSON.
-	payload, err := json.Marshal(patch)
+	err = c.modify(ctx, func(existing map[string]string) (map[string]interface{}, error) {
+		// Account for the size of the existing annotations.
+		annotations := make(map[string]interface{})
+		c.setChanges(existing, key, data, annotations)
+		return annotations, checkAnnotationSize(existing, annotations)
+	})
 	if err != nil {
+		// The backing resource is not created on-demand, so report that it
+		// does not exist.
+		if isResourceMissingError(err) {
+			return resourceMissingError{err: err}
+		}
+		// Some other kind of error was encountered.
 		return err
 	}
 
-	// Use the Kuberneties API to patch the backing resource.
-	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, metav1.PatchOptions{})
-	return err
+	return nil
+}
+
+// setChanges adds the annotation changes needed in order to set the given key
+// to the given data to the given set of changes, where a change with a nil
+// value represents the deletion of that annotation.
+func (c annotationStore) setChanges(existing map[string]string, key string, data []byte, changes map[string]interface{}) {
+	// Remove any annotations that currently hold the value for this key, as
+	// the number of chunks may have changed.
+	for _, name := range keyAnnotations(existing, key) {
+		changes[name] = nil
+	}
+
+	if c.options.chunkSize > 0 && len(data) > c.options.chunkSize {
+		// Split the value across multiple chunk annotations.
+		for i := 0; len(data) > 0; i++ {
+			size := c.options.chunkSize
+			if size > len(data) {
+				size = len(data)
+			}
+			changes[chunkAnnotationName(key, i)] = string(data[:size])
+			data = data[size:]
+		}
+	} else {
+		changes[annotationName(key)] = string(data)
+	}
+}
+
+// checkAnnotationSize ensures that the annotations resulting from applying the
+// given changes are within the API limits, as otherwise the patch would be
+// rejected.
+func checkAnnotationSize(existing map[string]string, changes map[string]interface{}) error {
+	if size := annotationSize(existing, changes); size > maxAnnotationSize {
+		return fmt.Errorf("%w: annotations would total %d bytes, exceeding the limit of %d bytes", ErrorValueTooLarge, size, maxAnnotationSize)
+	}
+	return nil
 }
 
 // List finds all matching annotations in the backing resource and returns a
@@ -140,7 +452,7 @@ func (c annotationStore) Set(ctx context.Context, key string, value interface{})
 // If the backing resource does not exist, no keys are returned.
 func (c annotationStore) List(ctx context.Context) ([]string, error) {
 	// Use the Kuberneties API to get the backing resource.
-	resource, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	resource, err := c.client.Get(ctx, c.name, c.options.getOptions())
 	if err != nil {
 		// If the backing resource does not exist, then the keys also no not
 		// exist, so return an empty (nil) slice.
@@ -151,42 +463,46 @@ func (c annotationStore) List(ctx context.Context) ([]string, error) {
 		return nil, err
 	}
 
+	return annotationKeys(resource.GetAnnotations()), nil
+}
+
+// annotationKeys returns the keys for all matching annotations.
+func annotationKeys(annotations map[string]string) []string {
 	// Build a list of all the keys.
 	var keys []string
-	for annotation := range resource.GetAnnotations() {
+	seen := make(map[string]bool)
+	for annotation := range annotations {
 		// Disregard annotation that do not match.
 		if !strings.HasPrefix(annotation, annotationPrefix+"/") {
 			continue
 		}
 		key := strings.TrimPrefix(annotation, annotationPrefix+"/")
+
+		// Chunks are reported under the key that they belong to.
+		if base, ok := chunkKey(annotations, key); ok {
+			key = base
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
 		keys = append(keys, key)
 	}
 
-	return keys, nil
+	return keys
 }
 
-// Delete removes the named annotation from the backing resource.
+// Delete removes the named annotation, or annotation chunks, from the backing
+// resource.
 func (c annotationStore) Delete(ctx context.Context, key string) error {
-	// Construct the full annotation.
-	annotation := fmt.Sprintf("%s/%s", annotationPrefix, key)
-
-	// Construct a patch for deleting the annotation.
-	patch := annotationPatch{
-		Metadata: metadataPatch{
-			Annotations: map[string]interface{}{
-				annotation: nil,
-			},
-		},
-	}
-
-	// Convert the patch to JSON.
-	payload, err := json.Marshal(patch)
-	if err != nil {
-		return err
-	}
-
-	// Use the Kuberneties API to patch the backing resource.
-	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, metav1.PatchOptions{})
+	err := c.modify(ctx, func(existing map[string]string) (map[string]interface{}, error) {
+		// Construct a patch for deleting the annotations, if any.
+		annotations := make(map[string]interface{})
+		for _, name := range keyAnnotations(existing, key) {
+			annotations[name] = nil
+		}
+		return annotations, nil
+	})
 	if err != nil {
 		// If the backing resource does not exist, then the key also does not
 		// exist, so there's nothing else to do.
@@ -0,0 +1,160 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// annotationClient is the subset of Kubernetes API operations needed in order
+// to manage the annotations on a single kind of resource.
+type annotationClient interface {
+	// Get retrieves the named resource.
+	Get(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error)
+
+	// Patch applies the given patch to the named resource.
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error)
+}
+
+// dynamicAnnotationClient is an annotationClient that can manage any kind of
+// resource by way of the dynamic client.
+type dynamicAnnotationClient struct {
+	client dynamic.ResourceInterface
+}
+
+func (c dynamicAnnotationClient) Get(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error) {
+	return c.client.Get(ctx, name, options)
+}
+
+func (c dynamicAnnotationClient) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+	return c.client.Patch(ctx, name, pt, data, options)
+}
+
+// typedAnnotationClient is an annotationClient that manages a single kind of
+// core resource by way of the typed clientset.
+type typedAnnotationClient struct {
+	get   func(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error)
+	patch func(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error)
+}
+
+func (c typedAnnotationClient) Get(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error) {
+	return c.get(ctx, name, options)
+}
+
+func (c typedAnnotationClient) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+	return c.patch(ctx, name, pt, data, options)
+}
+
+// newAnnotationClient returns an annotationClient for the given resource.
+//
+// Well known core/v1 resources are managed using the typed clientset, which
+// uses the more efficient protobuf transport and avoids unstructured
+// conversions. All other resources are managed using the dynamic client.
+func newAnnotationClient(config *rest.Config, gvr schema.GroupVersionResource, namespace string) (annotationClient, error) {
+	if gvr.Group == "" && gvr.Version == "v1" {
+		// Prefer protobuf when talking to the API, while still accepting JSON.
+		config = rest.CopyConfig(config)
+		config.ContentType = runtime.ContentTypeProtobuf
+		config.AcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+
+		// Create a set of Kubernetes clients.
+		clientSet, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		if client, found := newTypedAnnotationClient(clientSet, gvr.Resource, namespace); found {
+			return client, nil
+		}
+	}
+
+	// Create a dynamic Kubernetes client.
+	dynclient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// We're only interested in the client for this specific resource.
+	return dynamicAnnotationClient{
+		client: dynclient.Resource(gvr).Namespace(namespace),
+	}, nil
+}
+
+// newTypedAnnotationClient returns an annotationClient for the given core/v1
+// resource. Returns false if the resource is not one that has a typed client.
+func newTypedAnnotationClient(clientSet kubernetes.Interface, resource, namespace string) (annotationClient, bool) {
+	switch resource {
+	case "configmaps":
+		client := clientSet.CoreV1().ConfigMaps(namespace)
+		return typedAnnotationClient{
+			get: func(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error) {
+				return client.Get(ctx, name, options)
+			},
+			patch: func(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+				return client.Patch(ctx, name, pt, data, options)
+			},
+		}, true
+	case "persistentvolumeclaims":
+		client := clientSet.CoreV1().PersistentVolumeClaims(namespace)
+		return typedAnnotationClient{
+			get: func(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error) {
+				return client.Get(ctx, name, options)
+			},
+			patch: func(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+				return client.Patch(ctx, name, pt, data, options)
+			},
+		}, true
+	case "pods":
+		client := clientSet.CoreV1().Pods(namespace)
+		return typedAnnotationClient{
+			get: func(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error) {
+				return client.Get(ctx, name, options)
+			},
+			patch: func(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+				return client.Patch(ctx, name, pt, data, options)
+			},
+		}, true
+	case "secrets":
+		client := clientSet.CoreV1().Secrets(namespace)
+		return typedAnnotationClient{
+			get: func(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error) {
+				return client.Get(ctx, name, options)
+			},
+			patch: func(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+				return client.Patch(ctx, name, pt, data, options)
+			},
+		}, true
+	case "serviceaccounts":
+		client := clientSet.CoreV1().ServiceAccounts(namespace)
+		return typedAnnotationClient{
+			get: func(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error) {
+				return client.Get(ctx, name, options)
+			},
+			patch: func(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+				return client.Patch(ctx, name, pt, data, options)
+			},
+		}, true
+	case "services":
+		client := clientSet.CoreV1().Services(namespace)
+		return typedAnnotationClient{
+			get: func(ctx context.Context, name string, options metav1.GetOptions) (metav1.Object, error) {
+				return client.Get(ctx, name, options)
+			},
+			patch: func(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions) (metav1.Object, error) {
+				return client.Patch(ctx, name, pt, data, options)
+			},
+		}, true
+	default:
+		return nil, false
+	}
+}
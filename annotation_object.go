@@ -0,0 +1,108 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// NewAnnotationStoreForObject returns a Store backed by the annotations on
+// the given object.
+//
+// The group, version, and resource are derived from the kind of the given
+// object, and the name and namespace are taken from its metadata. Objects
+// without a namespace are assumed to reside in the current pod's namespace,
+// unless they are cluster scoped.
+//
+// This Store is intended to be used when running inside of a pod, as it
+// depends on the presence of a service account in order to interact with the
+// Kubernetes API.
+func NewAnnotationStoreForObject(obj runtime.Object, opts ...Option) (Store, error) {
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// Typed objects frequently have an empty TypeMeta, so fall back to
+	// looking up their kind from the client-go scheme.
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, err
+		}
+		gvk = gvks[0]
+	}
+
+	return newAnnotationStoreForKind(gvk, object.GetNamespace(), object.GetName(), opts)
+}
+
+// NewAnnotationStoreForReference returns a Store backed by the annotations on
+// the object referred to by the given reference.
+//
+// The group, version, and resource are derived from the API version and kind
+// of the given reference. References without a namespace are assumed to refer
+// to an object in the current pod's namespace, unless it is cluster scoped.
+//
+// This Store is intended to be used when running inside of a pod, as it
+// depends on the presence of a service account in order to interact with the
+// Kubernetes API.
+func NewAnnotationStoreForReference(ref apiv1.ObjectReference, opts ...Option) (Store, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAnnotationStoreForKind(gv.WithKind(ref.Kind), ref.Namespace, ref.Name, opts)
+}
+
+// newAnnotationStoreForKind returns a Store backed by the annotations on the
+// named object of the given kind.
+func newAnnotationStoreForKind(gvk schema.GroupVersionKind, namespace, name string, opts []Option) (Store, error) {
+	if gvk.Kind == "" {
+		return nil, fmt.Errorf("could not determine kind of object %q", name)
+	}
+
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Map the object kind to a resource using API discovery.
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case mapping.Scope.Name() == meta.RESTScopeNameRoot:
+		// Cluster scoped objects do not have a namespace.
+		namespace = ""
+	case namespace == "":
+		// Lookup the current pod's namespace.
+		namespace, err = inClusterNamespace()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newAnnotationStore(config, mapping.Resource, namespace, name, opts)
+}
@@ -0,0 +1,110 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Assert that annotationStore implements the Subscriber interface.
+var _ Subscriber = annotationStore{}
+
+// Subscribe watches the backing resource using an informer, and emits an event
+// whenever one of the matching annotations is changed.
+func (c annotationStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	// Create an informer that only watches the single backing resource.
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.dynclient, 0, c.namespace, func(options *metav1.ListOptions) {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", c.name).String()
+	})
+	informer := factory.ForResource(c.gvr).Informer()
+
+	events := make(chan Event)
+
+	// notify emits events for every key that differs between the given old
+	// and new versions of the backing resource.
+	notify := func(oldObj, newObj interface{}) {
+		for _, event := range annotationEvents(annotationsOf(oldObj), annotationsOf(newObj)) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			notify(nil, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			notify(oldObj, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			notify(obj, nil)
+		},
+	})
+
+	go func() {
+		// Run blocks until the context is done, and all event handlers have
+		// returned, after which it is safe to close the channel.
+		informer.Run(ctx.Done())
+		close(events)
+	}()
+
+	return events, nil
+}
+
+// annotationsOf returns the annotations of the given informer object.
+func annotationsOf(obj interface{}) map[string]string {
+	// Deleted objects may be wrapped if the final state was unknown.
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if obj == nil {
+		return nil
+	}
+
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return nil
+	}
+	return object.GetAnnotations()
+}
+
+// annotationEvents returns events for every key whose value differs between
+// the given old and new sets of annotations.
+func annotationEvents(oldAnnotations, newAnnotations map[string]string) []Event {
+	var events []Event
+
+	// Find all keys that were created or changed.
+	for _, key := range annotationKeys(newAnnotations) {
+		newValue, _ := readAnnotation(newAnnotations, key)
+		oldValue, found := readAnnotation(oldAnnotations, key)
+		if !found || oldValue != newValue {
+			events = append(events, Event{Type: EventSet, Key: key})
+		}
+	}
+
+	// Find all keys that were removed.
+	for _, key := range annotationKeys(oldAnnotations) {
+		if _, found := readAnnotation(newAnnotations, key); !found {
+			events = append(events, Event{Type: EventDelete, Key: key})
+		}
+	}
+
+	// Emit events in a stable order.
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+
+	return events
+}
@@ -0,0 +1,339 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Applier represents a Store that is capable of reconciling its contents
+// against a desired set of entries using a single write.
+type Applier interface {
+	// Apply sets every entry in the given desired state whose value differs
+	// from the current value, and if prune is true, removes every entry that
+	// is not in the desired state.
+	Apply(ctx context.Context, desired map[string]interface{}, prune bool) error
+}
+
+// Assert that the various stores implement the Applier interface.
+var (
+	_ Applier = annotationStore{}
+	_ Applier = configMapStore{}
+	_ Applier = secretStore{}
+)
+
+// Apply reconciles the contents of the given Store against the given desired
+// state. Every entry whose value differs from the desired value is set, and if
+// prune is true, every entry that is not in the desired state is removed.
+// Entries whose values are already as desired are left untouched.
+//
+// If the Store implements the Applier interface, then all changes are made
+// with a single write, which fails and is retried if the backing object was
+// concurrently modified. Otherwise, the changes are made using SetMany and
+// DeleteMany.
+func Apply(ctx context.Context, store Store, desired map[string]interface{}, prune bool, opts ...Option) error {
+	if applier, ok := store.(Applier); ok {
+		return applier.Apply(ctx, desired, prune)
+	}
+
+	keys, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	current, err := GetMulti(ctx, store, keys, opts...)
+	if err != nil {
+		return err
+	}
+
+	changes, err := diffDesired(current, desired)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{}, len(changes))
+	for key, data := range changes {
+		values[key] = data
+	}
+	if len(values) > 0 {
+		if err := SetMany(ctx, store, values, opts...); err != nil {
+			return err
+		}
+	}
+
+	if extraneous := extraneousKeys(keys, desired); prune && len(extraneous) > 0 {
+		return DeleteMany(ctx, store, extraneous, opts...)
+	}
+	return nil
+}
+
+// diffDesired returns the marshalled value of every entry in the given desired
+// state that differs from the given current state.
+func diffDesired(current map[string]json.RawMessage, desired map[string]interface{}) (map[string]json.RawMessage, error) {
+	changes := make(map[string]json.RawMessage)
+	for key, value := range desired {
+		// Marshal the the given value as JSON.
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing, found := current[key]; !found || !bytes.Equal(existing, data) {
+			changes[key] = data
+		}
+	}
+	return changes, nil
+}
+
+// extraneousKeys returns every one of the given keys that is not in the given
+// desired state.
+func extraneousKeys(keys []string, desired map[string]interface{}) []string {
+	var extraneous []string
+	for _, key := range keys {
+		if _, found := desired[key]; !found {
+			extraneous = append(extraneous, key)
+		}
+	}
+	return extraneous
+}
+
+// Apply reconciles the backing resource annotations against the given desired
+// state using a single patch.
+func (c annotationStore) Apply(ctx context.Context, desired map[string]interface{}, prune bool) error {
+	err := c.modify(ctx, func(existing map[string]string) (map[string]interface{}, error) {
+		keys := annotationKeys(existing)
+		current := make(map[string]json.RawMessage, len(keys))
+		for _, key := range keys {
+			value, _ := readAnnotation(existing, key)
+			current[key] = json.RawMessage(value)
+		}
+
+		changes, err := diffDesired(current, desired)
+		if err != nil {
+			return nil, err
+		}
+
+		annotations := make(map[string]interface{})
+		for key, data := range changes {
+			c.setChanges(existing, key, data, annotations)
+		}
+		if prune {
+			for _, key := range extraneousKeys(keys, desired) {
+				for _, name := range keyAnnotations(existing, key) {
+					annotations[name] = nil
+				}
+			}
+		}
+		if len(annotations) == 0 {
+			// The backing resource is already as desired.
+			return nil, nil
+		}
+		return annotations, checkAnnotationSize(existing, annotations)
+	})
+	if err != nil {
+		// The backing resource is not created on-demand, so report that it
+		// does not exist.
+		if isResourceMissingError(err) {
+			return resourceMissingError{err: err}
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	return nil
+}
+
+// Apply reconciles the backing ConfigMap against the given desired state
+// using a single write, with the resource version of the backing ConfigMap as
+// a precondition.
+//
+// If the backing ConfigMap does not exist, it is created on-demand. If the
+// backing ConfigMap is left empty, it is then deleted.
+func (c configMapStore) Apply(ctx context.Context, desired map[string]interface{}, prune bool) error {
+	for {
+		// Use the Kuberneties API to get the backing ConfigMap.
+		configMap, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+		if err != nil {
+			if !isResourceMissingError(err) {
+				// Some other kind of error was encountered.
+				return err
+			}
+			configMap = nil
+		}
+
+		var keys []string
+		current := make(map[string]json.RawMessage)
+		if configMap != nil {
+			for key, value := range configMap.Data {
+				keys = append(keys, key)
+				current[key] = json.RawMessage(value)
+			}
+		}
+
+		changes, err := diffDesired(current, desired)
+		if err != nil {
+			return err
+		}
+		var extraneous []string
+		if prune {
+			extraneous = extraneousKeys(keys, desired)
+		}
+		if len(changes) == 0 && len(extraneous) == 0 {
+			// The backing ConfigMap is already as desired.
+			return nil
+		}
+
+		switch {
+		case configMap == nil:
+			// Create the backing ConfigMap on-demand, which fails if it has
+			// been created concurrently.
+			configMap = &apiv1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        c.name,
+					Labels:      managedLabels(),
+					Annotations: nameAnnotations(c.original),
+				},
+				Data: make(map[string]string, len(changes)),
+			}
+			for key, data := range changes {
+				configMap.Data[key] = string(data)
+			}
+			configMap, err = c.client.Create(ctx, configMap, createOptions(ctx))
+		default:
+			// Update the backing ConfigMap, which fails if it has been
+			// modified concurrently.
+			configMap = configMap.DeepCopy()
+			if configMap.Data == nil {
+				configMap.Data = make(map[string]string, len(changes))
+			}
+			for key, data := range changes {
+				configMap.Data[key] = string(data)
+			}
+			for _, key := range extraneous {
+				delete(configMap.Data, key)
+			}
+			configMap, err = c.client.Update(ctx, configMap, updateOptions(ctx))
+		}
+		if isConflictError(err) {
+			// The backing ConfigMap was modified concurrently, so try again.
+			continue
+		}
+		if err != nil {
+			// Some other kind of error was encountered.
+			return err
+		}
+
+		// Is the backing ConfigMap now empty?
+		if len(configMap.Data) == 0 {
+			// Delete the backing ConfigMap in order to clean up after
+			// ourselves, but only if it has not been modified in the interim.
+			// Intentionally ignore any errors, as this is non-essential.
+			_ = c.client.Delete(ctx, c.name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &configMap.ResourceVersion},
+			})
+		}
+
+		return nil
+	}
+}
+
+// Apply reconciles the backing Secret against the given desired state using a
+// single write, with the resource version of the backing Secret as a
+// precondition.
+//
+// If the backing Secret does not exist, it is created on-demand. If the
+// backing Secret is left empty, it is then deleted.
+func (c secretStore) Apply(ctx context.Context, desired map[string]interface{}, prune bool) error {
+	for {
+		// Use the Kuberneties API to get the backing Secret.
+		secret, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+		if err != nil {
+			if !isResourceMissingError(err) {
+				// Some other kind of error was encountered.
+				return err
+			}
+			secret = nil
+		}
+
+		var keys []string
+		current := make(map[string]json.RawMessage)
+		if secret != nil {
+			for key, value := range secret.Data {
+				keys = append(keys, key)
+				current[key] = json.RawMessage(value)
+			}
+		}
+
+		changes, err := diffDesired(current, desired)
+		if err != nil {
+			return err
+		}
+		var extraneous []string
+		if prune {
+			extraneous = extraneousKeys(keys, desired)
+		}
+		if len(changes) == 0 && len(extraneous) == 0 {
+			// The backing Secret is already as desired.
+			return nil
+		}
+
+		switch {
+		case secret == nil:
+			// Create the backing Secret on-demand, which fails if it has been
+			// created concurrently.
+			secret = &apiv1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        c.name,
+					Labels:      managedLabels(),
+					Annotations: nameAnnotations(c.original),
+				},
+				Data: make(map[string][]byte, len(changes)),
+			}
+			for key, data := range changes {
+				secret.Data[key] = data
+			}
+			secret, err = c.client.Create(ctx, secret, createOptions(ctx))
+		default:
+			// Update the backing Secret, which fails if it has been modified
+			// concurrently.
+			secret = secret.DeepCopy()
+			if secret.Data == nil {
+				secret.Data = make(map[string][]byte, len(changes))
+			}
+			for key, data := range changes {
+				secret.Data[key] = data
+			}
+			for _, key := range extraneous {
+				delete(secret.Data, key)
+			}
+			secret, err = c.client.Update(ctx, secret, updateOptions(ctx))
+		}
+		if isConflictError(err) {
+			// The backing Secret was modified concurrently, so try again.
+			continue
+		}
+		if err != nil {
+			// Some other kind of error was encountered.
+			return err
+		}
+
+		// Is the backing Secret now empty?
+		if len(secret.Data) == 0 {
+			// Delete the backing Secret in order to clean up after ourselves,
+			// but only if it has not been modified in the interim.
+			// Intentionally ignore any errors, as this is non-essential.
+			_ = c.client.Delete(ctx, c.name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &secret.ResourceVersion},
+			})
+		}
+
+		return nil
+	}
+}
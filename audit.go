@@ -0,0 +1,250 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// actorKey is the context key under which the actor is stored.
+type actorKey struct{}
+
+// WithActor returns a copy of the given context which carries the name of the
+// actor, such as the user or service, on whose behalf operations are made.
+//
+// The actor is recorded in audit records, and used as the field manager for
+// changes made to a backing object, so that each change is attributed to the
+// originating request rather than to a shared service account. When using the
+// WithImpersonation option, requests are also made as the actor.
+func WithActor(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, actorKey{}, name)
+}
+
+// ActorFrom returns the name of the actor carried by the given context, or an
+// empty string if there is none.
+func ActorFrom(ctx context.Context) string {
+	name, _ := ctx.Value(actorKey{}).(string)
+	return name
+}
+
+// AuditRecord describes a single change that was made to a Store.
+type AuditRecord struct {
+	// Time is when the change was made.
+	Time time.Time `json:"time"`
+
+	// Type is the kind of change that was made.
+	Type EventType `json:"type"`
+
+	// Key is the name of the key that was changed.
+	Key string `json:"key"`
+
+	// Actor is the name of the actor that made the change, as carried by the
+	// context given to the operation with WithActor.
+	Actor string `json:"actor,omitempty"`
+
+	// Store describes the Store that was changed.
+	Store Description `json:"store"`
+
+	// ResourceVersion is the resource version of the backing object, as
+	// observed after the change was made, for Stores backed by a Kubernetes
+	// resource.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// resourceVersioner represents a Store that is capable of reporting the
+// resource version of its backing object.
+type resourceVersioner interface {
+	resourceVersion(ctx context.Context) (string, error)
+}
+
+// resourceVersionOf returns the resource version of the object backing the
+// given Store.
+//
+// If the Store does not implement the resourceVersioner interface, an error
+// matching the ErrorNotSupported sentinel error is returned.
+func resourceVersionOf(ctx context.Context, store Store) (string, error) {
+	if versioner, ok := store.(resourceVersioner); ok {
+		return versioner.resourceVersion(ctx)
+	}
+	return "", fmt.Errorf("%w: %s does not have a resource version", ErrorNotSupported, Describe(store).Backend)
+}
+
+// Assert that auditStore implements the Store interface.
+var _ Store = auditStore{}
+
+type auditStore struct {
+	store    Store
+	base     Store
+	endpoint string
+	client   *http.Client
+}
+
+// Get reads the named entry from the wrapped Store.
+func (s auditStore) Get(ctx context.Context, key string, value interface{}) error {
+	return s.store.Get(ctx, key, value)
+}
+
+// Set writes the named entry and value into the wrapped Store, and then posts
+// an audit record of the change.
+func (s auditStore) Set(ctx context.Context, key string, value interface{}) error {
+	if err := s.store.Set(ctx, key, value); err != nil {
+		return err
+	}
+	return s.audit(ctx, EventSet, key)
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s auditStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store, and then posts an
+// audit record of the change.
+func (s auditStore) Delete(ctx context.Context, key string) error {
+	if err := s.store.Delete(ctx, key); err != nil {
+		return err
+	}
+	return s.audit(ctx, EventDelete, key)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s auditStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s auditStore) resourceVersion(ctx context.Context) (string, error) {
+	return resourceVersionOf(ctx, s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s auditStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// Dump writes the raw contents of the wrapped Store.
+func (s auditStore) Dump(ctx context.Context, w io.Writer, opts ...Option) error {
+	return Dump(ctx, s.store, w, opts...)
+}
+
+// Update atomically modifies the named entry in the wrapped Store, and then
+// posts an audit record of the change, if any.
+func (s auditStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	var changed, deleted bool
+	err := Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		result, err := fn(current)
+		changed = err == nil && !unchanged(current, result)
+		deleted = result == nil
+		return result, err
+	})
+	if err != nil || !changed {
+		return err
+	}
+
+	if deleted {
+		return s.audit(ctx, EventDelete, key)
+	}
+	return s.audit(ctx, EventSet, key)
+}
+
+// audit posts a record of the given change to the configured endpoint. The
+// change has already been made, so an error only indicates that the record
+// could not be delivered.
+func (s auditStore) audit(ctx context.Context, typ EventType, key string) error {
+	record := AuditRecord{
+		Time:  time.Now().UTC(),
+		Type:  typ,
+		Key:   key,
+		Actor: ActorFrom(ctx),
+		Store: Describe(s.store),
+	}
+
+	if versioner, ok := s.base.(resourceVersioner); ok {
+		// Intentionally ignore any errors, as the backing object may have
+		// been deleted along with its last key.
+		record.ResourceVersion, _ = versioner.resourceVersion(ctx)
+	}
+
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	defer response.Body.Close()
+
+	// Drain the body, so that the connection can be reused.
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("audit: endpoint responded with status %s", response.Status)
+	}
+
+	return nil
+}
+
+// resourceVersion returns the resource version of the backing resource.
+func (c annotationStore) resourceVersion(ctx context.Context) (string, error) {
+	resource, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return resource.GetResourceVersion(), nil
+}
+
+// resourceVersion returns the resource version of the backing ConfigMap. Only
+// the metadata of the ConfigMap is read, when possible.
+func (c configMapStore) resourceVersion(ctx context.Context) (string, error) {
+	if c.metadata != nil {
+		object, err := c.metadata.Get(ctx, c.name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return object.ResourceVersion, nil
+	}
+
+	configMap, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return configMap.ResourceVersion, nil
+}
+
+// resourceVersion returns the resource version of the backing Secret. Only the
+// metadata of the Secret is read, when possible.
+func (c secretStore) resourceVersion(ctx context.Context) (string, error) {
+	if c.metadata != nil {
+		object, err := c.metadata.Get(ctx, c.name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return object.ResourceVersion, nil
+	}
+
+	secret, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return secret.ResourceVersion, nil
+}
@@ -0,0 +1,163 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ObjectStorage represents a bucket in an object storage service, such as
+// Amazon S3, Google Cloud Storage, or Azure Blob Storage, in which snapshots
+// of a Store can be kept outside of the cluster. Implementations are typically
+// thin adapters around the client of the respective service.
+type ObjectStorage interface {
+	// WriteObject writes the given data to the named object, replacing it if
+	// it already exists.
+	WriteObject(ctx context.Context, name string, data []byte) error
+
+	// ReadObject reads the contents of the named object. If the object does
+	// not exist, an error matching the ErrorKeyNotFound sentinel error should
+	// be returned.
+	ReadObject(ctx context.Context, name string) ([]byte, error)
+}
+
+// backupSnapshot is the stored form of a snapshot of a Store.
+type backupSnapshot struct {
+	Time   time.Time                  `json:"time"`
+	Store  Description                `json:"store"`
+	Values map[string]json.RawMessage `json:"values"`
+}
+
+// ExportToObjectStorage writes a snapshot of every key in the given Store to
+// the named object in the given object storage, so that the contents of the
+// Store can survive the loss of the cluster. The snapshot is a JSON document
+// that also records when it was taken, and a description of the Store.
+func ExportToObjectStorage(ctx context.Context, store Store, storage ObjectStorage, name string, opts ...Option) error {
+	keys, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	values, err := GetMulti(ctx, store, keys, opts...)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(backupSnapshot{
+		Time:   time.Now().UTC(),
+		Store:  Describe(store),
+		Values: values,
+	})
+	if err != nil {
+		return err
+	}
+
+	return storage.WriteObject(ctx, name, data)
+}
+
+// ImportFromObjectStorage restores the snapshot in the named object in the
+// given object storage, as written by ExportToObjectStorage, into the given
+// Store. Every key in the snapshot is set using Apply, and if prune is true,
+// every key that is not in the snapshot is removed, so that the Store matches
+// the snapshot exactly.
+func ImportFromObjectStorage(ctx context.Context, store Store, storage ObjectStorage, name string, prune bool, opts ...Option) error {
+	snapshot, err := readSnapshot(ctx, storage, name)
+	if err != nil {
+		return err
+	}
+
+	return restoreSnapshot(ctx, store, snapshot, prune, opts)
+}
+
+// readSnapshot reads and decodes the snapshot in the named object.
+func readSnapshot(ctx context.Context, storage ObjectStorage, name string) (backupSnapshot, error) {
+	var snapshot backupSnapshot
+
+	data, err := storage.ReadObject(ctx, name)
+	if err != nil {
+		return snapshot, err
+	}
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("object %s is %w: %v", name, errNotSnapshot, err)
+	}
+
+	// Every snapshot records when it was taken.
+	if snapshot.Time.IsZero() {
+		return snapshot, fmt.Errorf("object %s is %w", name, errNotSnapshot)
+	}
+
+	return snapshot, nil
+}
+
+// restoreSnapshot writes every key in the given snapshot into the given Store.
+func restoreSnapshot(ctx context.Context, store Store, snapshot backupSnapshot, prune bool, opts []Option) error {
+	desired := make(map[string]interface{}, len(snapshot.Values))
+	for key, value := range snapshot.Values {
+		desired[key] = value
+	}
+
+	return Apply(ctx, store, desired, prune, opts...)
+}
+
+// ObjectLister represents an ObjectStorage that is capable of listing the
+// objects that it contains.
+type ObjectLister interface {
+	// ListObjects returns the names of every object that begins with the
+	// given prefix.
+	ListObjects(ctx context.Context, prefix string) ([]string, error)
+}
+
+// RestoreToTime restores the given Store to its contents as of the given
+// time, such as for recovering from a bad deploy that corrupted its state,
+// and returns the time at which the restored snapshot was taken.
+//
+// Every object in the given object storage that begins with the given prefix
+// is read, skipping any objects that are not snapshots, and the latest
+// snapshot taken at or before the given time is restored, replacing the entire
+// contents of the Store. Changes made between snapshots can not be recovered,
+// so the granularity of recovery depends on how often snapshots are exported.
+//
+// If the object storage does not implement the ObjectLister interface, an
+// error matching the ErrorNotSupported sentinel error is returned. If there
+// is no snapshot taken at or before the given time, the ErrorKeyNotFound
+// sentinel error is returned.
+func RestoreToTime(ctx context.Context, store Store, storage ObjectStorage, prefix string, t time.Time, opts ...Option) (time.Time, error) {
+	lister, ok := storage.(ObjectLister)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: object storage does not support listing objects", ErrorNotSupported)
+	}
+
+	names, err := lister.ListObjects(ctx, prefix)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var latest *backupSnapshot
+	for _, name := range names {
+		snapshot, err := readSnapshot(ctx, storage, name)
+		if errors.Is(err, errNotSnapshot) {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if snapshot.Time.After(t) || latest != nil && !snapshot.Time.After(latest.Time) {
+			continue
+		}
+		latest = &snapshot
+	}
+
+	if latest == nil {
+		return time.Time{}, fmt.Errorf("%w: no snapshot was taken at or before %s", ErrorKeyNotFound, t.Format(time.RFC3339))
+	}
+
+	return latest.Time, restoreSnapshot(ctx, store, *latest, true, opts)
+}
@@ -0,0 +1,344 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// MultiSetter represents a Store that is capable of setting multiple keys at
+// once.
+type MultiSetter interface {
+	// SetMany stores each of the given values under its key.
+	SetMany(ctx context.Context, values map[string]interface{}) error
+}
+
+// MultiDeleter represents a Store that is capable of deleting multiple keys at
+// once.
+type MultiDeleter interface {
+	// DeleteMany removes each of the given keys.
+	DeleteMany(ctx context.Context, keys []string) error
+}
+
+// SetMany stores each of the given values under its key in the given Store.
+//
+// If the Store implements the MultiSetter interface, then the values are set
+// at once. Otherwise, each value is set using a call to Store.Set, with up to
+// the number of concurrent calls configured by the WithConcurrency option.
+func SetMany(ctx context.Context, store Store, values map[string]interface{}, opts ...Option) error {
+	if setter, ok := store.(MultiSetter); ok {
+		return setter.SetMany(ctx, values)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	return forEach(ctx, keys, newOptions(opts).concurrency, func(ctx context.Context, key string) error {
+		return store.Set(ctx, key, values[key])
+	})
+}
+
+// DeleteMany removes each of the given keys from the given Store. Keys that do
+// not exist are disregarded.
+//
+// If the Store implements the MultiDeleter interface, then the keys are
+// deleted at once. Otherwise, each key is deleted using a call to
+// Store.Delete, with up to the number of concurrent calls configured by the
+// WithConcurrency option.
+func DeleteMany(ctx context.Context, store Store, keys []string, opts ...Option) error {
+	if deleter, ok := store.(MultiDeleter); ok {
+		return deleter.DeleteMany(ctx, keys)
+	}
+
+	return forEach(ctx, keys, newOptions(opts).concurrency, func(ctx context.Context, key string) error {
+		err := store.Delete(ctx, key)
+		// Disregard keys that do not exist.
+		if isKeyNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
+
+// forEach calls the given function for each of the given keys, with up to the
+// given number of concurrent calls. The first error encountered is returned,
+// after cancelling the context passed to any remaining calls.
+func forEach(ctx context.Context, keys []string, concurrency int, fn func(ctx context.Context, key string) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	slots := make(chan struct{}, concurrency)
+
+	for _, key := range keys {
+		// Wait for a free slot, unless an error has already been
+		// encountered.
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := fn(ctx, key); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(key)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// SetMany writes all of the given entries and values into the backing
+// resource annotations using a single API call.
+func (c annotationStore) SetMany(ctx context.Context, values map[string]interface{}) error {
+	payloads := make(map[string][]byte, len(values))
+	for key, value := range values {
+		// Marshal the the given value as JSON.
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		payloads[key] = data
+	}
+
+	err := c.modify(ctx, func(existing map[string]string) (map[string]interface{}, error) {
+		// Account for the size of the existing annotations.
+		annotations := make(map[string]interface{})
+		for key, data := range payloads {
+			c.setChanges(existing, key, data, annotations)
+		}
+		return annotations, checkAnnotationSize(existing, annotations)
+	})
+	if err != nil {
+		// The backing resource is not created on-demand, so report that it
+		// does not exist.
+		if isResourceMissingError(err) {
+			return resourceMissingError{err: err}
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	return nil
+}
+
+// DeleteMany removes all of the given annotations from the backing resource
+// using a single API call.
+func (c annotationStore) DeleteMany(ctx context.Context, keys []string) error {
+	err := c.modify(ctx, func(existing map[string]string) (map[string]interface{}, error) {
+		// Determine which annotations hold the value for each key.
+		annotations := make(map[string]interface{})
+		for _, key := range keys {
+			for _, name := range keyAnnotations(existing, key) {
+				annotations[name] = nil
+			}
+		}
+		return annotations, nil
+	})
+
+	// If the backing resource does not exist, then the keys also do not
+	// exist, so there's nothing else to do.
+	if err != nil && !isResourceMissingError(err) {
+		return err
+	}
+	return nil
+}
+
+// SetMany writes all of the given entries and values into the backing
+// ConfigMap using a single API call.
+//
+// If the backing ConfigMap does not exist, it is created on-demand.
+func (c configMapStore) SetMany(ctx context.Context, values map[string]interface{}) error {
+	// Construct a patch for setting all of the data values.
+	patch := configMapPatch{
+		Data: make(map[string]interface{}, len(values)),
+	}
+	for key, value := range values {
+		// Marshal the the given value as JSON.
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		patch.Data[key] = string(data)
+	}
+
+	// Convert the patch to JSON.
+	payload, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	// Use the Kuberneties API to patch the backing ConfigMap.
+	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
+	if err != nil {
+		if isResourceMissingError(err) {
+			// If the backing ConfigMap does not exist, then create it
+			// on-demand, and retry setting the values.
+			if err := c.create(ctx); err != nil {
+				return err
+			}
+			return c.SetMany(ctx, values)
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	return nil
+}
+
+// DeleteMany removes all of the given entries from the backing ConfigMap using
+// a single API call.
+//
+// If the backing ConfigMap is empty (if it has no data entries), it is then
+// deleted.
+func (c configMapStore) DeleteMany(ctx context.Context, keys []string) error {
+	// Construct a patch for deleting all of the data values.
+	patch := configMapPatch{
+		Data: make(map[string]interface{}, len(keys)),
+	}
+	for _, key := range keys {
+		patch.Data[key] = nil
+	}
+
+	// Convert the patch to JSON.
+	payload, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	// Use the Kuberneties API to patch the backing ConfigMap.
+	configMap, err := c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
+	if err != nil {
+		// If the backing ConfigMap does not exist, then the keys also do not
+		// exist, so there's nothing else to do.
+		if isResourceMissingError(err) {
+			return nil
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	// Is the backing ConfigMap now empty?
+	if len(configMap.Data) == 0 {
+		// Delete the backing ConfigMap in order to clean up after ourselves.
+		// Intentionally ignore any errors, as this is non-essential.
+		_ = c.delete(ctx)
+	}
+
+	return nil
+}
+
+// SetMany writes all of the given entries and values into the backing Secret
+// using a single API call.
+//
+// If the backing Secret does not exist, it is created on-demand.
+func (c secretStore) SetMany(ctx context.Context, values map[string]interface{}) error {
+	// Construct a patch for setting all of the stringData values.
+	patch := secretPatch{
+		StringData: make(map[string]interface{}, len(values)),
+	}
+	for key, value := range values {
+		// Marshal the the given value as JSON.
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		patch.StringData[key] = string(data)
+	}
+
+	// Convert the patch to JSON.
+	payload, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	// Use the Kuberneties API to patch the backing Secret.
+	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
+	if err != nil {
+		if isResourceMissingError(err) {
+			// If the backing Secret does not exist, then create it
+			// on-demand, and retry setting the values.
+			if err := c.create(ctx); err != nil {
+				return err
+			}
+			return c.SetMany(ctx, values)
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	return nil
+}
+
+// DeleteMany removes all of the given entries from the backing Secret using a
+// single API call.
+//
+// If the backing Secret is empty (if it has no data entries), it is then
+// deleted.
+func (c secretStore) DeleteMany(ctx context.Context, keys []string) error {
+	// Construct a patch for deleting all of the data values.
+	patch := secretPatch{
+		Data: make(map[string]interface{}, len(keys)),
+	}
+	for _, key := range keys {
+		patch.Data[key] = nil
+	}
+
+	// Convert the patch to JSON.
+	payload, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	// Use the Kuberneties API to patch the backing Secret.
+	secret, err := c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
+	if err != nil {
+		// If the backing Secret does not exist, then the keys also do not
+		// exist, so there's nothing else to do.
+		if isResourceMissingError(err) {
+			return nil
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	// Is the backing Secret now empty?
+	if len(secret.Data) == 0 {
+		// Delete the backing Secret in order to clean up after ourselves.
+		// Intentionally ignore any errors, as this is non-essential.
+		_ = c.delete(ctx)
+	}
+
+	return nil
+}
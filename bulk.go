@@ -0,0 +1,150 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// defaultBatchBytes is the default maximum combined size of the values in a
+// single batch written by BulkLoad, which leaves ample headroom below the
+// limit that the Kubernetes API places on the size of a single object.
+const defaultBatchBytes = 512 * 1024
+
+// BulkItem is a single entry to be written by BulkLoad.
+type BulkItem struct {
+	// Key is the name of the entry.
+	Key string
+
+	// Value is the value of the entry.
+	Value interface{}
+}
+
+// BulkResult is the outcome of writing a single BulkItem.
+type BulkResult struct {
+	// Key is the name of the entry.
+	Key string
+
+	// Err is the error encountered while writing the entry, or nil if the
+	// entry was written successfully.
+	Err error
+}
+
+// BulkLoad writes every item received from the given channel into the given
+// Store, until the channel is closed or the given context is done, for seeding
+// a Store with a large number of entries.
+//
+// Items are grouped into batches that are written with a single call to
+// SetMany, so a Store that implements the MultiSetter interface writes each
+// batch with a single patch. The combined size of the keys and values in each
+// batch is limited by the WithBatchBytes option, and the rate at which batches
+// are written is limited by the WithRateLimit option.
+//
+// The result of writing every item is sent on the returned channel, which is
+// closed once all items have been written. If a batch could not be written,
+// every item in the batch is reported with the error. Items whose values could
+// not be marshalled are reported immediately, and are not written. Once the
+// given context is done, results that have not been received are discarded.
+func BulkLoad(ctx context.Context, store Store, items <-chan BulkItem, opts ...Option) <-chan BulkResult {
+	o := newOptions(opts)
+	maxBytes := o.batchBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultBatchBytes
+	}
+
+	var limiter flowcontrol.RateLimiter
+	if o.rateLimit > 0 {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(o.rateLimit, o.rateBurst)
+	}
+
+	results := make(chan BulkResult)
+
+	go func() {
+		defer close(results)
+
+		batch := make(map[string]interface{})
+		var order []string
+		var size int
+
+		// send reports the given result, and returns false if the context is
+		// done before the result is received.
+		send := func(result BulkResult) bool {
+			select {
+			case results <- result:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// flush writes the current batch, and reports the result of every item
+		// in the batch. It returns false if the results could not be reported.
+		flush := func() bool {
+			if len(order) == 0 {
+				return true
+			}
+
+			var err error
+			if limiter != nil {
+				err = limiter.Wait(ctx)
+			}
+			if err == nil {
+				err = SetMany(ctx, store, batch, opts...)
+			}
+
+			for _, key := range order {
+				if !send(BulkResult{Key: key, Err: err}) {
+					return false
+				}
+			}
+
+			batch = make(map[string]interface{})
+			order = nil
+			size = 0
+			return true
+		}
+
+		for {
+			var item BulkItem
+			var ok bool
+			select {
+			case item, ok = <-items:
+			case <-ctx.Done():
+			}
+			if !ok {
+				break
+			}
+
+			// Marshal the the given value as JSON, so that its size is known.
+			data, err := json.Marshal(item.Value)
+			if err != nil {
+				if !send(BulkResult{Key: item.Key, Err: err}) {
+					return
+				}
+				continue
+			}
+
+			// A key that is already in the batch must be written in a later
+			// batch, so that the latest value wins.
+			_, duplicate := batch[item.Key]
+			if duplicate || size+len(item.Key)+len(data) > maxBytes {
+				if !flush() {
+					return
+				}
+			}
+
+			batch[item.Key] = json.RawMessage(data)
+			order = append(order, item.Key)
+			size += len(item.Key) + len(data)
+		}
+
+		flush()
+	}()
+
+	return results
+}
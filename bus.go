@@ -0,0 +1,112 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// busMessage is the envelope in which a published message is stored.
+type busMessage struct {
+	ID      string          `json:"id"`
+	Sent    time.Time       `json:"sent"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Bus is a simple publish/subscribe messaging layer built on top of a Store,
+// where each topic is stored as a single key.
+//
+// Messages are delivered on a best-effort basis. Each topic only retains the
+// most recently published message, so subscribers may not observe every
+// message when messages are published in quick succession. This makes a Bus
+// suitable for broadcasting signals such as cache invalidation or
+// configuration reloads, but not for reliable message delivery.
+type Bus struct {
+	store Store
+}
+
+// NewBus returns a Bus built on top of the given Store.
+//
+// Subscribing to topics requires that the Store implements the Subscriber
+// interface, which the annotation, ConfigMap, Secret, and mounted volume
+// Stores do, regardless of the options they are configured with. Messages can
+// be published to any Store, but Bus.Subscribe returns an error matching the
+// ErrorNotSupported sentinel error for all other Stores, such as a file Store.
+func NewBus(store Store) *Bus {
+	return &Bus{
+		store: store,
+	}
+}
+
+// Publish broadcasts the given message to all subscribers of the given topic.
+func (b *Bus) Publish(ctx context.Context, topic string, msg interface{}) error {
+	// Marshal the the given message as JSON.
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// Give every message a unique identifier, so that publishing an identical
+	// message twice still results in a change.
+	id, err := randomID()
+	if err != nil {
+		return err
+	}
+
+	return b.store.Set(ctx, topic, busMessage{
+		ID:      id,
+		Sent:    time.Now().UTC(),
+		Payload: payload,
+	})
+}
+
+// Subscribe returns a channel which receives every message subsequently
+// published to the given topic. The channel is closed once the given context
+// is done.
+//
+// If the underlying Store does not implement the Subscriber interface, an
+// error matching the ErrorNotSupported sentinel error is returned.
+func (b *Bus) Subscribe(ctx context.Context, topic string) (<-chan json.RawMessage, error) {
+	events, err := Subscribe(ctx, b.store)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(chan json.RawMessage)
+
+	go func() {
+		defer close(messages)
+
+		// Disregard the message that was already published when subscribing,
+		// along with any repeated notifications for the same message.
+		var last string
+		var previous busMessage
+		if err := b.store.Get(ctx, topic, &previous); err == nil {
+			last = previous.ID
+		}
+
+		for event := range events {
+			if event.Key != topic || event.Type != EventSet {
+				continue
+			}
+
+			var message busMessage
+			if err := b.store.Get(ctx, topic, &message); err != nil || message.ID == last {
+				continue
+			}
+			last = message.ID
+
+			select {
+			case messages <- message.Payload:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return messages, nil
+}
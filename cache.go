@@ -0,0 +1,480 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a single cached value.
+type cacheEntry struct {
+	key     string
+	data    json.RawMessage
+	missing bool
+	expires time.Time
+
+	// version is the resource version of the object backing the wrapped
+	// Store when the value was read, if revalidation is enabled.
+	version string
+}
+
+// size returns the number of bytes that the entry counts towards the limit
+// configured by the WithMaxBytes option.
+func (e *cacheEntry) size() int {
+	return len(e.key) + len(e.data)
+}
+
+// CacheStats holds counters describing the effectiveness of a CacheStore,
+// since it was created.
+type CacheStats struct {
+	// Hits is the number of reads served from the cache, including reads of
+	// keys that were cached as not found.
+	Hits uint64
+
+	// NegativeHits is the number of reads served from the cache for keys that
+	// were cached as not found.
+	NegativeHits uint64
+
+	// Misses is the number of reads that were not served from the cache, and
+	// so were made against the wrapped Store.
+	Misses uint64
+
+	// Expirations is the number of cached values that were discarded upon
+	// being read after they had expired.
+	Expirations uint64
+
+	// Evictions is the number of cached values that were discarded to make
+	// room for another value, when the cache was full.
+	Evictions uint64
+
+	// Invalidations is the number of cached values that were discarded
+	// because they were invalidated.
+	Invalidations uint64
+
+	// Revalidations is the number of expired values that were served from
+	// the cache after confirming that the backing object was unchanged, when
+	// the WithRevalidation option is used. These reads are also counted as
+	// hits.
+	Revalidations uint64
+
+	// Entries is the number of values currently cached.
+	Entries int
+
+	// Bytes is the combined size of the keys and values currently cached.
+	Bytes int
+}
+
+// CacheEntryInfo describes a single cached value.
+type CacheEntryInfo struct {
+	// Key is the name of the cached key.
+	Key string
+
+	// Missing is true if the key was cached as not found.
+	Missing bool
+
+	// Size is the size of the cached value, in bytes.
+	Size int
+
+	// Expires is the time after which the cached value is discarded.
+	Expires time.Time
+}
+
+// pendingRead tracks the reads of a single key that are in progress against
+// the wrapped Store, so that a value that was invalidated while it was being
+// read is not cached.
+type pendingRead struct {
+	readers    int
+	generation uint64
+}
+
+// Assert that CacheStore implements the Store interface.
+var _ Store = (*CacheStore)(nil)
+
+// CacheStore is a Store that wraps another Store, and caches the values that
+// are read from it in memory.
+type CacheStore struct {
+	store      Store
+	maxEntries int
+	ttl        time.Duration
+	options    options
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	recency *list.List
+	stats   CacheStats
+	bytes   int
+	pending map[string]*pendingRead
+
+	warm     chan struct{}
+	warmOnce sync.Once
+}
+
+// NewCacheStore returns a CacheStore that wraps the given Store, and caches up
+// to the given number of values for the given duration. When the cache is
+// full, the least recently used value is evicted.
+//
+// Values are only cached by calls to Store.Get and CacheStore.Preload, and are
+// invalidated by calls to Store.Set and Store.Delete. Changes made by other
+// processes are not observed until the cached value expires, or is explicitly
+// invalidated.
+//
+// The combined size of the cached keys and values can additionally be limited
+// using the WithMaxBytes option. Keys that were not found can be cached using
+// the WithNegativeCache option, and expired values can be revalidated rather
+// than read again using the WithRevalidation option.
+func NewCacheStore(store Store, maxEntries int, ttl time.Duration, opts ...Option) *CacheStore {
+	return &CacheStore{
+		store:      store,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		options:    newOptions(opts),
+		entries:    make(map[string]*list.Element),
+		recency:    list.New(),
+		pending:    make(map[string]*pendingRead),
+		warm:       make(chan struct{}),
+	}
+}
+
+// Get reads the named entry from the cache, or from the wrapped Store if it is
+// not cached, and stores the contents into the given value pointer.
+func (s *CacheStore) Get(ctx context.Context, key string, value interface{}) error {
+	if entry, found := s.lookup(key); found {
+		if entry.missing {
+			return ErrorKeyNotFound
+		}
+		return json.Unmarshal(entry.data, value)
+	}
+
+	if entry, found := s.revalidate(ctx, key); found {
+		return json.Unmarshal(entry.data, value)
+	}
+
+	// Read the resource version before the value, so that a concurrent change
+	// results in the value being read again when it is next revalidated.
+	var version string
+	if s.options.revalidate {
+		version, _ = resourceVersionOf(ctx, s.store)
+	}
+
+	// Record the generation of the key before reading it, so that the value
+	// is not cached if it was invalidated in the interim.
+	generation := s.begin(key)
+
+	var data json.RawMessage
+	if err := s.store.Get(ctx, key, &data); err != nil {
+		// Remember that the key was not found, if configured to do so.
+		if errors.Is(err, ErrorKeyNotFound) && s.options.negativeTTL > 0 {
+			s.end(key, generation, &cacheEntry{key: key, missing: true, expires: time.Now().Add(s.options.negativeTTL)})
+			return err
+		}
+		s.end(key, generation, nil)
+		return err
+	}
+	s.end(key, generation, &cacheEntry{key: key, data: data, expires: time.Now().Add(s.ttl), version: version})
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(data, value)
+}
+
+// Set writes the named entry and value into the wrapped Store, and invalidates
+// any cached value.
+func (s *CacheStore) Set(ctx context.Context, key string, value interface{}) error {
+	defer s.Invalidate(key)
+	return s.store.Set(ctx, key, value)
+}
+
+// List returns a list of all keys in the wrapped Store. Listings are not
+// cached.
+func (s *CacheStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store, and invalidates any
+// cached value.
+func (s *CacheStore) Delete(ctx context.Context, key string) error {
+	defer s.Invalidate(key)
+	return s.store.Delete(ctx, key)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s *CacheStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// Invalidate removes the cached value for the named entry, if any.
+func (s *CacheStore) Invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, found := s.entries[key]; found {
+		s.remove(element)
+		s.stats.Invalidations++
+	}
+	if pending, found := s.pending[key]; found {
+		pending.generation++
+	}
+}
+
+// InvalidateAll removes all cached values.
+func (s *CacheStore) InvalidateAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Invalidations += uint64(len(s.entries))
+	s.entries = make(map[string]*list.Element)
+	s.recency.Init()
+	s.bytes = 0
+	for _, pending := range s.pending {
+		pending.generation++
+	}
+}
+
+// Preload reads the given keys from the wrapped Store and caches them, or
+// every key in the wrapped Store if no keys are given, so that latency
+// sensitive callers do not incur the cost of reading them on first use. Keys
+// are read using GetMulti, with the concurrency configured by the
+// WithConcurrency option, and keys that are not found are cached as such if
+// the WithNegativeCache option is used.
+//
+// Once a call to Preload succeeds, the CacheStore is considered warm, and any
+// calls to CacheStore.WaitForWarm return.
+func (s *CacheStore) Preload(ctx context.Context, keys ...string) error {
+	// Read the resource version before the values, as with Store.Get.
+	var version string
+	if s.options.revalidate {
+		version, _ = resourceVersionOf(ctx, s.store)
+	}
+
+	if len(keys) == 0 {
+		var err error
+		if keys, err = s.store.List(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Record the generation of each key before reading them, as with
+	// Store.Get.
+	generations := make([]uint64, len(keys))
+	for index, key := range keys {
+		generations[index] = s.begin(key)
+	}
+
+	values, err := GetMulti(ctx, s.store, keys, WithConcurrency(s.options.concurrency))
+	if err != nil {
+		for index, key := range keys {
+			s.end(key, generations[index], nil)
+		}
+		return err
+	}
+
+	now := time.Now()
+	for index, key := range keys {
+		if data, found := values[key]; found {
+			s.end(key, generations[index], &cacheEntry{key: key, data: data, expires: now.Add(s.ttl), version: version})
+		} else if s.options.negativeTTL > 0 {
+			s.end(key, generations[index], &cacheEntry{key: key, missing: true, expires: now.Add(s.options.negativeTTL)})
+		} else {
+			s.end(key, generations[index], nil)
+		}
+	}
+
+	s.warmOnce.Do(func() {
+		close(s.warm)
+	})
+	return nil
+}
+
+// WaitForWarm blocks until a call to CacheStore.Preload has succeeded, or
+// until the given context is done, such as for delaying a readiness probe
+// until the cache is populated.
+func (s *CacheStore) WaitForWarm(ctx context.Context) error {
+	select {
+	case <-s.warm:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns counters describing the effectiveness of the cache.
+func (s *CacheStore) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.Entries = len(s.entries)
+	stats.Bytes = s.bytes
+	return stats
+}
+
+// Entries returns a description of every cached value, from the most recently
+// used to the least recently used. Values that have expired, but that have
+// yet to be discarded, are included.
+func (s *CacheStore) Entries() []CacheEntryInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]CacheEntryInfo, 0, s.recency.Len())
+	for element := s.recency.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*cacheEntry)
+		infos = append(infos, CacheEntryInfo{
+			Key:     entry.key,
+			Missing: entry.missing,
+			Size:    len(entry.data),
+			Expires: entry.expires,
+		})
+	}
+	return infos
+}
+
+// lookup returns the cached entry for the named key, if it has not expired.
+func (s *CacheStore) lookup(key string) (*cacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, found := s.entries[key]
+	if !found {
+		s.stats.Misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !time.Now().Before(entry.expires) {
+		// Keep expired entries that can be revalidated.
+		if entry.version != "" {
+			return nil, false
+		}
+		s.remove(element)
+		s.stats.Expirations++
+		s.stats.Misses++
+		return nil, false
+	}
+
+	s.recency.MoveToFront(element)
+	s.stats.Hits++
+	if entry.missing {
+		s.stats.NegativeHits++
+	}
+	return entry, true
+}
+
+// revalidate returns the expired cached entry for the named key, if the
+// resource version of the object backing the wrapped Store is unchanged since
+// the entry was read, and refreshes its expiry.
+func (s *CacheStore) revalidate(ctx context.Context, key string) (*cacheEntry, bool) {
+	s.mu.Lock()
+	element, found := s.entries[key]
+	s.mu.Unlock()
+	if !found {
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if entry.version == "" {
+		return nil, false
+	}
+
+	version, err := resourceVersionOf(ctx, s.store)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The entry may have been invalidated or replaced in the interim.
+	if s.entries[key] != element {
+		s.stats.Misses++
+		return nil, false
+	}
+
+	if err != nil || version != entry.version {
+		s.remove(element)
+		s.stats.Expirations++
+		s.stats.Misses++
+		return nil, false
+	}
+
+	entry.expires = time.Now().Add(s.ttl)
+	s.recency.MoveToFront(element)
+	s.stats.Hits++
+	s.stats.Revalidations++
+	return entry, true
+}
+
+// begin records that the named key is about to be read from the wrapped
+// Store, and returns its current generation, which changes every time that the
+// key is invalidated. Every call must be followed by a call to
+// CacheStore.end.
+func (s *CacheStore) begin(key string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, found := s.pending[key]
+	if !found {
+		pending = &pendingRead{}
+		s.pending[key] = pending
+	}
+	pending.readers++
+	return pending.generation
+}
+
+// end records that a read of the named key, which began at the given
+// generation, has finished, and caches the given entry, if any, as long as
+// the key was not invalidated while it was being read.
+func (s *CacheStore) end(key string, generation uint64, entry *cacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.pending[key]
+	if pending.readers--; pending.readers == 0 {
+		delete(s.pending, key)
+	}
+
+	if entry != nil && pending.generation == generation {
+		s.add(entry)
+	}
+}
+
+// add caches the given entry, evicting the least recently used entries if
+// the cache is full. The caller must hold the lock.
+func (s *CacheStore) add(entry *cacheEntry) {
+	if element, found := s.entries[entry.key]; found {
+		s.remove(element)
+	}
+
+	// Never cache a value that is larger than the entire cache, as it would
+	// only evict every other value before being evicted itself.
+	maxBytes := s.options.maxBytes
+	if maxBytes > 0 && entry.size() > maxBytes {
+		return
+	}
+
+	s.entries[entry.key] = s.recency.PushFront(entry)
+	s.bytes += entry.size()
+
+	for (s.maxEntries > 0 && s.recency.Len() > s.maxEntries) || (maxBytes > 0 && s.bytes > maxBytes) {
+		s.remove(s.recency.Back())
+		s.stats.Evictions++
+	}
+}
+
+// remove removes the given element from the cache. The caller must hold the
+// lock.
+func (s *CacheStore) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	s.recency.Remove(element)
+	delete(s.entries, entry.key)
+	s.bytes -= entry.size()
+}
+
+// Update atomically modifies the named entry in the wrapped Store, and
+// invalidates any cached value.
+func (s *CacheStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	defer s.Invalidate(key)
+	return Update(ctx, s.store, key, fn)
+}
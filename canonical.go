@@ -0,0 +1,101 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// Assert that canonicalStore implements the Store interface.
+var _ Store = canonicalStore{}
+
+type canonicalStore struct {
+	store Store
+}
+
+// canonicalJSON marshals the given value as canonical JSON, in which the keys
+// of every object are sorted, numbers are preserved exactly, and there is no
+// insignificant whitespace. Equivalent values always result in identical
+// output, regardless of the order of struct fields.
+func canonicalJSON(value interface{}) (json.RawMessage, error) {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects are decoded as maps, which are marshalled with sorted keys.
+	var document interface{}
+	if err := unmarshalNumber(data, &document); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(document)
+}
+
+// Get reads the named entry from the wrapped Store.
+func (s canonicalStore) Get(ctx context.Context, key string, value interface{}) error {
+	return s.store.Get(ctx, key, value)
+}
+
+// Set writes the named entry and value into the wrapped Store, as canonical
+// JSON.
+func (s canonicalStore) Set(ctx context.Context, key string, value interface{}) error {
+	data, err := canonicalJSON(value)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(ctx, key, data)
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s canonicalStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s canonicalStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// GetMulti reads the given keys from the wrapped Store.
+func (s canonicalStore) GetMulti(ctx context.Context, keys []string) (map[string]json.RawMessage, error) {
+	return GetMulti(ctx, s.store, keys)
+}
+
+// Update atomically modifies the named entry in the wrapped Store, writing the
+// result of the given function as canonical JSON.
+func (s canonicalStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		result, err := fn(current)
+		if err != nil || result == nil {
+			return result, err
+		}
+		return canonicalJSON(result)
+	})
+}
+
+// Describe returns a description of the wrapped Store.
+func (s canonicalStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s canonicalStore) resourceVersion(ctx context.Context) (string, error) {
+	return resourceVersionOf(ctx, s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s canonicalStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// Dump writes the raw contents of the wrapped Store.
+func (s canonicalStore) Dump(ctx context.Context, w io.Writer, opts ...Option) error {
+	return Dump(ctx, s.store, w, opts...)
+}
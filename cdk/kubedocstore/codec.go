@@ -0,0 +1,266 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubedocstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"gocloud.dev/docstore/driver"
+)
+
+// storedDoc is a document in the form in which it is stored, which only
+// contains values that survive a round trip through JSON.
+type storedDoc map[string]interface{}
+
+// encodeDoc encodes the given document as a storedDoc.
+func encodeDoc(doc driver.Document) (storedDoc, error) {
+	var e encoder
+	if err := doc.Encode(&e); err != nil {
+		return nil, err
+	}
+	return storedDoc(e.val.(map[string]interface{})), nil
+}
+
+// encodeValue encodes the given value in the form in which it is stored.
+func encodeValue(v interface{}) (interface{}, error) {
+	var e encoder
+	if err := driver.Encode(reflect.ValueOf(v), &e); err != nil {
+		return nil, err
+	}
+	return e.val, nil
+}
+
+// marshalDoc marshals the given document as JSON.
+func marshalDoc(doc storedDoc) (json.RawMessage, error) {
+	return json.Marshal(doc)
+}
+
+// unmarshalDoc unmarshals the given JSON as a document, keeping integers
+// distinct from floating point numbers.
+func unmarshalDoc(data json.RawMessage) (storedDoc, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	doc, ok := normalize(value).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: value is not a document", errInvalidArgument)
+	}
+	return doc, nil
+}
+
+// normalize replaces every json.Number in the given value with an int64 if it
+// is an integer, or a float64 otherwise.
+func normalize(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case []interface{}:
+		for i := range value {
+			value[i] = normalize(value[i])
+		}
+	case map[string]interface{}:
+		for k := range value {
+			value[k] = normalize(value[k])
+		}
+	}
+	return value
+}
+
+// encoder encodes values in the form in which they are stored. Byte slices
+// are stored as base64 strings, and times are stored as RFC 3339 strings.
+type encoder struct {
+	val interface{}
+}
+
+func (e *encoder) EncodeNil()            { e.val = nil }
+func (e *encoder) EncodeBool(x bool)     { e.val = x }
+func (e *encoder) EncodeInt(x int64)     { e.val = x }
+func (e *encoder) EncodeUint(x uint64)   { e.val = int64(x) }
+func (e *encoder) EncodeFloat(x float64) { e.val = x }
+func (e *encoder) EncodeString(x string) { e.val = x }
+func (e *encoder) ListIndex(int)         { panic("impossible") }
+func (e *encoder) MapKey(string)         { panic("impossible") }
+
+func (e *encoder) EncodeBytes(x []byte) {
+	e.val = base64.StdEncoding.EncodeToString(x)
+}
+
+var typeOfTime = reflect.TypeOf(time.Time{})
+
+func (e *encoder) EncodeSpecial(v reflect.Value) (bool, error) {
+	if v.Type() == typeOfTime {
+		e.val = v.Interface().(time.Time).Format(time.RFC3339Nano)
+		return true, nil
+	}
+	return false, nil
+}
+
+func (e *encoder) EncodeList(n int) driver.Encoder {
+	s := make([]interface{}, n)
+	e.val = s
+	return &listEncoder{s: s}
+}
+
+type listEncoder struct {
+	s []interface{}
+	encoder
+}
+
+func (e *listEncoder) ListIndex(i int) { e.s[i] = e.val }
+
+func (e *encoder) EncodeMap(n int) driver.Encoder {
+	m := make(map[string]interface{}, n)
+	e.val = m
+	return &mapEncoder{m: m}
+}
+
+type mapEncoder struct {
+	m map[string]interface{}
+	encoder
+}
+
+func (e *mapEncoder) MapKey(k string) { e.m[k] = e.val }
+
+// decodeDoc decodes the given stored document into the given document,
+// limited to the given field paths if there are any.
+func decodeDoc(doc storedDoc, ddoc driver.Document, fps [][]string) error {
+	m := map[string]interface{}(doc)
+	if len(fps) > 0 {
+		m = make(map[string]interface{})
+		for _, fp := range fps {
+			value, found := getAtFieldPath(doc, fp)
+			if !found {
+				continue
+			}
+			parent, err := getParentMap(m, fp, true)
+			if err != nil {
+				return err
+			}
+			parent[fp[len(fp)-1]] = value
+		}
+	}
+	return ddoc.Decode(decoder{m})
+}
+
+// decoder decodes values from the form in which they are stored.
+type decoder struct {
+	val interface{}
+}
+
+func (d decoder) String() string {
+	return fmt.Sprint(d.val)
+}
+
+func (d decoder) AsNull() bool {
+	return d.val == nil
+}
+
+func (d decoder) AsBool() (bool, bool) {
+	b, ok := d.val.(bool)
+	return b, ok
+}
+
+func (d decoder) AsString() (string, bool) {
+	s, ok := d.val.(string)
+	return s, ok
+}
+
+func (d decoder) AsInt() (int64, bool) {
+	switch v := d.val.(type) {
+	case int64:
+		return v, true
+	case float64:
+		// Integral floating point numbers are indistinguishable from
+		// integers once stored as JSON.
+		if v == math.Trunc(v) && v >= math.MinInt64 && v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+func (d decoder) AsUint() (uint64, bool) {
+	i, ok := d.AsInt()
+	return uint64(i), ok
+}
+
+func (d decoder) AsFloat() (float64, bool) {
+	switch v := d.val.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func (d decoder) AsBytes() ([]byte, bool) {
+	s, ok := d.val.(string)
+	if !ok {
+		return nil, false
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	return b, err == nil
+}
+
+func (d decoder) AsInterface() (interface{}, error) {
+	return d.val, nil
+}
+
+func (d decoder) ListLen() (int, bool) {
+	if s, ok := d.val.([]interface{}); ok {
+		return len(s), true
+	}
+	return 0, false
+}
+
+func (d decoder) DecodeList(f func(int, driver.Decoder) bool) {
+	for i, e := range d.val.([]interface{}) {
+		if !f(i, decoder{e}) {
+			return
+		}
+	}
+}
+
+func (d decoder) MapLen() (int, bool) {
+	if m, ok := d.val.(map[string]interface{}); ok {
+		return len(m), true
+	}
+	return 0, false
+}
+
+func (d decoder) DecodeMap(f func(string, driver.Decoder, bool) bool) {
+	for k, v := range d.val.(map[string]interface{}) {
+		if !f(k, decoder{v}, true) {
+			return
+		}
+	}
+}
+
+func (d decoder) AsSpecial(v reflect.Value) (bool, interface{}, error) {
+	if v.Type() == typeOfTime {
+		s, ok := d.val.(string)
+		if !ok {
+			return true, nil, fmt.Errorf("%w: cannot decode %v as a time", errInvalidArgument, d.val)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		return true, t, err
+	}
+	return false, nil, nil
+}
@@ -0,0 +1,414 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Package kubedocstore provides a docstore driver for the Go Cloud Development
+// Kit (gocloud.dev), with documents stored as JSON objects under the keys of a
+// kubestore.Store. Use OpenCollection to construct a *docstore.Collection.
+//
+// Document keys must be strings, and are used as Store keys after being
+// prefixed with the collection prefix. Revisions are strings, stored in the
+// revision field of each document.
+//
+// Writes are conditional when the Store implements the kubestore.Updater
+// interface, so that concurrent writers never lose each other's changes.
+// Otherwise, they fall back to an unconditional read followed by a write.
+// Atomic writes across multiple documents are not supported.
+//
+// Queries are evaluated by reading every document in the collection, and so
+// are only suitable for small collections.
+//
+// For docstore.OpenCollection, kubedocstore registers for the scheme
+// "kubestore", using URLs of the form "kubestore://<prefix>/<key field>". The
+// default URL opener uses the Store described by environment variables, as
+// described by kubestore.NewFromEnv.
+package kubedocstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/joshdk/kubestore"
+	"gocloud.dev/docstore"
+	"gocloud.dev/docstore/driver"
+	"gocloud.dev/gcerrors"
+)
+
+var (
+	// errAlreadyExists is returned when creating a document that exists.
+	errAlreadyExists = errors.New("document already exists")
+
+	// errInvalidArgument is returned when given a document or query that
+	// cannot be used with a collection.
+	errInvalidArgument = errors.New("invalid argument")
+)
+
+// Options are the options for a collection.
+type Options struct {
+	// Prefix is prepended to every document key to form a Store key, so that
+	// multiple collections can share a Store.
+	Prefix string
+
+	// RevisionField is the name of the field holding the document revision,
+	// which defaults to docstore.DefaultRevisionField.
+	RevisionField string
+
+	// MaxOutstandingActions is the maximum number of actions that are run
+	// concurrently for a single call to ActionList.Do. If less than 1, there
+	// is no limit.
+	MaxOutstandingActions int
+}
+
+// OpenCollection returns a *docstore.Collection that stores documents under
+// the keys of the given Store. The given key field is the document field
+// holding the document key.
+func OpenCollection(store kubestore.Store, keyField string, opts *Options) (*docstore.Collection, error) {
+	if store == nil {
+		return nil, fmt.Errorf("%w: store is required", errInvalidArgument)
+	}
+	if keyField == "" {
+		return nil, fmt.Errorf("%w: key field is required", errInvalidArgument)
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	options := *opts
+	if options.RevisionField == "" {
+		options.RevisionField = docstore.DefaultRevisionField
+	}
+	return docstore.NewCollection(&collection{
+		store:    store,
+		keyField: keyField,
+		opts:     options,
+	}), nil
+}
+
+// collection implements driver.Collection.
+type collection struct {
+	store    kubestore.Store
+	keyField string
+	opts     Options
+}
+
+// Key returns the key of the given document, or nil if it has no key.
+func (c *collection) Key(doc driver.Document) (interface{}, error) {
+	key, _ := doc.GetField(c.keyField)
+	if key == nil {
+		return nil, nil
+	}
+	if _, ok := key.(string); !ok {
+		return nil, fmt.Errorf("%w: key %v of type %T is not a string", errInvalidArgument, key, key)
+	}
+	return key, nil
+}
+
+// RevisionField returns the name of the field holding the document revision.
+func (c *collection) RevisionField() string {
+	return c.opts.RevisionField
+}
+
+// storeKey returns the Store key used for the given document key.
+func (c *collection) storeKey(key interface{}) string {
+	return c.opts.Prefix + key.(string)
+}
+
+// RunActions runs the given actions, with writes to distinct keys running
+// concurrently with each other.
+func (c *collection) RunActions(ctx context.Context, actions []*driver.Action, opts *driver.RunActionsOptions) driver.ActionListError {
+	errs := make([]error, len(actions))
+
+	run := func(as []*driver.Action) {
+		t := driver.NewThrottle(c.opts.MaxOutstandingActions)
+		for _, a := range as {
+			a := a
+			t.Acquire()
+			go func() {
+				defer t.Release()
+				errs[a.Index] = c.runAction(ctx, a)
+			}()
+		}
+		t.Wait()
+	}
+
+	if opts.BeforeDo != nil {
+		if err := opts.BeforeDo(func(interface{}) bool { return false }); err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+			return driver.NewActionListError(errs)
+		}
+	}
+
+	beforeGets, gets, writes, writesTx, afterGets := driver.GroupActions(actions)
+	for _, a := range writesTx {
+		errs[a.Index] = fmt.Errorf("%w: atomic writes", kubestore.ErrorNotSupported)
+	}
+	run(beforeGets)
+	run(gets)
+	run(writes)
+	run(afterGets)
+	return driver.NewActionListError(errs)
+}
+
+// runAction runs a single action.
+func (c *collection) runAction(ctx context.Context, a *driver.Action) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if a.Kind == driver.Get {
+		var data json.RawMessage
+		if err := c.store.Get(ctx, c.storeKey(a.Key), &data); err != nil {
+			return err
+		}
+		doc, err := unmarshalDoc(data)
+		if err != nil {
+			return err
+		}
+		return decodeDoc(doc, a.Doc, a.FieldPaths)
+	}
+
+	// Documents created without a key are given a unique key.
+	if a.Kind == driver.Create && a.Key == nil {
+		a.Key = driver.UniqueString()
+		if err := a.Doc.SetField(c.keyField, a.Key); err != nil {
+			return fmt.Errorf("%w: cannot set key field %q", errInvalidArgument, c.keyField)
+		}
+	}
+
+	// Determine the revision expected by the action, if any.
+	var want interface{}
+	if a.Kind != driver.Create {
+		if rev, err := a.Doc.GetField(c.opts.RevisionField); err == nil && rev != nil {
+			if _, ok := rev.(string); !ok {
+				return fmt.Errorf("%w: revision field %s is not a string", errInvalidArgument, c.opts.RevisionField)
+			}
+			want = rev
+		}
+	}
+
+	var revision string
+	err := c.update(ctx, c.storeKey(a.Key), func(current json.RawMessage) (json.RawMessage, error) {
+		var existing storedDoc
+		if current != nil {
+			var err error
+			if existing, err = unmarshalDoc(current); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case existing == nil && (a.Kind == driver.Replace || a.Kind == driver.Update):
+			return nil, fmt.Errorf("%w: document with key %v does not exist", kubestore.ErrorKeyNotFound, a.Key)
+		case existing != nil && a.Kind == driver.Create:
+			return nil, fmt.Errorf("%w: document with key %v exists", errAlreadyExists, a.Key)
+		case existing != nil && want != nil && existing[c.opts.RevisionField] != want:
+			return nil, fmt.Errorf("%w: mismatched revisions: want %v, current %v", kubestore.ErrorConflict, want, existing[c.opts.RevisionField])
+		}
+
+		var doc storedDoc
+		switch a.Kind {
+		case driver.Delete:
+			return nil, nil
+		case driver.Update:
+			doc = existing
+			if err := applyMods(doc, a.Mods); err != nil {
+				return nil, err
+			}
+		default:
+			var err error
+			if doc, err = encodeDoc(a.Doc); err != nil {
+				return nil, err
+			}
+		}
+
+		// Every write is given a new revision.
+		revision = driver.UniqueString()
+		doc[c.opts.RevisionField] = revision
+		return marshalDoc(doc)
+	})
+	if err != nil {
+		return err
+	}
+
+	if a.Kind != driver.Delete && a.Doc.HasField(c.opts.RevisionField) {
+		return a.Doc.SetField(c.opts.RevisionField, revision)
+	}
+	return nil
+}
+
+// update atomically modifies the given key, falling back to a separate read
+// and write if the Store does not support atomic updates.
+func (c *collection) update(ctx context.Context, key string, fn kubestore.UpdateFunc) error {
+	err := kubestore.Update(ctx, c.store, key, fn)
+	if !errors.Is(err, kubestore.ErrorNotSupported) {
+		return err
+	}
+
+	var current json.RawMessage
+	if err := c.store.Get(ctx, key, &current); err != nil {
+		if !errors.Is(err, kubestore.ErrorKeyNotFound) {
+			return err
+		}
+		current = nil
+	}
+
+	result, err := fn(current)
+	switch {
+	case err != nil:
+		return err
+	case result == nil && current == nil:
+		return nil
+	case result == nil:
+		return c.store.Delete(ctx, key)
+	default:
+		return c.store.Set(ctx, key, result)
+	}
+}
+
+// applyMods applies the given modifications to the given document.
+func applyMods(doc storedDoc, mods []driver.Mod) error {
+	for _, mod := range mods {
+		parent, err := getParentMap(doc, mod.FieldPath, mod.Value != nil)
+		if err != nil {
+			return err
+		}
+		key := mod.FieldPath[len(mod.FieldPath)-1]
+
+		switch value := mod.Value.(type) {
+		case nil:
+			if parent != nil {
+				delete(parent, key)
+			}
+		case driver.IncOp:
+			amount, err := encodeValue(value.Amount)
+			if err != nil {
+				return err
+			}
+			if parent[key], err = add(parent[key], amount); err != nil {
+				return err
+			}
+		default:
+			if parent[key], err = encodeValue(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// add adds the given stored numbers, which are each an int64 or a float64.
+func add(x, y interface{}) (interface{}, error) {
+	if x == nil {
+		return y, nil
+	}
+	switch x := x.(type) {
+	case int64:
+		switch y := y.(type) {
+		case int64:
+			return x + y, nil
+		case float64:
+			return float64(x) + y, nil
+		}
+	case float64:
+		switch y := y.(type) {
+		case int64:
+			return x + float64(y), nil
+		case float64:
+			return x + y, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: cannot increment %v by %v", errInvalidArgument, x, y)
+}
+
+// getAtFieldPath returns the value at the given field path of the given
+// document, and whether it was found.
+func getAtFieldPath(m map[string]interface{}, fp []string) (interface{}, bool) {
+	var value interface{} = m
+	for _, k := range fp {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// getParentMap returns the map that directly contains the given field path.
+// Missing maps along the way are created if create is true, or otherwise nil
+// is returned.
+func getParentMap(m map[string]interface{}, fp []string, create bool) (map[string]interface{}, error) {
+	for _, k := range fp[:len(fp)-1] {
+		if m[k] == nil {
+			if !create {
+				return nil, nil
+			}
+			m[k] = make(map[string]interface{})
+		}
+		next, ok := m[k].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid field path %q at %q", errInvalidArgument, strings.Join(fp, "."), k)
+		}
+		m = next
+	}
+	return m, nil
+}
+
+// RevisionToBytes converts the given revision to bytes.
+func (c *collection) RevisionToBytes(rev interface{}) ([]byte, error) {
+	r, ok := rev.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: revision %v of type %T is not a string", errInvalidArgument, rev, rev)
+	}
+	return []byte(r), nil
+}
+
+// BytesToRevision converts the given bytes to a revision.
+func (c *collection) BytesToRevision(b []byte) (interface{}, error) {
+	return string(b), nil
+}
+
+// As does not expose any types.
+func (c *collection) As(interface{}) bool {
+	return false
+}
+
+// ErrorAs does not expose any types.
+func (c *collection) ErrorAs(error, interface{}) bool {
+	return false
+}
+
+// ErrorCode returns the error code corresponding to the given error.
+func (c *collection) ErrorCode(err error) gcerrors.ErrorCode {
+	switch {
+	case errors.Is(err, kubestore.ErrorKeyNotFound):
+		return gcerrors.NotFound
+	case errors.Is(err, errAlreadyExists):
+		return gcerrors.AlreadyExists
+	case errors.Is(err, kubestore.ErrorConflict):
+		return gcerrors.FailedPrecondition
+	case errors.Is(err, errInvalidArgument):
+		return gcerrors.InvalidArgument
+	case errors.Is(err, kubestore.ErrorNotSupported):
+		return gcerrors.Unimplemented
+	case errors.Is(err, kubestore.ErrorValueTooLarge), errors.Is(err, kubestore.ErrorQuotaExceeded):
+		return gcerrors.ResourceExhausted
+	case errors.Is(err, context.Canceled):
+		return gcerrors.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return gcerrors.DeadlineExceeded
+	default:
+		return gcerrors.Code(err)
+	}
+}
+
+// Close does nothing, as the Store is owned by the caller.
+func (c *collection) Close() error {
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubedocstore
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/joshdk/kubestore"
+	"gocloud.dev/docstore"
+	"gocloud.dev/gcerrors"
+)
+
+type user struct {
+	ID               string
+	Name             string
+	Age              int
+	Avatar           []byte
+	Joined           time.Time
+	DocstoreRevision interface{}
+}
+
+func TestCollection(t *testing.T) {
+	ctx := context.Background()
+	store := kubestore.NewFileStore(t.TempDir())
+
+	coll, err := OpenCollection(store, "ID", &Options{Prefix: "users."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	joined := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	alice := &user{ID: "alice", Name: "Alice", Age: 30, Avatar: []byte{0, 1, 2}, Joined: joined}
+	if err := coll.Create(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	if alice.DocstoreRevision == nil {
+		t.Fatal("expected revision to be set")
+	}
+	if err := coll.Create(ctx, &user{ID: "alice"}); gcerrors.Code(err) != gcerrors.AlreadyExists {
+		t.Fatalf("expected AlreadyExists, got %v", err)
+	}
+
+	got := &user{ID: "alice"}
+	if err := coll.Get(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, alice) {
+		t.Fatalf("expected %+v, got %+v", alice, got)
+	}
+
+	// A write with a stale revision is rejected.
+	stale := *alice
+	if err := coll.Update(ctx, alice, docstore.Mods{"Age": docstore.Increment(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coll.Replace(ctx, &stale); gcerrors.Code(err) != gcerrors.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+
+	if err := coll.Put(ctx, &user{ID: "bob", Name: "Bob", Age: 25}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coll.Put(ctx, &user{ID: "carol", Name: "Carol", Age: 40}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only keys with the collection prefix are a part of the collection.
+	if err := store.Set(ctx, "other", map[string]interface{}{"ID": "other", "Age": 35}); err != nil {
+		t.Fatal(err)
+	}
+
+	iter := coll.Query().Where("Age", ">", 26).OrderBy("Age", docstore.Ascending).Get(ctx)
+	defer iter.Stop()
+	var names []string
+	for {
+		var u user
+		err := iter.Next(ctx, &u)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, u.Name)
+	}
+	if expected := []string{"Alice", "Carol"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+
+	if err := coll.Delete(ctx, &user{ID: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := coll.Get(ctx, &user{ID: "bob"}); gcerrors.Code(err) != gcerrors.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubedocstore
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshdk/kubestore"
+	"gocloud.dev/docstore/driver"
+)
+
+// RunGetQuery runs the given query by reading every document in the
+// collection, and then filtering, ordering and limiting them.
+func (c *collection) RunGetQuery(ctx context.Context, q *driver.Query) (driver.DocumentIterator, error) {
+	if q.BeforeQuery != nil {
+		if err := q.BeforeQuery(func(interface{}) bool { return false }); err != nil {
+			return nil, err
+		}
+	}
+
+	keys, err := c.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matching []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, c.opts.Prefix) {
+			matching = append(matching, key)
+		}
+	}
+
+	values, err := kubestore.GetMulti(ctx, c.store, matching)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []storedDoc
+	for _, key := range matching {
+		data, found := values[key]
+		if !found {
+			// The key was deleted after it was listed.
+			continue
+		}
+		doc, err := unmarshalDoc(data)
+		if err != nil {
+			// The key does not hold a document, so is not a part of the
+			// collection.
+			continue
+		}
+		if filtersMatch(q.Filters, doc) {
+			docs = append(docs, doc)
+		}
+	}
+
+	if q.OrderByField != "" {
+		sortDocs(docs, q.OrderByField, q.OrderAscending)
+	}
+	if q.Offset > 0 {
+		if q.Offset >= len(docs) {
+			docs = nil
+		} else {
+			docs = docs[q.Offset:]
+		}
+	}
+	if q.Limit > 0 && len(docs) > q.Limit {
+		docs = docs[:q.Limit]
+	}
+
+	// The key field is always included in the results.
+	fps := q.FieldPaths
+	if len(fps) > 0 {
+		fps = append([][]string{{c.keyField}}, fps...)
+	}
+
+	return &docIterator{docs: docs, fieldPaths: fps}, nil
+}
+
+// QueryPlan returns an empty plan, as every query reads the whole collection.
+func (c *collection) QueryPlan(*driver.Query) (string, error) {
+	return "", nil
+}
+
+// filtersMatch returns true if the given document matches every filter.
+func filtersMatch(filters []driver.Filter, doc storedDoc) bool {
+	for _, f := range filters {
+		value, found := getAtFieldPath(doc, f.FieldPath)
+		if !found {
+			return false
+		}
+		cmp, ok := compare(value, f.Value, f.Op)
+		if !ok || !applyComparison(f.Op, cmp) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyComparison returns true if the given comparison result satisfies the
+// given operator.
+func applyComparison(op string, cmp int) bool {
+	switch op {
+	case driver.EqualOp, "in":
+		return cmp == 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case "not-in":
+		return cmp != 0
+	default:
+		return false
+	}
+}
+
+// compare compares the given stored value with the given filter value, and
+// returns false if they cannot be compared. For the "in" and "not-in"
+// operators, the filter value is a slice, and 0 is returned if the stored
+// value is one of its elements.
+func compare(stored, value interface{}, op string) (int, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if cmp, ok := compare(stored, v.Index(i).Interface(), op); ok && cmp == 0 {
+				return 0, true
+			}
+		}
+		return -1, true
+	}
+
+	// Times are stored as strings, so are compared with filter times after
+	// being parsed.
+	if t, ok := value.(time.Time); ok {
+		s, ok := stored.(string)
+		if !ok {
+			return 0, false
+		}
+		st, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return 0, false
+		}
+		return driver.CompareTimes(st, t), true
+	}
+
+	s := reflect.ValueOf(stored)
+	if s.Kind() == reflect.String && v.Kind() == reflect.String {
+		return strings.Compare(s.String(), v.String()), true
+	}
+	if cmp, err := driver.CompareNumbers(s, v); err == nil {
+		return cmp, true
+	}
+	if s.Kind() == reflect.Bool && v.Kind() == reflect.Bool {
+		if s.Bool() == v.Bool() {
+			return 0, true
+		}
+		return -1, true
+	}
+	return 0, false
+}
+
+// sortDocs sorts the given documents by the given field.
+func sortDocs(docs []storedDoc, field string, ascending bool) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		cmp, ok := compare(docs[i][field], docs[j][field], ">")
+		if !ok {
+			return false
+		}
+		if ascending {
+			return cmp < 0
+		}
+		return cmp > 0
+	})
+}
+
+// docIterator iterates over the results of a query.
+type docIterator struct {
+	docs       []storedDoc
+	fieldPaths [][]string
+	err        error
+}
+
+// Next decodes the next result into the given document, or returns io.EOF if
+// there are no more results.
+func (it *docIterator) Next(_ context.Context, doc driver.Document) error {
+	if it.err != nil {
+		return it.err
+	}
+	if len(it.docs) == 0 {
+		it.err = io.EOF
+		return it.err
+	}
+	if err := decodeDoc(it.docs[0], doc, it.fieldPaths); err != nil {
+		it.err = err
+		return it.err
+	}
+	it.docs = it.docs[1:]
+	return nil
+}
+
+// Stop stops the iteration.
+func (it *docIterator) Stop() {
+	it.err = io.EOF
+}
+
+// As does not expose any types.
+func (it *docIterator) As(interface{}) bool {
+	return false
+}
@@ -0,0 +1,88 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubedocstore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/joshdk/kubestore"
+	"gocloud.dev/docstore"
+)
+
+// Scheme is the URL scheme that kubedocstore registers its URLOpener under on
+// docstore.DefaultURLMux.
+const Scheme = "kubestore"
+
+func init() {
+	docstore.DefaultURLMux().RegisterCollection(Scheme, &defaultOpener{})
+}
+
+// defaultOpener opens collections using the Store described by environment
+// variables, which is only created once it is first needed.
+type defaultOpener struct {
+	init   sync.Once
+	opener *URLOpener
+	err    error
+}
+
+// OpenCollectionURL opens the collection at the given URL, as described by
+// URLOpener.OpenCollectionURL.
+func (o *defaultOpener) OpenCollectionURL(ctx context.Context, u *url.URL) (*docstore.Collection, error) {
+	o.init.Do(func() {
+		store, err := kubestore.NewFromEnv()
+		if err != nil {
+			o.err = err
+			return
+		}
+		o.opener = &URLOpener{Store: store}
+	})
+	if o.err != nil {
+		return nil, fmt.Errorf("open collection %v: %v", u, o.err)
+	}
+	return o.opener.OpenCollectionURL(ctx, u)
+}
+
+// URLOpener opens collections with URLs of the form
+// "kubestore://<prefix>/<key field>". The URL host is used as the collection
+// prefix, followed by a ".", so that "kubestore://users/id" stores documents
+// under keys such as "users.<id>". The following query parameters are
+// supported:
+//
+//	revision_field  the name of the revision field
+type URLOpener struct {
+	// Store is the Store in which documents are stored, and is required.
+	Store kubestore.Store
+}
+
+// OpenCollectionURL opens the collection at the given URL.
+func (o *URLOpener) OpenCollectionURL(_ context.Context, u *url.URL) (*docstore.Collection, error) {
+	if o.Store == nil {
+		return nil, fmt.Errorf("open collection %v: store is required", u)
+	}
+
+	keyField := strings.TrimPrefix(u.Path, "/")
+	if keyField == "" || strings.Contains(keyField, "/") {
+		return nil, fmt.Errorf("open collection %v: invalid key field %q (must be non-empty and have no slashes)", u, keyField)
+	}
+
+	query := u.Query()
+	opts := &Options{
+		RevisionField: query.Get("revision_field"),
+	}
+	if u.Host != "" {
+		opts.Prefix = u.Host + "."
+	}
+	query.Del("revision_field")
+
+	for param := range query {
+		return nil, fmt.Errorf("open collection %v: invalid query parameter %q", u, param)
+	}
+
+	return OpenCollection(o.Store, keyField, opts)
+}
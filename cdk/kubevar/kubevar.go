@@ -0,0 +1,238 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Package kubevar provides a runtimevar driver for the Go Cloud Development
+// Kit (gocloud.dev), with variables read from the keys of a kubestore.Store.
+// Use OpenVariable to construct a *runtimevar.Variable.
+//
+// For runtimevar.OpenVariable, kubevar registers for the scheme "kubestore",
+// using URLs of the form "kubestore://<key>?decoder=string&wait=30s". The
+// default URL opener uses the Store described by environment variables, as
+// described by kubestore.NewFromEnv. To use a different Store, register a
+// URLOpener with a runtimevar.URLMux.
+package kubevar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/joshdk/kubestore"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/runtimevar"
+	"gocloud.dev/runtimevar/driver"
+)
+
+// Scheme is the URL scheme that kubevar registers its URLOpener under on
+// runtimevar.DefaultURLMux.
+const Scheme = "kubestore"
+
+func init() {
+	runtimevar.DefaultURLMux().RegisterVariable(Scheme, &defaultOpener{})
+}
+
+// defaultOpener opens variables using the Store described by environment
+// variables, which is only created once it is first needed.
+type defaultOpener struct {
+	init   sync.Once
+	opener *URLOpener
+	err    error
+}
+
+// OpenVariableURL opens the variable at the given URL, as described by
+// URLOpener.OpenVariableURL.
+func (o *defaultOpener) OpenVariableURL(ctx context.Context, u *url.URL) (*runtimevar.Variable, error) {
+	o.init.Do(func() {
+		store, err := kubestore.NewFromEnv()
+		if err != nil {
+			o.err = err
+			return
+		}
+		o.opener = &URLOpener{Store: store}
+	})
+	if o.err != nil {
+		return nil, fmt.Errorf("open variable %v: %v", u, o.err)
+	}
+	return o.opener.OpenVariableURL(ctx, u)
+}
+
+// URLOpener opens variables with URLs of the form "kubestore://<key>". The
+// following query parameters are supported:
+//
+//	decoder  the decoder to use, as given to runtimevar.DecoderByName
+//	wait     the interval at which the key is polled, such as "30s"
+type URLOpener struct {
+	// Store is the Store from which variables are read, and is required.
+	Store kubestore.Store
+
+	// Decoder is the decoder used if one is not given in the URL, which
+	// defaults to runtimevar.BytesDecoder.
+	Decoder *runtimevar.Decoder
+
+	// Options are the options used for every variable.
+	Options Options
+}
+
+// OpenVariableURL opens the variable at the given URL.
+func (o *URLOpener) OpenVariableURL(ctx context.Context, u *url.URL) (*runtimevar.Variable, error) {
+	if o.Store == nil {
+		return nil, fmt.Errorf("open variable %v: store is required", u)
+	}
+
+	query := u.Query()
+	decoder, err := runtimevar.DecoderByName(ctx, query.Get("decoder"), o.Decoder)
+	if err != nil {
+		return nil, fmt.Errorf("open variable %v: invalid decoder: %v", u, err)
+	}
+	query.Del("decoder")
+
+	opts := o.Options
+	if wait := query.Get("wait"); wait != "" {
+		if opts.WaitDuration, err = time.ParseDuration(wait); err != nil {
+			return nil, fmt.Errorf("open variable %v: invalid wait %q: %v", u, wait, err)
+		}
+	}
+	query.Del("wait")
+
+	for param := range query {
+		return nil, fmt.Errorf("open variable %v: invalid query parameter %q", u, param)
+	}
+
+	return OpenVariable(o.Store, path.Join(u.Host, u.Path), decoder, &opts)
+}
+
+// Options are the options for a variable.
+type Options struct {
+	// WaitDuration is the interval at which the key is polled, which
+	// defaults to 30 seconds.
+	WaitDuration time.Duration
+}
+
+// OpenVariable returns a *runtimevar.Variable that reads the given key of the
+// given Store, and decodes it using the given decoder.
+//
+// Values that are stored as JSON strings are given to the decoder as the
+// contents of the string, and any other value is given to the decoder as its
+// JSON encoding, which can be decoded using runtimevar.JSONDecoder.
+func OpenVariable(store kubestore.Store, key string, decoder *runtimevar.Decoder, opts *Options) (*runtimevar.Variable, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	return runtimevar.New(&watcher{
+		store:   store,
+		key:     key,
+		decoder: decoder,
+		wait:    driver.WaitDuration(opts.WaitDuration),
+	}), nil
+}
+
+// state is a single snapshot of a variable.
+type state struct {
+	value      interface{}
+	updateTime time.Time
+	raw        []byte
+	err        error
+}
+
+// Value returns the decoded value of the variable, or the error encountered
+// while reading it.
+func (s *state) Value() (interface{}, error) {
+	return s.value, s.err
+}
+
+// UpdateTime returns the time at which the variable was last observed to have
+// changed.
+func (s *state) UpdateTime() time.Time {
+	return s.updateTime
+}
+
+// As does not expose any types.
+func (s *state) As(interface{}) bool {
+	return false
+}
+
+// watcher polls a single key of a Store.
+type watcher struct {
+	store   kubestore.Store
+	key     string
+	decoder *runtimevar.Decoder
+	wait    time.Duration
+}
+
+// WatchVariable reads the key, and returns its new state, or nil if it has
+// not changed since the given previous state.
+func (w *watcher) WatchVariable(ctx context.Context, prev driver.State) (driver.State, time.Duration) {
+	var data json.RawMessage
+	if err := w.store.Get(ctx, w.key, &data); err != nil {
+		return errorState(err, prev), w.wait
+	}
+
+	// Values stored as JSON strings are decoded from their contents.
+	raw := []byte(data)
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		raw = []byte(value)
+	}
+
+	if prev, ok := prev.(*state); ok && prev.err == nil && bytes.Equal(prev.raw, raw) {
+		return nil, w.wait
+	}
+
+	decoded, err := w.decoder.Decode(ctx, raw)
+	if err != nil {
+		return errorState(err, prev), w.wait
+	}
+	return &state{value: decoded, updateTime: time.Now(), raw: raw}, w.wait
+}
+
+// errorState returns a state holding the given error, or nil if the given
+// previous state holds the same error.
+func errorState(err error, prev driver.State) driver.State {
+	if prev, ok := prev.(*state); ok && prev.err != nil && prev.err.Error() == err.Error() {
+		return nil
+	}
+	return &state{err: err}
+}
+
+// Close does nothing, as the Store is owned by the caller.
+func (w *watcher) Close() error {
+	return nil
+}
+
+// ErrorAs does not expose any types.
+func (w *watcher) ErrorAs(error, interface{}) bool {
+	return false
+}
+
+// ErrorCode returns the error code corresponding to the given error.
+func (w *watcher) ErrorCode(err error) gcerrors.ErrorCode {
+	return errorCode(err)
+}
+
+// errorCode returns the error code corresponding to the given error returned
+// by a Store.
+func errorCode(err error) gcerrors.ErrorCode {
+	switch {
+	case errors.Is(err, kubestore.ErrorKeyNotFound):
+		return gcerrors.NotFound
+	case errors.Is(err, kubestore.ErrorNotSupported):
+		return gcerrors.Unimplemented
+	case errors.Is(err, kubestore.ErrorConflict):
+		return gcerrors.FailedPrecondition
+	case errors.Is(err, kubestore.ErrorValueTooLarge), errors.Is(err, kubestore.ErrorQuotaExceeded):
+		return gcerrors.ResourceExhausted
+	case errors.Is(err, context.Canceled):
+		return gcerrors.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return gcerrors.DeadlineExceeded
+	default:
+		return gcerrors.Unknown
+	}
+}
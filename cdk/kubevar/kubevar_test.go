@@ -0,0 +1,80 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubevar
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/joshdk/kubestore"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/runtimevar"
+)
+
+func TestVariable(t *testing.T) {
+	ctx := context.Background()
+	store := kubestore.NewFileStore(t.TempDir())
+
+	v, err := OpenVariable(store, "greeting", runtimevar.StringDecoder, &Options{WaitDuration: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	// The variable does not exist yet.
+	if _, err := v.Watch(ctx); gcerrors.Code(err) != gcerrors.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	if err := store.Set(ctx, "greeting", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := v.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Value != "hello" {
+		t.Fatalf("expected %q, got %q", "hello", snapshot.Value)
+	}
+
+	if err := store.Set(ctx, "greeting", "goodbye"); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot, err = v.Watch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Value != "goodbye" {
+		t.Fatalf("expected %q, got %q", "goodbye", snapshot.Value)
+	}
+}
+
+func TestVariableJSON(t *testing.T) {
+	ctx := context.Background()
+	store := kubestore.NewFileStore(t.TempDir())
+
+	type config struct {
+		Replicas int `json:"replicas"`
+	}
+	if err := store.Set(ctx, "config", config{Replicas: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := runtimevar.NewDecoder(config{}, runtimevar.JSONDecode)
+	v, err := OpenVariable(store, "config", decoder, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	snapshot, err := v.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (config{Replicas: 3}); !reflect.DeepEqual(snapshot.Value, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, snapshot.Value)
+	}
+}
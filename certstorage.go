@@ -0,0 +1,338 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certKeyPrefix is the prefix of every Store key used by a CertStorage, and
+// certLockPrefix is the prefix of every Store key used for its locks.
+const (
+	certKeyPrefix  = "certmagic."
+	certLockPrefix = "certmagic-lock."
+)
+
+// certEntry is the stored form of a single CertStorage value.
+type certEntry struct {
+	Value    []byte    `json:"value"`
+	Modified time.Time `json:"modified"`
+}
+
+// CertKeyInfo describes a key in a CertStorage. It has the same fields as
+// the KeyInfo type from github.com/caddyserver/certmagic, and can be converted
+// to it directly.
+type CertKeyInfo struct {
+	Key        string
+	Modified   time.Time
+	Size       int64
+	IsTerminal bool
+}
+
+// CertStorage adapts a Store to the Storage interface from
+// github.com/caddyserver/certmagic, so that services embedding CertMagic or
+// Caddy can keep their certificates and ACME account data in-cluster. A Secret
+// Store is recommended, as the stored data includes private keys.
+//
+// CertStorage implements every method of the Storage interface, except that
+// Stat returns a CertKeyInfo rather than a certmagic.KeyInfo, so that this
+// package does not depend on CertMagic. It can be adapted with a wrapper:
+//
+//	type storage struct{ *kubestore.CertStorage }
+//
+//	func (s storage) Stat(ctx context.Context, key string) (certmagic.KeyInfo, error) {
+//		info, err := s.CertStorage.Stat(ctx, key)
+//		return certmagic.KeyInfo(info), err
+//	}
+//
+// Keys are hierarchical paths separated by slashes, and are escaped in order
+// to form valid Store keys. Locks are held using a Semaphore with a single
+// slot, which is renewed while held, so the Store must implement the Updater
+// interface. A lock abandoned by a process that has crashed is released once
+// LockTTL has passed.
+type CertStorage struct {
+	store Store
+
+	// LockTTL is the duration after which a lock that has not been renewed
+	// is considered to be abandoned.
+	LockTTL time.Duration
+
+	// LockPollInterval is the interval at which CertStorage.Lock retries
+	// acquiring a lock while it is held by another process.
+	LockPollInterval time.Duration
+
+	mu    sync.Mutex
+	locks map[string]certLock
+}
+
+// certLock is a lock held by a CertStorage.
+type certLock struct {
+	semaphore *Semaphore
+	token     string
+	cancel    context.CancelFunc
+}
+
+// NewCertStorage returns a CertStorage backed by the given Store.
+func NewCertStorage(store Store) *CertStorage {
+	return &CertStorage{
+		store:            store,
+		LockTTL:          time.Minute,
+		LockPollInterval: time.Second,
+		locks:            make(map[string]certLock),
+	}
+}
+
+// Store writes the given value under the given key.
+func (s *CertStorage) Store(ctx context.Context, key string, value []byte) error {
+	return s.store.Set(ctx, certStoreKey(key), certEntry{
+		Value:    value,
+		Modified: time.Now().UTC(),
+	})
+}
+
+// Load reads the value of the given key. If the key does not exist, an error
+// matching os.ErrNotExist is returned, as expected by CertMagic.
+func (s *CertStorage) Load(ctx context.Context, key string) ([]byte, error) {
+	entry, err := s.load(ctx, key)
+	return entry.Value, err
+}
+
+// Delete removes the given key, along with every key beneath it if it is a
+// directory. If no such key exists, an error matching os.ErrNotExist is
+// returned.
+func (s *CertStorage) Delete(ctx context.Context, key string) error {
+	keys, err := s.keys(ctx)
+	if err != nil {
+		return err
+	}
+
+	var deleted bool
+	for _, name := range keys {
+		if name != key && !strings.HasPrefix(name, key+"/") {
+			continue
+		}
+		if err := s.store.Delete(ctx, certStoreKey(name)); err != nil && !isKeyNotFound(err) {
+			return err
+		}
+		deleted = true
+	}
+
+	if !deleted {
+		return fmt.Errorf("%w: %s", os.ErrNotExist, key)
+	}
+	return nil
+}
+
+// Exists returns true if the given key exists, either as a value or as a
+// directory.
+func (s *CertStorage) Exists(ctx context.Context, key string) bool {
+	_, err := s.Stat(ctx, key)
+	return err == nil
+}
+
+// List returns the keys beneath the given directory. If recursive is false,
+// only the immediate children of the directory are returned, which may be
+// directories themselves. If the directory does not exist, an error matching
+// os.ErrNotExist is returned.
+func (s *CertStorage) List(ctx context.Context, path string, recursive bool) ([]string, error) {
+	keys, err := s.keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	if prefix == "/" {
+		prefix = ""
+	}
+
+	seen := make(map[string]bool)
+	var listed []string
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		// Report only the immediate child unless listing recursively.
+		if !recursive {
+			if index := strings.Index(key[len(prefix):], "/"); index >= 0 {
+				key = key[:len(prefix)+index]
+			}
+		}
+		if !seen[key] {
+			seen[key] = true
+			listed = append(listed, key)
+		}
+	}
+
+	if len(listed) == 0 {
+		return nil, fmt.Errorf("%w: %s", os.ErrNotExist, path)
+	}
+
+	sort.Strings(listed)
+	return listed, nil
+}
+
+// Stat returns information about the given key, which is terminal if it holds
+// a value, or not terminal if it is a directory. If the key does not exist,
+// an error matching os.ErrNotExist is returned.
+func (s *CertStorage) Stat(ctx context.Context, key string) (CertKeyInfo, error) {
+	entry, err := s.load(ctx, key)
+	if err == nil {
+		return CertKeyInfo{
+			Key:        key,
+			Modified:   entry.Modified,
+			Size:       int64(len(entry.Value)),
+			IsTerminal: true,
+		}, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return CertKeyInfo{}, err
+	}
+
+	// The key may instead be a directory.
+	if _, err := s.List(ctx, key, false); err != nil {
+		return CertKeyInfo{}, err
+	}
+	return CertKeyInfo{Key: key}, nil
+}
+
+// Lock blocks until the named lock is acquired, or until the given context is
+// done. The lock is renewed in the background until CertStorage.Unlock is
+// called.
+func (s *CertStorage) Lock(ctx context.Context, name string) error {
+	semaphore := NewSemaphore(s.store, certLockPrefix+escapeCertKey(name), 1, s.LockTTL)
+	semaphore.PollInterval = s.LockPollInterval
+
+	token, err := semaphore.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Renew the lock well before it would be considered abandoned.
+	renewCtx, cancel := context.WithCancel(context.Background())
+	if s.LockTTL > 0 {
+		go s.renew(renewCtx, semaphore, token)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks[name] = certLock{semaphore: semaphore, token: token, cancel: cancel}
+	return nil
+}
+
+// renew periodically renews the lock held with the given token, until the
+// given context is done.
+func (s *CertStorage) renew(ctx context.Context, semaphore *Semaphore, token string) {
+	ticker := time.NewTicker(s.LockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = semaphore.Renew(ctx, token)
+		}
+	}
+}
+
+// Unlock releases the named lock, which must have been acquired by calling
+// CertStorage.Lock.
+func (s *CertStorage) Unlock(ctx context.Context, name string) error {
+	s.mu.Lock()
+	lock, found := s.locks[name]
+	delete(s.locks, name)
+	s.mu.Unlock()
+
+	if !found {
+		return fmt.Errorf("%w: lock %s is not held", ErrorConflict, name)
+	}
+
+	lock.cancel()
+	return lock.semaphore.Release(ctx, lock.token)
+}
+
+// load reads the entry stored under the given key.
+func (s *CertStorage) load(ctx context.Context, key string) (certEntry, error) {
+	var entry certEntry
+	if err := s.store.Get(ctx, certStoreKey(key), &entry); err != nil {
+		if isKeyNotFound(err) {
+			return entry, fmt.Errorf("%w: %s", os.ErrNotExist, key)
+		}
+		return entry, err
+	}
+	return entry, nil
+}
+
+// keys returns every key stored by a CertStorage, in their unescaped form.
+func (s *CertStorage) keys(ctx context.Context) ([]string, error) {
+	names, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, certKeyPrefix) {
+			continue
+		}
+		if key, ok := unescapeCertKey(strings.TrimPrefix(name, certKeyPrefix)); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// certStoreKey returns the Store key used for the given CertStorage key.
+func certStoreKey(key string) string {
+	return certKeyPrefix + escapeCertKey(key)
+}
+
+// escapeCertKey escapes the given key so that it only contains characters
+// that are valid in a Store key. Every other byte, including underscores, is
+// replaced by an underscore followed by its value in hex.
+func escapeCertKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '.':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "_%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// unescapeCertKey reverses escapeCertKey, and returns false if the given key
+// was not escaped correctly.
+func unescapeCertKey(key string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		if key[i] != '_' {
+			b.WriteByte(key[i])
+			continue
+		}
+		if i+2 >= len(key) {
+			return "", false
+		}
+
+		c, err := strconv.ParseUint(key[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), true
+}
@@ -0,0 +1,221 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/client-go/rest"
+)
+
+// Assert that MultiClusterStore implements the Store interface.
+var _ Store = (*MultiClusterStore)(nil)
+
+// MultiClusterStore is a Store that wraps a Store in each of several clusters,
+// writing to all of them and reading with failover, so that state survives the
+// loss of any one cluster.
+type MultiClusterStore struct {
+	stores []Store
+	mirror bool
+
+	mu        sync.Mutex
+	callbacks []func(mirror Store, err error)
+}
+
+// NewMultiClusterStore returns a MultiClusterStore that wraps the given
+// stores, which are typically backed by the same object in different clusters.
+//
+// Calls to Store.Set and Store.Delete are made against every Store, and fail
+// if any of them fail. Calls to Store.Get and Store.List are made against the
+// first Store, and fail over to the next Store in order if the Kubernetes API
+// of a cluster is unavailable.
+func NewMultiClusterStore(stores ...Store) *MultiClusterStore {
+	return &MultiClusterStore{
+		stores: stores,
+	}
+}
+
+// NewMirroredStore returns a MultiClusterStore in mirror mode, which wraps the
+// given primary Store, along with the given mirrors, which are typically
+// backed by the same object in different clusters.
+//
+// Calls to Store.Set and Store.Delete fail only if they fail against the
+// primary Store. Failures against the mirrors are reported to the callbacks
+// registered with MultiClusterStore.OnMirrorError, and can be repaired using a
+// Replicator. Reads fail over as described by NewMultiClusterStore.
+func NewMirroredStore(primary Store, mirrors ...Store) *MultiClusterStore {
+	return &MultiClusterStore{
+		stores: append([]Store{primary}, mirrors...),
+		mirror: true,
+	}
+}
+
+// NewMultiClusterConfigMapStore returns a MultiClusterStore that wraps a Store
+// backed by a ConfigMap with the given name and namespace in each of the
+// clusters with the given configs, as described by NewMultiClusterStore.
+//
+// Configs for the contexts in a kubeconfig file can be loaded using the
+// k8s.io/client-go/tools/clientcmd package.
+func NewMultiClusterConfigMapStore(configs []*rest.Config, namespace, name string, opts ...Option) (*MultiClusterStore, error) {
+	return newMultiClusterStore(configs, func(config *rest.Config) (Store, error) {
+		return newConfigMapStore(config, namespace, name, opts)
+	})
+}
+
+// NewMultiClusterSecretStore returns a MultiClusterStore that wraps a Store
+// backed by a Secret with the given name and namespace in each of the clusters
+// with the given configs, as described by NewMultiClusterStore.
+//
+// Configs for the contexts in a kubeconfig file can be loaded using the
+// k8s.io/client-go/tools/clientcmd package.
+func NewMultiClusterSecretStore(configs []*rest.Config, namespace, name string, opts ...Option) (*MultiClusterStore, error) {
+	return newMultiClusterStore(configs, func(config *rest.Config) (Store, error) {
+		return newSecretStore(config, namespace, name, opts)
+	})
+}
+
+// newMultiClusterStore returns a MultiClusterStore that wraps a Store,
+// constructed with the given function, for each of the given configs.
+func newMultiClusterStore(configs []*rest.Config, fn func(config *rest.Config) (Store, error)) (*MultiClusterStore, error) {
+	stores := make([]Store, len(configs))
+	for index, config := range configs {
+		store, err := fn(config)
+		if err != nil {
+			return nil, err
+		}
+		stores[index] = store
+	}
+
+	return NewMultiClusterStore(stores...), nil
+}
+
+// OnMirrorError registers a callback that is called with the mirror and the
+// error, for every write that fails against a mirror while in mirror mode.
+// Callbacks are called synchronously, in the order that they were registered.
+func (s *MultiClusterStore) OnMirrorError(callback func(mirror Store, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+}
+
+// Get reads the named entry from the first available Store.
+func (s *MultiClusterStore) Get(ctx context.Context, key string, value interface{}) error {
+	return s.failover(func(store Store) error {
+		return store.Get(ctx, key, value)
+	})
+}
+
+// Set writes the named entry and value into every Store.
+func (s *MultiClusterStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return s.write(func(store Store) error {
+		return store.Set(ctx, key, json.RawMessage(data))
+	})
+}
+
+// List returns a list of all keys in the first available Store.
+func (s *MultiClusterStore) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := s.failover(func(store Store) error {
+		var err error
+		keys, err = store.List(ctx)
+		return err
+	})
+	return keys, err
+}
+
+// Delete removes the named entry from every Store. The ErrorKeyNotFound
+// sentinel error is only returned if the entry did not exist in any Store.
+func (s *MultiClusterStore) Delete(ctx context.Context, key string) error {
+	var mu sync.Mutex
+	var found bool
+	err := s.write(func(store Store) error {
+		err := store.Delete(ctx, key)
+		if isKeyNotFound(err) {
+			return nil
+		}
+		if err == nil {
+			mu.Lock()
+			found = true
+			mu.Unlock()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrorKeyNotFound
+	}
+	return nil
+}
+
+// Describe returns a description of the first Store.
+func (s *MultiClusterStore) Describe() Description {
+	if len(s.stores) == 0 {
+		return Description{Backend: "multicluster"}
+	}
+	return Describe(s.stores[0])
+}
+
+// failover calls the given function with each Store in order, until it does
+// not fail because the Kubernetes API was unavailable.
+func (s *MultiClusterStore) failover(fn func(store Store) error) error {
+	var err error
+	for _, store := range s.stores {
+		if err = fn(store); err == nil || !isUnavailableError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// write calls the given function concurrently with every Store, and returns
+// the first error encountered. In mirror mode, only errors encountered with
+// the primary Store are returned, and all others are reported to callbacks.
+func (s *MultiClusterStore) write(fn func(store Store) error) error {
+	errs := make([]error, len(s.stores))
+
+	var wg sync.WaitGroup
+	for index, store := range s.stores {
+		wg.Add(1)
+		go func(index int, store Store) {
+			defer wg.Done()
+			errs[index] = fn(store)
+		}(index, store)
+	}
+	wg.Wait()
+
+	if !s.mirror {
+		for index, err := range errs {
+			if err != nil {
+				return fmt.Errorf("cluster %d: %w", index, err)
+			}
+		}
+		return nil
+	}
+
+	s.mu.Lock()
+	callbacks := s.callbacks
+	s.mu.Unlock()
+	for index, err := range errs[1:] {
+		if err == nil {
+			continue
+		}
+		for _, callback := range callbacks {
+			callback(s.stores[index+1], err)
+		}
+	}
+
+	return errs[0]
+}
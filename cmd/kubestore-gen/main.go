@@ -0,0 +1,326 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Command kubestore-gen generates a typed accessor wrapper over a
+// kubestore.Store, given a struct describing a set of well-known keys.
+//
+// Each field of the struct describes a single key, named by the field's
+// kubestore struct tag, or by the field's name if there is no tag. Fields
+// tagged with "-" are skipped. For example, given:
+//
+//	//go:generate go run github.com/joshdk/kubestore/cmd/kubestore-gen -type State
+//	type State struct {
+//		RetryCount int        `kubestore:"retry-count"`
+//		Checkpoint Checkpoint `kubestore:"checkpoint"`
+//	}
+//
+// a StateStore type is generated in state_kubestore.go, with GetRetryCount,
+// SetRetryCount, DeleteRetryCount, GetCheckpoint, SetCheckpoint, and
+// DeleteCheckpoint methods. Imports that are used by the types of the fields
+// are carried over from the file that declares the struct.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// accessor describes the generated methods for a single key.
+type accessor struct {
+	// Field is the name of the struct field, which is used in the names of
+	// the generated methods.
+	Field string
+
+	// Key is the name of the key in the Store.
+	Key string
+
+	// Type is the Go type of the value.
+	Type string
+}
+
+// importSpec describes a single import of the generated file.
+type importSpec struct {
+	// Spec is the import as it appears in the source, including its name if
+	// it was imported with an explicit name.
+	Spec string
+
+	// Standard is true if the import is of a standard library package.
+	Standard bool
+}
+
+// generated is the template for the generated file.
+var generated = template.Must(template.New("generated").Parse(`// Code generated by kubestore-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+{{- range .Imports}}{{if .Standard}}
+	{{.Spec}}
+{{- end}}{{end}}
+
+	"github.com/joshdk/kubestore"
+{{- range .Imports}}{{if not .Standard}}
+	{{.Spec}}
+{{- end}}{{end}}
+)
+
+// {{.Type}}Store provides typed access to the keys described by {{.Type}}.
+type {{.Type}}Store struct {
+	store kubestore.Store
+}
+
+// New{{.Type}}Store returns a {{.Type}}Store backed by the given Store.
+func New{{.Type}}Store(store kubestore.Store) {{.Type}}Store {
+	return {{.Type}}Store{store: store}
+}
+{{range .Accessors}}
+// Get{{.Field}} reads the {{printf "%q" .Key}} key.
+func (s {{$.Type}}Store) Get{{.Field}}(ctx context.Context) ({{.Type}}, error) {
+	var value {{.Type}}
+	err := s.store.Get(ctx, {{printf "%q" .Key}}, &value)
+	return value, err
+}
+
+// Set{{.Field}} writes the {{printf "%q" .Key}} key.
+func (s {{$.Type}}Store) Set{{.Field}}(ctx context.Context, value {{.Type}}) error {
+	return s.store.Set(ctx, {{printf "%q" .Key}}, value)
+}
+
+// Delete{{.Field}} removes the {{printf "%q" .Key}} key.
+func (s {{$.Type}}Store) Delete{{.Field}}(ctx context.Context) error {
+	return s.store.Delete(ctx, {{printf "%q" .Key}})
+}
+{{end}}`))
+
+func main() {
+	if err := mainCmd(); err != nil {
+		fmt.Fprintf(os.Stderr, "kubestore-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func mainCmd() error {
+	typeName := flag.String("type", "", "name of the struct describing the keys")
+	output := flag.String("output", "", "name of the generated file (default <type>_kubestore.go)")
+	flag.Parse()
+
+	if *typeName == "" {
+		return errors.New("the -type flag is required")
+	}
+
+	directory := "."
+	if flag.NArg() > 0 {
+		directory = flag.Arg(0)
+	}
+
+	pkg, accessors, imports, err := parse(directory, *typeName)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := generated.Execute(&buf, struct {
+		Package   string
+		Type      string
+		Imports   []importSpec
+		Accessors []accessor
+	}{pkg, *typeName, imports, accessors}); err != nil {
+		return err
+	}
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	filename := *output
+	if filename == "" {
+		filename = filepath.Join(directory, strings.ToLower(*typeName)+"_kubestore.go")
+	}
+
+	return ioutil.WriteFile(filename, source, 0644)
+}
+
+// parse finds the named struct in the package in the given directory, and
+// returns the name of the package along with an accessor for each field, and
+// the imports used by the types of the fields.
+func parse(directory, typeName string) (string, []accessor, []importSpec, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, directory, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	for name, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+
+				for _, spec := range gen.Specs {
+					spec := spec.(*ast.TypeSpec)
+					if spec.Name.Name != typeName {
+						continue
+					}
+
+					structType, ok := spec.Type.(*ast.StructType)
+					if !ok {
+						return "", nil, nil, fmt.Errorf("type %s is not a struct", typeName)
+					}
+
+					accessors, err := accessorsOf(fset, structType)
+					if err != nil {
+						return "", nil, nil, err
+					}
+
+					imports, err := importsOf(file, structType)
+					return name, accessors, imports, err
+				}
+			}
+		}
+	}
+
+	return "", nil, nil, fmt.Errorf("type %s not found in %s", typeName, directory)
+}
+
+// importsOf returns the imports of the given file that are used by the types
+// of the fields of the given struct, as they appear in the source.
+func importsOf(file *ast.File, structType *ast.StructType) ([]importSpec, error) {
+	// Find the names of every package referenced by the types of the fields.
+	used := make(map[string]bool)
+	for _, field := range structType.Fields.List {
+		ast.Inspect(field.Type, func(node ast.Node) bool {
+			if selector, ok := node.(*ast.SelectorExpr); ok {
+				if ident, ok := selector.X.(*ast.Ident); ok {
+					used[ident.Name] = true
+				}
+			}
+			return true
+		})
+	}
+
+	var imports []importSpec
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		name := importName(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if !used[name] {
+			continue
+		}
+		delete(used, name)
+
+		// These packages are always imported by the generated file.
+		if spec.Name == nil && (path == "context" || path == "github.com/joshdk/kubestore") {
+			continue
+		}
+
+		// Standard library import paths do not begin with a domain name.
+		imported := importSpec{
+			Spec:     spec.Path.Value,
+			Standard: !strings.Contains(strings.Split(path, "/")[0], "."),
+		}
+		if spec.Name != nil {
+			imported.Spec = spec.Name.Name + " " + spec.Path.Value
+		}
+		imports = append(imports, imported)
+	}
+
+	for name := range used {
+		return nil, fmt.Errorf("package %s is not imported, or must be imported with an explicit name", name)
+	}
+
+	return imports, nil
+}
+
+// importName returns the conventional name of the package with the given
+// import path, which is the last element of the path without any version
+// suffix.
+func importName(path string) string {
+	elements := strings.Split(path, "/")
+	name := elements[len(elements)-1]
+
+	// Skip major version elements, such as in "example.com/module/v2".
+	if len(elements) > 1 && len(name) > 1 && name[0] == 'v' {
+		if _, err := strconv.Atoi(name[1:]); err == nil {
+			name = elements[len(elements)-2]
+		}
+	}
+
+	// Strip version suffixes, such as in "gopkg.in/yaml.v2".
+	if index := strings.Index(name, "."); index >= 0 {
+		name = name[:index]
+	}
+
+	return strings.TrimPrefix(name, "go-")
+}
+
+// accessorsOf returns an accessor for each field of the given struct.
+func accessorsOf(fset *token.FileSet, structType *ast.StructType) ([]accessor, error) {
+	var accessors []accessor
+	for _, field := range structType.Fields.List {
+		if len(field.Names) == 0 {
+			return nil, errors.New("embedded fields are not supported")
+		}
+
+		// Render the field's type as it appears in the source.
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, field.Type); err != nil {
+			return nil, err
+		}
+
+		var tag string
+		if field.Tag != nil {
+			value, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(value).Get("kubestore")
+		}
+		if tag == "-" {
+			continue
+		}
+		if tag != "" && len(field.Names) > 1 {
+			return nil, fmt.Errorf("fields %s share the key %q", field.Names, tag)
+		}
+
+		for _, name := range field.Names {
+			key := tag
+			if key == "" {
+				key = name.Name
+			}
+			accessors = append(accessors, accessor{
+				Field: strings.Title(name.Name),
+				Key:   key,
+				Type:  buf.String(),
+			})
+		}
+	}
+
+	return accessors, nil
+}
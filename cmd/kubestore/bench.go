@@ -0,0 +1,214 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// benchOps are the operations that bench can measure, in the order in which
+// they are run.
+var benchOps = []string{"set", "get", "list", "delete"}
+
+// benchResult describes the measurements of a single operation.
+type benchResult struct {
+	Op         string   `json:"op"`
+	Count      int      `json:"count"`
+	Errors     int      `json:"errors"`
+	Elapsed    duration `json:"elapsed"`
+	Throughput float64  `json:"throughput"`
+	P50        duration `json:"p50"`
+	P90        duration `json:"p90"`
+	P99        duration `json:"p99"`
+	Max        duration `json:"max"`
+}
+
+// duration is a time.Duration that is marshalled as a string, such as "1.5ms".
+type duration time.Duration
+
+// String returns the duration formatted as by time.Duration.
+func (d duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON marshals the duration as a string.
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func benchCmd(ctx context.Context, env *environment, args []string) error {
+	flags, format := newFlagSet("bench", formatTable)
+	count := flags.Int("n", 100, "number of times to run each operation")
+	concurrency := flags.Int("c", 4, "number of concurrent workers")
+	size := flags.Int("size", 1024, "size of each value in bytes")
+	prefix := flags.String("prefix", "kubestore-bench.", "prefix of the keys that are written")
+	ops := flags.String("ops", "set,get,list,delete", "comma separated operations to run")
+	if err := parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *count < 1 || *concurrency < 1 || *size < 0 {
+		return fmt.Errorf("-n and -c must be positive, and -size must not be negative")
+	}
+
+	selected := make(map[string]bool)
+	for _, op := range splitList(*ops) {
+		if !contains(benchOps, op) {
+			return fmt.Errorf("unknown operation %q", op)
+		}
+		selected[op] = true
+	}
+
+	store, err := env.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, *count)
+	for i := range keys {
+		keys[i] = *prefix + strconv.Itoa(i)
+	}
+	value := benchValue(*size)
+
+	// Every operation other than set depends on the keys existing, so they
+	// are written even if set is not being measured, and are always removed
+	// afterwards.
+	if !selected["set"] && (selected["get"] || selected["delete"]) {
+		benchRun(ctx, *count, *concurrency, func(ctx context.Context, i int) error {
+			return store.Set(ctx, keys[i], value)
+		})
+	}
+	defer func() {
+		if selected["delete"] {
+			return
+		}
+		// Intentionally ignore any errors, as this is non-essential.
+		benchRun(context.Background(), *count, *concurrency, func(ctx context.Context, i int) error {
+			return store.Delete(ctx, keys[i])
+		})
+	}()
+
+	run := map[string]func(ctx context.Context, i int) error{
+		"set": func(ctx context.Context, i int) error {
+			return store.Set(ctx, keys[i], value)
+		},
+		"get": func(ctx context.Context, i int) error {
+			var v string
+			return store.Get(ctx, keys[i], &v)
+		},
+		"list": func(ctx context.Context, _ int) error {
+			_, err := store.List(ctx)
+			return err
+		},
+		"delete": func(ctx context.Context, i int) error {
+			return store.Delete(ctx, keys[i])
+		},
+	}
+
+	var results []benchResult
+	for _, op := range benchOps {
+		if selected[op] {
+			result := benchRun(ctx, *count, *concurrency, run[op])
+			result.Op = op
+			results = append(results, result)
+		}
+	}
+
+	if *format == formatTable {
+		return printTable(env.stdout, []string{"OP", "COUNT", "ERRORS", "OPS/SEC", "P50", "P90", "P99", "MAX"}, len(results), func(i int) []string {
+			r := results[i]
+			return []string{
+				r.Op,
+				strconv.Itoa(r.Count),
+				strconv.Itoa(r.Errors),
+				strconv.FormatFloat(r.Throughput, 'f', 1, 64),
+				r.P50.String(),
+				r.P90.String(),
+				r.P99.String(),
+				r.Max.String(),
+			}
+		})
+	}
+	return printValue(env.stdout, *format, results)
+}
+
+// benchRun calls the given function the given number of times, spread across
+// the given number of concurrent workers, and measures its latency.
+func benchRun(ctx context.Context, count, concurrency int, fn func(ctx context.Context, i int) error) benchResult {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, count)
+		failures  int
+	)
+
+	indexes := make(chan int)
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				began := time.Now()
+				err := fn(ctx, i)
+				latency := time.Since(began)
+
+				mu.Lock()
+				latencies = append(latencies, latency)
+				if err != nil {
+					failures++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	return benchResult{
+		Count:      count,
+		Errors:     failures,
+		Elapsed:    duration(elapsed),
+		Throughput: float64(count) / elapsed.Seconds(),
+		P50:        duration(percentile(latencies, 50)),
+		P90:        duration(percentile(latencies, 90)),
+		P99:        duration(percentile(latencies, 99)),
+		Max:        duration(latencies[len(latencies)-1]),
+	}
+}
+
+// percentile returns the given percentile of the given sorted latencies,
+// using the nearest rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// benchValue returns a string value of the given size, which is random so
+// that it does not compress unrealistically well.
+func benchValue(size int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = alphabet[rand.Intn(len(alphabet))]
+	}
+	return string(b)
+}
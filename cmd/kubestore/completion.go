@@ -0,0 +1,156 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joshdk/kubestore"
+)
+
+// completeCommand is the hidden command used by the completion scripts. It is
+// given the words of the command line up to and including the word being
+// completed, and prints a candidate for that word on each line.
+const completeCommand = "__complete"
+
+// completeTimeout bounds how long completion waits on the Store, so that a
+// slow or unreachable cluster does not hang the shell.
+const completeTimeout = 5 * time.Second
+
+// bashCompletion is the completion script for bash, which is also used by
+// zsh through bashcompinit.
+const bashCompletion = `_kubestore() {
+	local IFS=$'\n'
+	COMPREPLY=($(kubestore __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+	if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == *:// ]]; then
+		compopt -o nospace 2>/dev/null
+	fi
+}
+complete -F _kubestore kubestore
+`
+
+// zshCompletion is the completion script for zsh.
+const zshCompletion = `autoload -U +X bashcompinit && bashcompinit
+` + bashCompletion
+
+// backendSchemes are the DSN schemes offered when completing --store.
+var backendSchemes = []string{"configmap://", "secret://", "annotation://", "file://"}
+
+// formats are the output formats offered when completing -o.
+var formats = []string{formatJSON, formatYAML, formatTable}
+
+// keyArgs are the number of leading arguments of each command that are keys,
+// where -1 means that every argument is a key.
+var keyArgs = map[string]int{
+	"get":    1,
+	"set":    1,
+	"delete": -1,
+}
+
+func completionCmd(_ context.Context, env *environment, args []string) error {
+	flags, _ := newFlagSet("completion", "")
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	switch shell := flags.Arg(0); shell {
+	case "bash":
+		_, err := fmt.Fprint(env.stdout, bashCompletion)
+		return err
+	case "zsh":
+		_, err := fmt.Fprint(env.stdout, zshCompletion)
+		return err
+	default:
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+}
+
+// completeCmd prints the completion candidates for the last of the given
+// words. Errors are not reported, as there is nowhere to show them.
+func completeCmd(ctx context.Context, env *environment, words []string) error {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	for _, candidate := range candidates(ctx, env, words) {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Fprintln(env.stdout, candidate)
+		}
+	}
+	return nil
+}
+
+// candidates returns the possible values of the last of the given words.
+func candidates(ctx context.Context, env *environment, words []string) []string {
+	// Values of flags are completed first.
+	if len(words) >= 2 {
+		switch strings.TrimLeft(words[len(words)-2], "-") {
+		case "store":
+			return backendSchemes
+		case "o":
+			return formats
+		}
+	}
+
+	// Find the command, and the position of the word being completed among
+	// its arguments.
+	var (
+		name     string
+		position int
+	)
+	for i := 0; i < len(words)-1; i++ {
+		word := words[i]
+		switch {
+		case strings.TrimLeft(word, "-") == "store" || strings.TrimLeft(word, "-") == "o":
+			// Skip the value of the flag.
+			if i+1 < len(words)-1 {
+				if name == "" {
+					env.dsn = words[i+1]
+				}
+			}
+			i++
+		case strings.HasPrefix(word, "-"):
+		case name == "":
+			name = word
+		default:
+			position++
+		}
+	}
+
+	if strings.HasPrefix(words[len(words)-1], "-") {
+		if name == "" {
+			return []string{"--store"}
+		}
+		return []string{"-o"}
+	}
+
+	switch {
+	case name == "":
+		return commandNames()
+	case name == "completion":
+		return []string{"bash", "zsh"}
+	}
+
+	if n, found := keyArgs[name]; !found || (n >= 0 && position >= n) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, completeTimeout)
+	defer cancel()
+
+	store, err := env.open(ctx)
+	if err != nil {
+		return nil
+	}
+	keys, err := kubestore.ListSorted(ctx, store)
+	if err != nil {
+		return nil
+	}
+	return keys
+}
@@ -0,0 +1,397 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Command kubestore reads and writes the keys of a kubestore.Store from the
+// command line.
+//
+// The Store is given by the --store flag as a DSN, as described by
+// kubestore.Open, or otherwise by environment variables, as described by
+// kubestore.NewFromEnv. For example:
+//
+//	kubestore --store configmap://default/settings set retry-count 3
+//	kubestore --store configmap://default/settings get retry-count
+//	kubestore --store configmap://default/settings list -o yaml
+//
+// Values given to set are stored as JSON if they are valid JSON, and are
+// otherwise stored as strings. Every command accepts a -o flag selecting the
+// output format, which is one of "json", "yaml", or "table".
+//
+// The serve command serves the Store over HTTP, as described by the httpstore
+// package, so that it can be deployed as a sidecar gateway. Every request must
+// carry the token given by -token, -token-file, or KUBESTORE_TOKEN, unless the
+// -insecure flag is given. For example:
+//
+//	kubestore --store configmap://default/settings serve -listen :8080 -token-file /etc/kubestore/token
+//
+// The bench command measures the throughput and latency of Set, Get, List,
+// and Delete, in order to compare backends. For example:
+//
+//	kubestore --store secret://default/bench bench -n 200 -c 8 -size 4096
+//
+// The stats command prints the usage of the Store against the size limit of
+// its backend, along with its largest keys and when each was last modified, in
+// order to find which component is bloating a shared Store. For example:
+//
+//	kubestore --store configmap://default/settings stats -top 5
+//
+// The managed command lists every ConfigMap and Secret created by kubestore,
+// along with the number of keys and size of each, in the given namespace or
+// otherwise across the whole cluster. For example:
+//
+//	kubestore managed default -o yaml
+//
+// Shell completion for commands, backends, and keys is enabled by evaluating
+// the output of "kubestore completion bash" or "kubestore completion zsh".
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/joshdk/kubestore"
+)
+
+// command describes a single subcommand.
+type command struct {
+	// Usage is a summary of the arguments of the command.
+	Usage string
+
+	// Help is a single line describing the command.
+	Help string
+
+	// Run runs the command with the given arguments.
+	Run func(ctx context.Context, env *environment, args []string) error
+}
+
+// commands are the subcommands, by name. They are registered by init, since
+// the commands themselves refer to this map when printing their usage.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"get": {
+			Usage: "get [-o format] KEY",
+			Help:  "print the value of a key",
+			Run:   getCmd,
+		},
+		"set": {
+			Usage: "set [-o format] KEY VALUE",
+			Help:  "set the value of a key",
+			Run:   setCmd,
+		},
+		"list": {
+			Usage: "list [-o format]",
+			Help:  "print every key",
+			Run:   listCmd,
+		},
+		"delete": {
+			Usage: "delete [-o format] KEY...",
+			Help:  "remove keys",
+			Run:   deleteCmd,
+		},
+		"managed": {
+			Usage: "managed [-o format] [NAMESPACE]",
+			Help:  "print every object managed by kubestore, with key counts and sizes",
+			Run:   managedCmd,
+		},
+		"bench": {
+			Usage: "bench [-o format] [-n count] [-c workers] [flags]",
+			Help:  "measure the throughput and latency of the store",
+			Run:   benchCmd,
+		},
+		"stats": {
+			Usage: "stats [-o format] [-top n]",
+			Help:  "print the usage of the store, and its largest keys",
+			Run:   statsCmd,
+		},
+		"serve": {
+			Usage: "serve [-listen addr] [-token token | -insecure] [flags]",
+			Help:  "serve the store over HTTP",
+			Run:   serveCmd,
+		},
+		"completion": {
+			Usage: "completion bash|zsh",
+			Help:  "print a shell completion script",
+			Run:   completionCmd,
+		},
+	}
+}
+
+// environment holds the state shared by every command.
+type environment struct {
+	// dsn is the value of the --store flag.
+	dsn string
+
+	// stdout is where command output is written.
+	stdout io.Writer
+}
+
+// open returns the Store described by the --store flag, or otherwise by
+// environment variables.
+func (e *environment) open(ctx context.Context) (kubestore.Store, error) {
+	if e.dsn != "" {
+		return kubestore.Open(ctx, e.dsn)
+	}
+	return kubestore.NewFromEnv()
+}
+
+func main() {
+	if err := mainCmd(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "kubestore: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func mainCmd(args []string) error {
+	env := &environment{stdout: os.Stdout}
+
+	flags := flag.NewFlagSet("kubestore", flag.ContinueOnError)
+	flags.StringVar(&env.dsn, "store", "", "DSN of the store (default from KUBESTORE_URL or KUBESTORE_BACKEND)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: kubestore [--store DSN] COMMAND [ARGS]\n\nCommands:\n")
+		for _, name := range commandNames() {
+			fmt.Fprintf(flags.Output(), "  %-40s %s\n", commands[name].Usage, commands[name].Help)
+		}
+		fmt.Fprintf(flags.Output(), "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("a command is required")
+	}
+
+	name := flags.Arg(0)
+	if name == completeCommand {
+		return completeCmd(context.Background(), env, flags.Args()[1:])
+	}
+	cmd, found := commands[name]
+	if !found {
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.Run(context.Background(), env, flags.Args()[1:])
+}
+
+// commandNames returns the names of the commands, sorted.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newFlagSet returns a flag set for the named command, with a -o flag that
+// defaults to the given output format, unless it is empty.
+func newFlagSet(name string, defaultFormat string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: kubestore %s\n", commands[name].Usage)
+		if defaultFormat != "" {
+			fmt.Fprintf(flags.Output(), "\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	}
+	if defaultFormat == "" {
+		return flags, nil
+	}
+	format := flags.String("o", defaultFormat, "output format, one of json, yaml, or table")
+	return flags, format
+}
+
+// parseArgs parses the given arguments, which may have flags before, after,
+// or between positional arguments, and checks that the number of positional
+// arguments is within the given bounds. A negative maximum means that there
+// is no maximum.
+func parseArgs(flags *flag.FlagSet, args []string, min, max int) error {
+	var positional []string
+	for len(args) > 0 {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			break
+		}
+		// Parsing stops after a "--", and everything after it is positional.
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+
+	// Parse the positional arguments alone, so that flags.Args returns them.
+	if err := flags.Parse(append([]string{"--"}, positional...)); err != nil {
+		return err
+	}
+	if flags.NArg() < min || (max >= 0 && flags.NArg() > max) {
+		flags.Usage()
+		return fmt.Errorf("wrong number of arguments for %s", flags.Name())
+	}
+	return nil
+}
+
+func getCmd(ctx context.Context, env *environment, args []string) error {
+	flags, format := newFlagSet("get", formatJSON)
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+
+	store, err := env.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	key := flags.Arg(0)
+	var value json.RawMessage
+	if err := store.Get(ctx, key, &value); err != nil {
+		return err
+	}
+
+	return printEntries(env.stdout, *format, []entry{{Key: key, Value: value}}, true)
+}
+
+func setCmd(ctx context.Context, env *environment, args []string) error {
+	flags, format := newFlagSet("set", formatTable)
+	if err := parseArgs(flags, args, 2, 2); err != nil {
+		return err
+	}
+
+	store, err := env.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, value := flags.Arg(0), parseValue(flags.Arg(1))
+	if err := store.Set(ctx, key, value); err != nil {
+		return err
+	}
+
+	return printResults(env.stdout, *format, []result{{Key: key, Result: "set"}})
+}
+
+// parseValue returns the given command line value as JSON if it is valid
+// JSON, or otherwise as a JSON string.
+func parseValue(value string) json.RawMessage {
+	if json.Valid([]byte(value)) {
+		return json.RawMessage(value)
+	}
+	data, _ := json.Marshal(value)
+	return data
+}
+
+func listCmd(ctx context.Context, env *environment, args []string) error {
+	flags, format := newFlagSet("list", formatTable)
+	if err := parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	store, err := env.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := kubestore.ListSorted(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	return printKeys(env.stdout, *format, keys)
+}
+
+func deleteCmd(ctx context.Context, env *environment, args []string) error {
+	flags, format := newFlagSet("delete", formatTable)
+	if err := parseArgs(flags, args, 1, -1); err != nil {
+		return err
+	}
+
+	store, err := env.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	results := make([]result, 0, flags.NArg())
+	for _, key := range flags.Args() {
+		if err := store.Delete(ctx, key); err != nil {
+			return err
+		}
+		results = append(results, result{Key: key, Result: "deleted"})
+	}
+
+	return printResults(env.stdout, *format, results)
+}
+
+func managedCmd(ctx context.Context, env *environment, args []string) error {
+	flags, format := newFlagSet("managed", formatTable)
+	if err := parseArgs(flags, args, 0, 1); err != nil {
+		return err
+	}
+
+	// An empty namespace lists objects across the whole cluster.
+	objects, err := kubestore.ListManagedObjects(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	entries := make([]entry, 0, len(objects))
+	for _, object := range objects {
+		value, err := json.Marshal(struct {
+			Keys int `json:"keys"`
+			Size int `json:"size"`
+		}{
+			Keys: object.Keys,
+			Size: object.Size,
+		})
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{
+			Key:   path.Join(object.Kind, object.Namespace, object.Name),
+			Value: value,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	return printEntries(env.stdout, *format, entries, false)
+}
+
+// splitList splits the given comma separated list, discarding empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// contains returns true if the given list contains the given item.
+func contains(list []string, item string) bool {
+	for _, candidate := range list {
+		if candidate == item {
+			return true
+		}
+	}
+	return false
+}
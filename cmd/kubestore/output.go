@@ -0,0 +1,127 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+)
+
+// The supported output formats.
+const (
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+	formatTable = "table"
+)
+
+// entry is a single key and its value.
+type entry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// result is the outcome of modifying a single key.
+type result struct {
+	Key    string `json:"key"`
+	Result string `json:"result"`
+}
+
+// printEntries prints the given entries in the given format. If valueOnly is
+// true, only the value of the single entry is printed in the json and yaml
+// formats, so that the output can be consumed directly by other tools.
+func printEntries(w io.Writer, format string, entries []entry, valueOnly bool) error {
+	if format == formatTable {
+		return printTable(w, []string{"KEY", "VALUE"}, len(entries), func(i int) []string {
+			return []string{entries[i].Key, compact(entries[i].Value)}
+		})
+	}
+	if valueOnly && len(entries) == 1 {
+		return printValue(w, format, entries[0].Value)
+	}
+	return printValue(w, format, entries)
+}
+
+// printKeys prints the given keys in the given format.
+func printKeys(w io.Writer, format string, keys []string) error {
+	if format == formatTable {
+		return printTable(w, []string{"KEY"}, len(keys), func(i int) []string {
+			return []string{keys[i]}
+		})
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	return printValue(w, format, keys)
+}
+
+// printResults prints the given results in the given format.
+func printResults(w io.Writer, format string, results []result) error {
+	if format == formatTable {
+		return printTable(w, []string{"KEY", "RESULT"}, len(results), func(i int) []string {
+			return []string{results[i].Key, results[i].Result}
+		})
+	}
+	return printValue(w, format, results)
+}
+
+// printValue prints the given value in the given format, which must be either
+// json or yaml.
+func printValue(w io.Writer, format string, value interface{}) error {
+	switch format {
+	case formatJSON:
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case formatYAML:
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// compact returns the given JSON without insignificant whitespace, so that
+// it fits on a single row of a table.
+func compact(value json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, value); err != nil {
+		return string(value)
+	}
+	return buf.String()
+}
+
+// printTable prints a table with the given headers, and the given number of
+// rows, each of which is returned by the given function.
+func printTable(w io.Writer, headers []string, rows int, row func(i int) []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	printRow(tw, headers)
+	for i := 0; i < rows; i++ {
+		printRow(tw, row(i))
+	}
+	return tw.Flush()
+}
+
+// printRow prints a single tab separated row.
+func printRow(w io.Writer, columns []string) {
+	for i, column := range columns {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, column)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,130 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joshdk/kubestore/httpstore"
+	"github.com/joshdk/kubestore/resp"
+)
+
+// shutdownTimeout bounds how long serve waits for in-flight requests once it
+// is asked to stop.
+const shutdownTimeout = 10 * time.Second
+
+func serveCmd(ctx context.Context, env *environment, args []string) error {
+	flags, _ := newFlagSet("serve", "")
+	listen := flags.String("listen", ":8080", "address on which to serve HTTP")
+	token := flags.String("token", os.Getenv("KUBESTORE_TOKEN"), "bearer token required by every HTTP request (default from KUBESTORE_TOKEN)")
+	tokenFile := flags.String("token-file", "", "file containing the bearer token")
+	insecure := flags.Bool("insecure", false, "serve without a token, allowing every request")
+	respListen := flags.String("resp-listen", "", "address on which to also serve the Redis protocol, which is unauthenticated and so requires -insecure (disabled if empty)")
+	if err := parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	if err := resolveToken(token, *tokenFile, *insecure); err != nil {
+		return err
+	}
+	if *respListen != "" && !*insecure {
+		return errors.New("the Redis protocol has no authentication, so -resp-listen requires -insecure")
+	}
+	if *token == "" {
+		log.Printf("warning: serving without a token, so every request is allowed")
+	}
+
+	store, err := env.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Stop serving once interrupted or terminated.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	errs := make(chan error, 2)
+
+	// The Redis protocol has no means of authentication, so it is only
+	// served when explicitly enabled, and when serving insecurely.
+	if *respListen != "" {
+		go func() {
+			log.Printf("serving Redis protocol on %s", *respListen)
+			err := resp.NewServer(store).ListenAndServe(ctx, *respListen)
+			if errors.Is(err, context.Canceled) {
+				err = nil
+			}
+			errs <- err
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", httpstore.NewHandler(store, *token))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	server := &http.Server{Addr: *listen, Handler: mux}
+	go func() {
+		log.Printf("serving HTTP on %s", *listen)
+		err := server.ListenAndServe()
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		errs <- err
+	}()
+
+	// Wait for either server to fail, or to be asked to stop.
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+	if shutdownErr := server.Shutdown(shutdownCtx); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// resolveToken reads the token from the given file, if any, into the given
+// token. An error is returned if no token is configured, unless serving
+// without a token is explicitly allowed.
+func resolveToken(token *string, tokenFile string, insecure bool) error {
+	if tokenFile != "" {
+		data, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return err
+		}
+		*token = strings.TrimSpace(string(data))
+	}
+
+	if *token == "" && !insecure {
+		return errors.New("a token is required, given by -token, -token-file, or KUBESTORE_TOKEN, unless -insecure is given")
+	}
+	return nil
+}
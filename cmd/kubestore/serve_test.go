@@ -0,0 +1,43 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeRequiresToken(t *testing.T) {
+	env := &environment{dsn: "file://" + t.TempDir(), stdout: ioutil.Discard}
+
+	err := serveCmd(context.Background(), env, []string{"-listen", "127.0.0.1:0", "-token", ""})
+	if err == nil || !strings.Contains(err.Error(), "token is required") {
+		t.Fatalf("expected a missing token error, got %v", err)
+	}
+}
+
+func TestServeInsecure(t *testing.T) {
+	env := &environment{dsn: "file://" + t.TempDir(), stdout: ioutil.Discard}
+
+	// Serving without a token is allowed when explicitly requested, and stops
+	// once the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := serveCmd(ctx, env, []string{"-listen", "127.0.0.1:0", "-token", "", "-insecure"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeRESPRequiresInsecure(t *testing.T) {
+	env := &environment{dsn: "file://" + t.TempDir(), stdout: ioutil.Discard}
+
+	err := serveCmd(context.Background(), env, []string{"-listen", "127.0.0.1:0", "-token", "secret", "-resp-listen", "127.0.0.1:0"})
+	if err == nil || !strings.Contains(err.Error(), "requires -insecure") {
+		t.Fatalf("expected an insecure error, got %v", err)
+	}
+}
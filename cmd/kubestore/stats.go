@@ -0,0 +1,112 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/joshdk/kubestore"
+)
+
+// statsResult is the usage of a store, along with its largest keys.
+type statsResult struct {
+	Store    string     `json:"store"`
+	Keys     int        `json:"keys"`
+	Bytes    int        `json:"bytes"`
+	Limit    int        `json:"limit,omitempty"`
+	Headroom int        `json:"headroom"`
+	Top      []statsKey `json:"top"`
+}
+
+// statsKey is the size of a single key, and when it was last modified.
+type statsKey struct {
+	Key      string     `json:"key"`
+	Size     int        `json:"size"`
+	Modified *time.Time `json:"modified,omitempty"`
+}
+
+func statsCmd(ctx context.Context, env *environment, args []string) error {
+	flags, format := newFlagSet("stats", formatTable)
+	top := flags.Int("top", 10, "number of the largest keys to print, or 0 for every key")
+	if err := parseArgs(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *top < 0 {
+		return fmt.Errorf("-top must not be negative")
+	}
+
+	store, err := env.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	usage, err := kubestore.Usage(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	// Modification times are only printed for stores that report them.
+	modTimes, err := kubestore.ModTimes(ctx, store)
+	if err != nil && !errors.Is(err, kubestore.ErrorNotSupported) {
+		return err
+	}
+
+	result := statsResult{
+		Store:    kubestore.Describe(store).String(),
+		Keys:     usage.Keys,
+		Bytes:    usage.Bytes,
+		Limit:    usage.Limit,
+		Headroom: usage.Headroom,
+		Top:      make([]statsKey, 0, len(usage.Sizes)),
+	}
+	for key, size := range usage.Sizes {
+		stat := statsKey{Key: key, Size: size}
+		if modified, found := modTimes[key]; found {
+			stat.Modified = &modified
+		}
+		result.Top = append(result.Top, stat)
+	}
+
+	// Order keys by size, largest first, and then by name.
+	sort.Slice(result.Top, func(i, j int) bool {
+		if result.Top[i].Size != result.Top[j].Size {
+			return result.Top[i].Size > result.Top[j].Size
+		}
+		return result.Top[i].Key < result.Top[j].Key
+	})
+	if *top > 0 && len(result.Top) > *top {
+		result.Top = result.Top[:*top]
+	}
+
+	if *format != formatTable {
+		return printValue(env.stdout, *format, result)
+	}
+
+	limit, headroom := "-", "-"
+	if result.Limit > 0 {
+		limit, headroom = strconv.Itoa(result.Limit), strconv.Itoa(result.Headroom)
+	}
+	err = printTable(env.stdout, []string{"STORE", "KEYS", "BYTES", "LIMIT", "HEADROOM"}, 1, func(int) []string {
+		return []string{result.Store, strconv.Itoa(result.Keys), strconv.Itoa(result.Bytes), limit, headroom}
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(env.stdout)
+	return printTable(env.stdout, []string{"KEY", "SIZE", "MODIFIED"}, len(result.Top), func(i int) []string {
+		stat := result.Top[i]
+		modified := "-"
+		if stat.Modified != nil {
+			modified = stat.Modified.UTC().Format(time.RFC3339)
+		}
+		return []string{stat.Key, strconv.Itoa(stat.Size), modified}
+	})
+}
@@ -0,0 +1,179 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Config describes a Store, along with the wrappers that it is composed with,
+// so that the behavior of a Store can be changed without recompiling.
+type Config struct {
+	// URL is the DSN of the backing Store, as described by Open.
+	URL string `json:"url"`
+
+	// AnnotationChunkSize configures the WithAnnotationChunking option.
+	AnnotationChunkSize int `json:"annotationChunkSize,omitempty"`
+
+	// JSONPatch configures the WithJSONPatch option.
+	JSONPatch bool `json:"jsonPatch,omitempty"`
+
+	// MaxKeys configures the WithMaxKeys option.
+	MaxKeys int `json:"maxKeys,omitempty"`
+
+	// Decoding configures how values are decoded when read.
+	Decoding *DecodingConfig `json:"decoding,omitempty"`
+
+	// HMACKeyFile is the path of a file containing a base64 encoded key,
+	// which configures the WithHMAC option.
+	HMACKeyFile string `json:"hmacKeyFile,omitempty"`
+
+	// Retry configures a RetryStore.
+	Retry *RetryConfig `json:"retry,omitempty"`
+
+	// Encryption configures an EncryptedStore.
+	Encryption *EncryptionConfig `json:"encryption,omitempty"`
+
+	// Cache configures a CacheStore.
+	Cache *CacheConfig `json:"cache,omitempty"`
+}
+
+// DecodingConfig configures how values are decoded when read.
+type DecodingConfig struct {
+	// UseNumber configures the WithUseNumber option.
+	UseNumber bool `json:"useNumber,omitempty"`
+
+	// DisallowUnknownFields configures the WithDisallowUnknownFields option.
+	DisallowUnknownFields bool `json:"disallowUnknownFields,omitempty"`
+}
+
+// RetryConfig configures a RetryStore.
+type RetryConfig struct {
+	// Attempts is the maximum number of attempts made at each operation.
+	Attempts int `json:"attempts"`
+
+	// Backoff is the initial delay between attempts.
+	Backoff metav1.Duration `json:"backoff"`
+}
+
+// EncryptionConfig configures an EncryptedStore.
+type EncryptionConfig struct {
+	// KeyFiles are the paths of files containing base64 encoded keys. The
+	// first key is used for encrypting values, and any other keys are only
+	// used for decrypting values.
+	KeyFiles []string `json:"keyFiles"`
+}
+
+// CacheConfig configures a CacheStore.
+type CacheConfig struct {
+	// MaxEntries is the maximum number of values that are cached.
+	MaxEntries int `json:"maxEntries"`
+
+	// TTL is the duration for which values are cached.
+	TTL metav1.Duration `json:"ttl"`
+
+	// MaxBytes configures the WithMaxBytes option.
+	MaxBytes int `json:"maxBytes,omitempty"`
+
+	// NegativeTTL configures the WithNegativeCache option.
+	NegativeTTL metav1.Duration `json:"negativeTTL,omitempty"`
+
+	// Revalidate configures the WithRevalidation option.
+	Revalidate bool `json:"revalidate,omitempty"`
+}
+
+// BuildFromConfig returns a Store described by the given YAML or JSON encoded
+// Config.
+func BuildFromConfig(ctx context.Context, data []byte) (Store, error) {
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, err
+	}
+	return Build(ctx, config)
+}
+
+// Build returns a Store described by the given Config. The backing Store is
+// wrapped with a RetryStore, followed by an EncryptedStore, followed by a
+// CacheStore, when configured.
+func Build(ctx context.Context, config Config) (Store, error) {
+	var opts []Option
+	if config.AnnotationChunkSize > 0 {
+		opts = append(opts, WithAnnotationChunking(config.AnnotationChunkSize))
+	}
+	if config.JSONPatch {
+		opts = append(opts, WithJSONPatch())
+	}
+	if config.MaxKeys > 0 {
+		opts = append(opts, WithMaxKeys(config.MaxKeys))
+	}
+	if config.Decoding != nil && config.Decoding.UseNumber {
+		opts = append(opts, WithUseNumber())
+	}
+	if config.Decoding != nil && config.Decoding.DisallowUnknownFields {
+		opts = append(opts, WithDisallowUnknownFields())
+	}
+	if config.HMACKeyFile != "" {
+		key, err := readKeyFile(config.HMACKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithHMAC(key))
+	}
+
+	store, err := Open(ctx, config.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Retry != nil && config.Retry.Attempts > 0 {
+		store = NewRetryStore(store, config.Retry.Attempts, config.Retry.Backoff.Duration)
+	}
+
+	if config.Encryption != nil && len(config.Encryption.KeyFiles) > 0 {
+		keys := make([][]byte, 0, len(config.Encryption.KeyFiles))
+		for _, filename := range config.Encryption.KeyFiles {
+			key, err := readKeyFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+
+		if store, err = NewEncryptedStore(store, keys[0], keys[1:]...); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Cache != nil {
+		var cacheOpts []Option
+		if config.Cache.NegativeTTL.Duration > 0 {
+			cacheOpts = append(cacheOpts, WithNegativeCache(config.Cache.NegativeTTL.Duration))
+		}
+		if config.Cache.MaxBytes > 0 {
+			cacheOpts = append(cacheOpts, WithMaxBytes(config.Cache.MaxBytes))
+		}
+		if config.Cache.Revalidate {
+			cacheOpts = append(cacheOpts, WithRevalidation())
+		}
+		store = NewCacheStore(store, config.Cache.MaxEntries, config.Cache.TTL.Duration, cacheOpts...)
+	}
+
+	return store, nil
+}
+
+// readKeyFile reads the base64 encoded key from the named file.
+func readKeyFile(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+}
@@ -13,6 +13,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 )
 
@@ -24,8 +25,17 @@ type configMapPatch struct {
 var _ Store = configMapStore{}
 
 type configMapStore struct {
-	client v1.ConfigMapInterface
-	name   string
+	client    v1.ConfigMapInterface
+	metadata  metadata.ResourceInterface
+	namespace string
+	name      string
+
+	// getOptions are the options used when reading the backing ConfigMap.
+	getOptions metav1.GetOptions
+
+	// original is the name that the Store was constructed with, if it was
+	// normalized in order to be a valid ConfigMap name.
+	original string
 }
 
 // NewConfigMapStore returns a Store backed by a ConfigMap with the given name.
@@ -38,7 +48,12 @@ type configMapStore struct {
 // ConfigMap as it will be created on-demand when calling Store.Set and
 // automatically deleted when calling Store.Delete (in the event that it is
 // empty).
-func NewConfigMapStore(name string) (Store, error) {
+//
+// A name which is not a valid ConfigMap name, such as one that is too long or
+// contains uppercase characters, is normalized and suffixed with a hash, and
+// the original name is preserved in the kubestore/name annotation. Use the
+// WithStrictNames option to return an InvalidNameError instead.
+func NewConfigMapStore(name string, opts ...Option) (Store, error) {
 	// Lookup the current pod's service account details.
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -51,6 +66,15 @@ func NewConfigMapStore(name string) (Store, error) {
 		return nil, err
 	}
 
+	return newConfigMapStore(config, namespace, name, opts)
+}
+
+// newConfigMapStore returns a Store backed by a ConfigMap with the given name
+// in the given namespace.
+func newConfigMapStore(config *rest.Config, namespace, name string, opts []Option) (Store, error) {
+	o := newOptions(opts)
+	config = impersonatedConfig(config, o)
+
 	// Create a set of Kubernetes clients.
 	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -60,19 +84,38 @@ func NewConfigMapStore(name string) (Store, error) {
 	// We're only interested in the ConfigMaps client.
 	client := clientSet.CoreV1().ConfigMaps(namespace)
 
-	return &configMapStore{
-		client: client,
-		name:   name,
-	}, nil
+	// Create a metadata client, for cheaply checking the resource version of
+	// the backing ConfigMap.
+	metadataClient, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Normalize the name of the backing ConfigMap, if necessary.
+	name, original, err := resolveName(name, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrap(&configMapStore{
+		client:     client,
+		metadata:   metadataClient.Resource(apiv1.SchemeGroupVersion.WithResource("configmaps")).Namespace(namespace),
+		namespace:  namespace,
+		name:       name,
+		original:   original,
+		getOptions: o.getOptions(),
+	}, o), nil
 }
 
 // create is a helper for creating the backing ConfigMap.
 func (c configMapStore) create(ctx context.Context) error {
 	_, err := c.client.Create(ctx, &apiv1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: c.name,
+			Name:        c.name,
+			Labels:      managedLabels(),
+			Annotations: nameAnnotations(c.original),
 		},
-	}, metav1.CreateOptions{})
+	}, createOptions(ctx))
 	return err
 }
 
@@ -88,7 +131,7 @@ func (c configMapStore) delete(ctx context.Context) error {
 // is returned.
 func (c configMapStore) Get(ctx context.Context, key string, value interface{}) error {
 	// Use the Kuberneties API to get the backing ConfigMap.
-	configMap, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	configMap, err := c.client.Get(ctx, c.name, c.getOptions)
 	if err != nil {
 		// If the backing ConfigMap does not exist, then the key also does not
 		// exist, so return the not found sentinel error.
@@ -135,7 +178,7 @@ func (c configMapStore) Set(ctx context.Context, key string, value interface{})
 	}
 
 	// Use the Kuberneties API to patch the backing ConfigMap.
-	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, metav1.PatchOptions{})
+	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
 	if err != nil {
 		if isResourceMissingError(err) {
 			// If the backing ConfigMap does not exist, then create it
@@ -158,7 +201,7 @@ func (c configMapStore) Set(ctx context.Context, key string, value interface{})
 // If the backing ConfigMap does not exist, no keys are returned.
 func (c configMapStore) List(ctx context.Context) ([]string, error) {
 	// Use the Kuberneties API to get the backing ConfigMap.
-	configMap, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	configMap, err := c.client.Get(ctx, c.name, c.getOptions)
 	if err != nil {
 		// If the backing ConfigMap does not exist, then the keys also no not
 		// exist, so return an empty (nil) slice.
@@ -199,7 +242,7 @@ func (c configMapStore) Delete(ctx context.Context, key string) error {
 	}
 
 	// Use the Kuberneties API to patch the backing ConfigMap.
-	configMap, err := c.client.Patch(ctx, c.name, types.MergePatchType, payload, metav1.PatchOptions{})
+	configMap, err := c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
 	if err != nil {
 		// If the backing ConfigMap does not exist, then the key also does not
 		// exist, so there's nothing else to do.
@@ -0,0 +1,78 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Conflict describes a key that was changed locally by a FallbackStore while
+// degraded, and was also changed in the primary Store in the interim.
+type Conflict struct {
+	// Key is the name of the conflicting key.
+	Key string
+
+	// Primary is the current value in the primary Store, or nil if the key
+	// does not exist there.
+	Primary json.RawMessage
+
+	// Local is the value that was set locally, or nil if the key was deleted
+	// locally.
+	Local json.RawMessage
+
+	// Modified is the time that the local change was made.
+	Modified time.Time
+}
+
+// ConflictPolicy resolves a Conflict, returning the value that both the
+// primary Store and the local Store should converge on, or nil if the key
+// should be deleted from both. Custom policies, such as merging both values,
+// may be provided as well.
+type ConflictPolicy func(ctx context.Context, conflict Conflict) (json.RawMessage, error)
+
+// PreferPrimary is a ConflictPolicy that discards the local change in favor
+// of the value in the primary Store.
+func PreferPrimary(_ context.Context, conflict Conflict) (json.RawMessage, error) {
+	return conflict.Primary, nil
+}
+
+// PreferLocal is a ConflictPolicy that overwrites the value in the primary
+// Store with the local change. This is the default behavior of a
+// FallbackStore.
+func PreferLocal(_ context.Context, conflict Conflict) (json.RawMessage, error) {
+	return conflict.Local, nil
+}
+
+// LastWriteWins returns a ConflictPolicy that keeps whichever value was
+// written most recently, as determined by the given function which extracts a
+// timestamp from a value. A local deletion is timestamped with the time that
+// it was made. A deletion from the primary Store has no timestamp, and so
+// always loses to a local change.
+func LastWriteWins(timestamp func(value json.RawMessage) (time.Time, error)) ConflictPolicy {
+	return func(_ context.Context, conflict Conflict) (json.RawMessage, error) {
+		if conflict.Primary == nil {
+			return conflict.Local, nil
+		}
+
+		primary, err := timestamp(conflict.Primary)
+		if err != nil {
+			return nil, err
+		}
+
+		local := conflict.Modified
+		if conflict.Local != nil {
+			if local, err = timestamp(conflict.Local); err != nil {
+				return nil, err
+			}
+		}
+
+		if primary.After(local) {
+			return conflict.Primary, nil
+		}
+		return conflict.Local, nil
+	}
+}
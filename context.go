@@ -0,0 +1,112 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"time"
+)
+
+// Assert that defaultContextStore implements the Store interface.
+var _ Store = defaultContextStore{}
+
+type defaultContextStore struct {
+	store   Store
+	base    context.Context
+	timeout time.Duration
+}
+
+// NewDefaultContextStore returns a Store that wraps the given Store, and bounds
+// operations that are called with a context that can never be cancelled, such
+// as context.Background or context.TODO.
+//
+// Such operations instead use a context derived from the given base context,
+// such as one that is cancelled when the process is shutting down, along with
+// the given timeout if it is non-zero. Any values carried by the original
+// context are preserved. Operations called with a context that can be
+// cancelled are passed through as-is.
+func NewDefaultContextStore(store Store, base context.Context, timeout time.Duration) Store {
+	return &defaultContextStore{
+		store:   store,
+		base:    base,
+		timeout: timeout,
+	}
+}
+
+// bound returns the context that should be used in place of the given
+// context, along with a function to release its resources.
+func (s defaultContextStore) bound(ctx context.Context) (context.Context, context.CancelFunc) {
+	// A context that can be cancelled was deliberately configured by the
+	// caller, so it is used as-is.
+	if ctx.Done() != nil {
+		return ctx, func() {}
+	}
+
+	base, cancel := s.base, context.CancelFunc(func() {})
+	if s.timeout > 0 {
+		base, cancel = context.WithTimeout(base, s.timeout)
+	}
+
+	return valueContext{Context: base, values: ctx}, cancel
+}
+
+// Get calls Store.Get on the wrapped Store with a bounded context.
+func (s defaultContextStore) Get(ctx context.Context, key string, value interface{}) error {
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	return s.store.Get(ctx, key, value)
+}
+
+// Set calls Store.Set on the wrapped Store with a bounded context.
+func (s defaultContextStore) Set(ctx context.Context, key string, value interface{}) error {
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	return s.store.Set(ctx, key, value)
+}
+
+// List calls Store.List on the wrapped Store with a bounded context.
+func (s defaultContextStore) List(ctx context.Context) ([]string, error) {
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	return s.store.List(ctx)
+}
+
+// Delete calls Store.Delete on the wrapped Store with a bounded context.
+func (s defaultContextStore) Delete(ctx context.Context, key string) error {
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	return s.store.Delete(ctx, key)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s defaultContextStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s defaultContextStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// valueContext is a context that takes its deadline and cancellation from the
+// embedded context, but prefers the values from another context.
+type valueContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valueContext) Value(key interface{}) interface{} {
+	if value := c.values.Value(key); value != nil {
+		return value
+	}
+	return c.Context.Value(key)
+}
+
+// Update calls Update on the wrapped Store with a bounded context.
+func (s defaultContextStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	return Update(ctx, s.store, key, fn)
+}
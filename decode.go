@@ -0,0 +1,85 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// Assert that decodeStore implements the Store interface.
+var _ Store = decodeStore{}
+
+type decodeStore struct {
+	store                 Store
+	useNumber             bool
+	disallowUnknownFields bool
+}
+
+// Get reads the named entry from the wrapped Store, and decodes it into the
+// given value pointer using the configured decoding behaviors.
+//
+// There is no option for rejecting data that follows the JSON value, as every
+// Store already rejects such values when reading them from the backing medium.
+func (s decodeStore) Get(ctx context.Context, key string, value interface{}) error {
+	var data json.RawMessage
+	if err := s.store.Get(ctx, key, &data); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if s.useNumber {
+		decoder.UseNumber()
+	}
+	if s.disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	// Decode the JSON data into the given value pointer.
+	return decoder.Decode(value)
+}
+
+// Set writes the named entry and value into the wrapped Store.
+func (s decodeStore) Set(ctx context.Context, key string, value interface{}) error {
+	return s.store.Set(ctx, key, value)
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s decodeStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s decodeStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// GetMulti reads the given keys from the wrapped Store. The values are not
+// decoded, and so are returned as-is.
+func (s decodeStore) GetMulti(ctx context.Context, keys []string) (map[string]json.RawMessage, error) {
+	return GetMulti(ctx, s.store, keys)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s decodeStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s decodeStore) resourceVersion(ctx context.Context) (string, error) {
+	return resourceVersionOf(ctx, s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s decodeStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// Update atomically modifies the named entry in the wrapped Store.
+func (s decodeStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return Update(ctx, s.store, key, fn)
+}
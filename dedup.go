@@ -0,0 +1,217 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// dedupEnvelope is the stored form of a value, which either holds the value
+// itself, or a reference to a shared blob holding the value.
+type dedupEnvelope struct {
+	Value json.RawMessage `json:"value,omitempty"`
+	Blob  string          `json:"blob,omitempty"`
+}
+
+// dedupBlob is the stored form of a shared blob, along with the number of
+// keys that reference it.
+type dedupBlob struct {
+	Refs  int             `json:"refs"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Assert that DedupStore implements the Store interface.
+var _ Store = (*DedupStore)(nil)
+
+// DedupStore is a Store that wraps another Store, and stores large values
+// that are identical across keys only once.
+type DedupStore struct {
+	store     Store
+	blobs     Store
+	threshold int
+}
+
+// NewDedupStore returns a DedupStore that wraps the given Store, and stores
+// every value that is at least the given number of bytes in the given blobs
+// Store, keyed by the SHA-256 hash of the value. Keys hold a reference to the
+// blob in place of the value, so many keys that share the same large value
+// only consume the space of a single copy.
+//
+// Each blob tracks the number of keys that reference it, and is removed once
+// it is no longer referenced. Blobs are referenced before a key is written,
+// and released after, so an interrupted write may leave a blob referenced
+// more times than necessary, but never leaves a key referencing a missing
+// blob. Both Stores should implement the Updater interface, so that reference
+// counts are modified atomically.
+func NewDedupStore(store, blobs Store, threshold int) *DedupStore {
+	return &DedupStore{
+		store:     store,
+		blobs:     blobs,
+		threshold: threshold,
+	}
+}
+
+// Get reads the named entry from the wrapped Store, along with its blob if it
+// references one, and stores the contents into the given value pointer.
+func (s *DedupStore) Get(ctx context.Context, key string, value interface{}) error {
+	var envelope dedupEnvelope
+	if err := s.store.Get(ctx, key, &envelope); err != nil {
+		return err
+	}
+
+	data := envelope.Value
+	if envelope.Blob != "" {
+		var blob dedupBlob
+		if err := s.blobs.Get(ctx, envelope.Blob, &blob); err != nil {
+			if errors.Is(err, ErrorKeyNotFound) {
+				return fmt.Errorf("key %s references missing blob %s", key, envelope.Blob)
+			}
+			return err
+		}
+		data = blob.Value
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(data, value)
+}
+
+// Set writes the named entry and value into the wrapped Store. Values that are
+// at least the configured size are stored in a shared blob.
+func (s *DedupStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	envelope := dedupEnvelope{Value: data}
+	if len(data) >= s.threshold {
+		sum := sha256.Sum256(data)
+		envelope = dedupEnvelope{Blob: hex.EncodeToString(sum[:])}
+
+		if err := s.acquire(ctx, envelope.Blob, data); err != nil {
+			return err
+		}
+	}
+
+	encoded, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	previous, err := s.replace(ctx, key, encoded)
+	if err != nil {
+		// Release the blob that was referenced in anticipation. Any errors
+		// are disregarded, as the original error is more relevant.
+		if envelope.Blob != "" {
+			_ = s.release(ctx, envelope.Blob)
+		}
+		return err
+	}
+
+	// Release the blob that the entry previously referenced.
+	if previous != "" {
+		return s.release(ctx, previous)
+	}
+	return nil
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s *DedupStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store, and releases its blob
+// if it references one.
+func (s *DedupStore) Delete(ctx context.Context, key string) error {
+	var found bool
+	previous, err := s.modify(ctx, key, func(current json.RawMessage) (json.RawMessage, error) {
+		found = current != nil
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrorKeyNotFound
+	}
+
+	if previous != "" {
+		return s.release(ctx, previous)
+	}
+	return nil
+}
+
+// Describe returns a description of the wrapped Store.
+func (s *DedupStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// replace atomically writes the given encoded envelope into the named entry,
+// and returns the blob that the entry previously referenced, if any.
+func (s *DedupStore) replace(ctx context.Context, key string, encoded json.RawMessage) (string, error) {
+	return s.modify(ctx, key, func(json.RawMessage) (json.RawMessage, error) {
+		return encoded, nil
+	})
+}
+
+// modify atomically modifies the named entry with the given function, and
+// returns the blob that the entry previously referenced, if any.
+func (s *DedupStore) modify(ctx context.Context, key string, fn UpdateFunc) (string, error) {
+	var previous string
+	err := updateOrSet(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		// Record the blob from the latest attempt, as the function is called
+		// again if the entry was concurrently modified.
+		previous = ""
+		if current != nil {
+			var envelope dedupEnvelope
+			if err := json.Unmarshal(current, &envelope); err != nil {
+				return nil, err
+			}
+			previous = envelope.Blob
+		}
+		return fn(current)
+	})
+	return previous, err
+}
+
+// acquire adds a reference to the named blob, creating it with the given data
+// if it does not exist.
+func (s *DedupStore) acquire(ctx context.Context, hash string, data json.RawMessage) error {
+	return updateOrSet(ctx, s.blobs, hash, func(current json.RawMessage) (json.RawMessage, error) {
+		blob := dedupBlob{Value: data}
+		if current != nil {
+			if err := json.Unmarshal(current, &blob); err != nil {
+				return nil, err
+			}
+		}
+		blob.Refs++
+		return json.Marshal(blob)
+	})
+}
+
+// release removes a reference to the named blob, removing the blob once it is
+// no longer referenced.
+func (s *DedupStore) release(ctx context.Context, hash string) error {
+	return updateOrSet(ctx, s.blobs, hash, func(current json.RawMessage) (json.RawMessage, error) {
+		if current == nil {
+			return nil, nil
+		}
+
+		var blob dedupBlob
+		if err := json.Unmarshal(current, &blob); err != nil {
+			return nil, err
+		}
+		if blob.Refs--; blob.Refs <= 0 {
+			return nil, nil
+		}
+		return json.Marshal(blob)
+	})
+}
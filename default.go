@@ -0,0 +1,37 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"os"
+	"path/filepath"
+
+	"k8s.io/client-go/rest"
+)
+
+// NewDefault returns a Store with the given name that is suitable for the
+// current environment.
+//
+// When running inside of a pod with a service account, a Store backed by a
+// ConfigMap with the given name is returned. Otherwise, a Store backed by
+// files in a directory with the given name, under a kubestore directory in
+// the user's configuration directory (such as ~/.config/kubestore/<name> on
+// Linux) is returned, so that applications embedding a Store can run locally
+// without Kubernetes.
+func NewDefault(name string, opts ...Option) (Store, error) {
+	// Check for the current pod's service account details.
+	if _, err := rest.InClusterConfig(); err == nil {
+		return NewConfigMapStore(name, opts...)
+	}
+
+	// Fallback to the temporary directory if there is no configuration
+	// directory, such as when $HOME is unset.
+	base, err := os.UserConfigDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+
+	return NewFileStore(filepath.Join(base, "kubestore", name), opts...), nil
+}
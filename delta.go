@@ -0,0 +1,506 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// deltaSeparator separates the name of a key from the generation and sequence
+// number of each of its deltas, as in "<key>.delta.<generation>.<sequence>".
+const deltaSeparator = ".delta."
+
+// deltaSnapshot is the stored form of a full snapshot of a value.
+type deltaSnapshot struct {
+	Generation int             `json:"generation"`
+	Value      json.RawMessage `json:"value"`
+}
+
+// Assert that deltaStore implements the Store and Updater interfaces.
+var (
+	_ Store   = deltaStore{}
+	_ Updater = deltaStore{}
+)
+
+type deltaStore struct {
+	store         Store
+	snapshotEvery int
+}
+
+// deltaState is the state of an entry, as read from the wrapped Store.
+type deltaState struct {
+	// raw is the stored snapshot, or nil if the entry does not exist.
+	raw json.RawMessage
+
+	// snapshot is the decoded snapshot.
+	snapshot deltaSnapshot
+
+	// document is the value reconstructed by applying every delta to the
+	// snapshot.
+	document interface{}
+
+	// current are the keys of every delta that was applied to the snapshot,
+	// and stale are the keys of any deltas from other generations.
+	current, stale []string
+}
+
+// deltaKey returns the key under which the given delta of the given key is
+// stored.
+func deltaKey(key string, generation, sequence int) string {
+	return key + deltaSeparator + strconv.Itoa(generation) + "." + strconv.Itoa(sequence)
+}
+
+// parseDeltaKey splits the given key into the key that it is a delta of, and
+// the generation and sequence number of the delta.
+func parseDeltaKey(key string) (string, int, int, bool) {
+	index := strings.LastIndex(key, deltaSeparator)
+	if index < 0 {
+		return "", 0, 0, false
+	}
+
+	parts := strings.Split(key[index+len(deltaSeparator):], ".")
+	if len(parts) != 2 {
+		return "", 0, 0, false
+	}
+	generation, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	sequence, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, 0, false
+	}
+
+	return key[:index], generation, sequence, true
+}
+
+// parseSnapshot decodes the given stored snapshot. Values that are not
+// snapshots, such as those written before delta encoding was enabled, are
+// treated as the snapshot of generation zero.
+func parseSnapshot(data json.RawMessage) deltaSnapshot {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err == nil && len(fields) == 2 {
+		var snapshot deltaSnapshot
+		value, found := fields["value"]
+		if err := json.Unmarshal(fields["generation"], &snapshot.Generation); err == nil && found && snapshot.Generation > 0 {
+			snapshot.Value = value
+			return snapshot
+		}
+	}
+
+	return deltaSnapshot{Value: data}
+}
+
+// deltas returns the keys of every delta of the given key, along with the
+// keys of any deltas from other generations, which are stale.
+func (s deltaStore) deltas(ctx context.Context, key string, generation int) ([]string, []string, error) {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var current, stale []string
+	sequences := make(map[string]int)
+	for _, name := range keys {
+		base, gen, sequence, ok := parseDeltaKey(name)
+		switch {
+		case !ok || base != key:
+			continue
+		case gen == generation:
+			current = append(current, name)
+			sequences[name] = sequence
+		default:
+			stale = append(stale, name)
+		}
+	}
+
+	// Deltas are applied in order of their sequence number.
+	sort.Slice(current, func(i, j int) bool {
+		return sequences[current[i]] < sequences[current[j]]
+	})
+
+	return current, stale, nil
+}
+
+// load reads the named entry, and reconstructs its value by applying each of
+// its deltas to its latest snapshot.
+func (s deltaStore) load(ctx context.Context, key string) (deltaState, error) {
+	var state deltaState
+	if err := s.store.Get(ctx, key, &state.raw); err != nil {
+		return deltaState{}, err
+	}
+
+	state.snapshot = parseSnapshot(state.raw)
+	if err := unmarshalNumber(state.snapshot.Value, &state.document); err != nil {
+		return deltaState{}, err
+	}
+
+	var err error
+	state.current, state.stale, err = s.deltas(ctx, key, state.snapshot.Generation)
+	if err != nil {
+		return deltaState{}, err
+	}
+
+	patches, err := GetMulti(ctx, s.store, state.current)
+	if err != nil {
+		return deltaState{}, err
+	}
+
+	for _, name := range state.current {
+		data, found := patches[name]
+		if !found {
+			// The delta may have been removed by a concurrent writer that
+			// replaced the snapshot, in which case the entry is read again.
+			var raw json.RawMessage
+			if err := s.store.Get(ctx, key, &raw); err != nil || !bytes.Equal(raw, state.raw) {
+				return s.load(ctx, key)
+			}
+			return deltaState{}, fmt.Errorf("delta %s of key %s is missing", name, key)
+		}
+
+		var patch interface{}
+		if err := unmarshalNumber(data, &patch); err != nil {
+			return deltaState{}, err
+		}
+		state.document = mergePatch(state.document, patch)
+	}
+
+	return state, nil
+}
+
+// Get reads the named entry from the wrapped Store, applying any deltas to its
+// latest snapshot, and stores the contents into the given value pointer.
+func (s deltaStore) Get(ctx context.Context, key string, value interface{}) error {
+	state, err := s.load(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(state.document)
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(data, value)
+}
+
+// Set writes the difference between the given value and the current value of
+// the named entry as a delta. A full snapshot is written instead when the key
+// does not exist, when the configured number of deltas have been written since
+// the last snapshot, or when a delta would not be smaller than the value.
+//
+// If the wrapped Store does not support atomic updates, the value is written
+// without guarding against concurrent writers.
+func (s deltaStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return s.update(ctx, key, func(json.RawMessage) (json.RawMessage, error) {
+		return data, nil
+	}, true)
+}
+
+// Update atomically modifies the named entry, writing the result of the given
+// function as either a delta or a full snapshot, in the same way as Set.
+//
+// If the wrapped Store does not support atomic updates, an error matching the
+// ErrorNotSupported sentinel error is returned.
+func (s deltaStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return s.update(ctx, key, fn, false)
+}
+
+// update modifies the named entry with the given function, trying again if
+// the entry was concurrently modified. If fallback is true, the entry is
+// written without any guards when the wrapped Store does not support atomic
+// updates.
+func (s deltaStore) update(ctx context.Context, key string, fn UpdateFunc, fallback bool) error {
+	for {
+		state, err := s.load(ctx, key)
+		if err != nil && !errors.Is(err, ErrorKeyNotFound) {
+			return err
+		}
+
+		// The function is given nil if the entry does not exist.
+		var current json.RawMessage
+		if state.raw != nil {
+			if current, err = json.Marshal(state.document); err != nil {
+				return err
+			}
+		}
+
+		result, err := fn(current)
+		if err != nil || unchanged(current, result) {
+			return err
+		}
+
+		err = s.commit(ctx, key, state, result, fallback)
+		if errors.Is(err, ErrorConflict) {
+			// The entry was modified concurrently, so try again.
+			continue
+		}
+		return err
+	}
+}
+
+// commit writes the given result, or deletes the entry if it is nil, provided
+// that the entry still has the given state. An error matching the
+// ErrorConflict sentinel error is returned if it does not.
+func (s deltaStore) commit(ctx context.Context, key string, state deltaState, result json.RawMessage, fallback bool) error {
+	if result != nil && state.raw != nil && len(state.current) < s.snapshotEvery {
+		var document interface{}
+		if err := unmarshalNumber(result, &document); err != nil {
+			return err
+		}
+
+		if patch, ok := createMergePatch(state.document, document); ok {
+			encoded, err := json.Marshal(patch)
+			if err != nil {
+				return err
+			}
+
+			if len(encoded) < len(result) {
+				return s.commitDelta(ctx, key, state, encoded, fallback)
+			}
+		}
+	}
+
+	// Write a new snapshot under the next generation, which supersedes all
+	// existing deltas, before removing them.
+	var snapshot json.RawMessage
+	if result != nil {
+		var err error
+		snapshot, err = json.Marshal(deltaSnapshot{
+			Generation: state.snapshot.Generation + 1,
+			Value:      result,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.replace(ctx, key, state.raw, snapshot, fallback)
+	if err != nil {
+		return err
+	}
+
+	return DeleteMany(ctx, s.store, append(state.current, state.stale...))
+}
+
+// commitDelta writes the given delta after every delta in the given state,
+// provided that no other delta has been written in its place, and that the
+// snapshot has not been replaced. An error matching the ErrorConflict sentinel
+// error is returned otherwise.
+func (s deltaStore) commitDelta(ctx context.Context, key string, state deltaState, delta json.RawMessage, fallback bool) error {
+	sequence := 1
+	if len(state.current) > 0 {
+		_, _, sequence, _ = parseDeltaKey(state.current[len(state.current)-1])
+		sequence++
+	}
+	name := deltaKey(key, state.snapshot.Generation, sequence)
+
+	// Create the delta, provided that a concurrent writer has not already
+	// created a delta with the same sequence number.
+	err := s.replace(ctx, name, nil, delta, fallback)
+	if err != nil {
+		return err
+	}
+
+	// A concurrent writer may have replaced the snapshot in the meantime, in
+	// which case the delta no longer applies.
+	var raw json.RawMessage
+	if err := s.store.Get(ctx, key, &raw); err != nil && !errors.Is(err, ErrorKeyNotFound) {
+		return err
+	}
+	if !bytes.Equal(raw, state.raw) {
+		if err := s.store.Delete(ctx, name); err != nil && !isKeyNotFound(err) {
+			return err
+		}
+		return fmt.Errorf("%w: snapshot of key %s was replaced", ErrorConflict, key)
+	}
+
+	return nil
+}
+
+// replace writes the given result to the named key, or deletes the key if it
+// is nil, provided that the current contents of the key are the given
+// expected contents. An error matching the ErrorConflict sentinel error is
+// returned if they are not. If fallback is true, the key is written without
+// any guards when the wrapped Store does not support atomic updates.
+func (s deltaStore) replace(ctx context.Context, key string, expected, result json.RawMessage, fallback bool) error {
+	err := Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		if !unchanged(expected, current) {
+			return nil, fmt.Errorf("%w: key %s was modified concurrently", ErrorConflict, key)
+		}
+		return result, nil
+	})
+	if !fallback || !errors.Is(err, ErrorNotSupported) {
+		return err
+	}
+
+	if result == nil {
+		return s.store.Delete(ctx, key)
+	}
+	return s.store.Set(ctx, key, result)
+}
+
+// List returns a list of all keys in the wrapped Store, excluding the deltas
+// of any keys that exist.
+func (s deltaStore) List(ctx context.Context) ([]string, error) {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		exists[key] = true
+	}
+
+	filtered := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if base, _, _, ok := parseDeltaKey(key); !ok || !exists[base] {
+			filtered = append(filtered, key)
+		}
+	}
+
+	return filtered, nil
+}
+
+// Delete removes the named entry from the wrapped Store, along with all of its
+// deltas.
+func (s deltaStore) Delete(ctx context.Context, key string) error {
+	if err := s.store.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	// The generation is unknown once the snapshot is removed, so every delta
+	// is treated as stale.
+	_, stale, err := s.deltas(ctx, key, -1)
+	if err != nil {
+		return err
+	}
+	return DeleteMany(ctx, s.store, stale)
+}
+
+// Describe returns a description of the wrapped Store, including how often
+// snapshots are written.
+func (s deltaStore) Describe() Description {
+	description := Describe(s.store)
+	if description.Options == nil {
+		description.Options = make(map[string]string)
+	}
+	description.Options["snapshotEvery"] = strconv.Itoa(s.snapshotEvery)
+	return description
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s deltaStore) resourceVersion(ctx context.Context) (string, error) {
+	return resourceVersionOf(ctx, s.store)
+}
+
+// Subscribe watches the wrapped Store for changes. Changes to deltas are
+// reported as changes to the key that they are a delta of.
+func (s deltaStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	upstream, err := Subscribe(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for event := range upstream {
+			if base, _, _, ok := parseDeltaKey(event.Key); ok {
+				// Removing deltas is not a change to the value.
+				if event.Type == EventDelete {
+					continue
+				}
+				event.Key = base
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// createMergePatch returns a JSON merge patch (RFC 7386) that transforms the
+// given original document into the given modified document. A merge patch can
+// only be created when both documents are objects, and the modified document
+// contains no null fields, as those would be interpreted as removals.
+func createMergePatch(original, modified interface{}) (map[string]interface{}, bool) {
+	originalObject, ok := original.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	modifiedObject, ok := modified.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	patch := make(map[string]interface{})
+
+	// Remove fields that are not in the modified document.
+	for name := range originalObject {
+		if _, found := modifiedObject[name]; !found {
+			patch[name] = nil
+		}
+	}
+
+	for name, value := range modifiedObject {
+		if value == nil {
+			return nil, false
+		}
+
+		existing, found := originalObject[name]
+		if found && reflect.DeepEqual(existing, value) {
+			continue
+		}
+
+		// Merge nested objects recursively, and replace anything else.
+		if nested, ok := createMergePatch(existing, value); ok {
+			patch[name] = nested
+			continue
+		}
+		if containsNull(value) {
+			return nil, false
+		}
+		patch[name] = value
+	}
+
+	return patch, true
+}
+
+// containsNull returns true if the given value is an object that contains a
+// null field, at any depth. Arrays are replaced as-is by a merge patch, so
+// their elements are not considered.
+func containsNull(value interface{}) bool {
+	if object, ok := value.(map[string]interface{}); ok {
+		for _, field := range object {
+			if field == nil || containsNull(field) {
+				return true
+			}
+		}
+	}
+	return false
+}
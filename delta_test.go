@@ -0,0 +1,169 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// deltaPadding makes values large enough that changes are written as deltas.
+var deltaPadding = strings.Repeat("x", 256)
+
+func TestDeltaConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir(), WithDeltaEncoding(3))
+
+	type counter struct {
+		Count   int    `json:"count"`
+		Padding string `json:"padding"`
+	}
+
+	const writers, increments = 8, 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				err := Update(ctx, store, "counter", func(current json.RawMessage) (json.RawMessage, error) {
+					value := counter{Padding: deltaPadding}
+					if current != nil {
+						if err := json.Unmarshal(current, &value); err != nil {
+							return nil, err
+						}
+					}
+					value.Count++
+					return json.Marshal(value)
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	var value counter
+	if err := store.Get(ctx, "counter", &value); err != nil {
+		t.Fatal(err)
+	}
+	if value.Count != writers*increments {
+		t.Fatalf("expected a count of %d, got %d", writers*increments, value.Count)
+	}
+}
+
+func TestDeltaConcurrentSets(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir(), WithDeltaEncoding(3))
+
+	const writers, writes = 8, 10
+
+	// Each writer sets a field of its own, so a value that was merged from
+	// the writes of multiple writers has more than one such field.
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				value := map[string]interface{}{
+					"padding":                        deltaPadding,
+					"writer":                         writer,
+					fmt.Sprintf("writer-%d", writer): j,
+				}
+				if err := store.Set(ctx, "value", value); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	var value map[string]interface{}
+	if err := store.Get(ctx, "value", &value); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"padding": deltaPadding,
+		"writer":  value["writer"],
+		fmt.Sprintf("writer-%v", value["writer"]): float64(writes - 1),
+	}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("expected %v, got %v", expected, value)
+	}
+}
+
+func TestDeltaPlainValue(t *testing.T) {
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	// Write a value before delta encoding is enabled.
+	if err := NewFileStore(directory).Set(ctx, "key", map[string]string{"a": "1", "padding": deltaPadding}); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewFileStore(directory, WithDeltaEncoding(3))
+	for _, expected := range []map[string]string{
+		{"a": "1", "padding": deltaPadding},
+		{"a": "2", "padding": deltaPadding},
+	} {
+		if err := store.Set(ctx, "key", expected); err != nil {
+			t.Fatal(err)
+		}
+
+		var value map[string]string
+		if err := store.Get(ctx, "key", &value); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(value, expected) {
+			t.Fatalf("expected %v, got %v", expected, value)
+		}
+	}
+}
+
+func TestDeltaList(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir(), WithDeltaEncoding(3), WithSortedList())
+
+	// Write a value along with a delta of it, and a key that only looks like
+	// a delta.
+	for _, value := range []string{"1", "2"} {
+		if err := store.Set(ctx, "key", map[string]string{"a": value, "padding": deltaPadding}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Set(ctx, "report.delta.1.2", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"key", "report.delta.1.2"}; !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected %v, got %v", expected, keys)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Description holds details about the physical medium that backs a Store.
+type Description struct {
+	// Backend is the kind of Store, such as "configmap" or "file".
+	Backend string `json:"backend"`
+
+	// Resource is the group, version, and resource of the backing object,
+	// for Stores backed by a Kubernetes resource.
+	Resource string `json:"resource,omitempty"`
+
+	// Namespace is the namespace of the backing object, for Stores backed by
+	// a namespaced Kubernetes resource.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name is the name of the backing object, or the path of the backing
+	// directory.
+	Name string `json:"name"`
+
+	// Options holds any non-default options that the Store was configured
+	// with.
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// String formats the description in a form that is suitable for logging.
+func (d Description) String() string {
+	var builder strings.Builder
+	builder.WriteString(d.Backend)
+	if d.Resource != "" {
+		fmt.Fprintf(&builder, " %s", d.Resource)
+	}
+	if d.Namespace != "" {
+		fmt.Fprintf(&builder, " %s/%s", d.Namespace, d.Name)
+	} else {
+		fmt.Fprintf(&builder, " %s", d.Name)
+	}
+
+	// Include options in a stable order.
+	names := make([]string, 0, len(d.Options))
+	for name := range d.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&builder, " %s=%s", name, d.Options[name])
+	}
+
+	return builder.String()
+}
+
+// Describer represents a Store that is capable of describing the physical
+// medium that backs it.
+type Describer interface {
+	// Describe returns a description of the backing medium.
+	Describe() Description
+}
+
+// Describe returns a description of the physical medium that backs the given
+// Store. If the Store does not implement the Describer interface, only the
+// type of the Store is described.
+func Describe(store Store) Description {
+	if describer, ok := store.(Describer); ok {
+		return describer.Describe()
+	}
+	return Description{Backend: fmt.Sprintf("%T", store)}
+}
+
+// Describe returns a description of the backing resource.
+func (c annotationStore) Describe() Description {
+	return Description{
+		Backend:   "annotation",
+		Resource:  path.Join(c.gvr.Group, c.gvr.Version, c.gvr.Resource),
+		Namespace: c.namespace,
+		Name:      c.name,
+		Options:   c.options.describe(),
+	}
+}
+
+// Describe returns a description of the backing ConfigMap.
+func (c configMapStore) Describe() Description {
+	return Description{
+		Backend:   "configmap",
+		Namespace: c.namespace,
+		Name:      c.name,
+	}
+}
+
+// Describe returns a description of the backing Secret.
+func (c secretStore) Describe() Description {
+	return Description{
+		Backend:   "secret",
+		Namespace: c.namespace,
+		Name:      c.name,
+	}
+}
+
+// Describe returns a description of the backing directory.
+func (s fileStore) Describe() Description {
+	return Description{
+		Backend: "file",
+		Name:    s.directory,
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Assert that DualStore implements the Store interface.
+var _ Store = (*DualStore)(nil)
+
+// DualStore is a Store that wraps an old and a new Store, and serves both at
+// once, in order to migrate from one backend to another without downtime.
+type DualStore struct {
+	oldStore Store
+	newStore Store
+
+	mu      sync.Mutex
+	cutover bool
+}
+
+// NewDualStore returns a DualStore that migrates from the given old Store to
+// the given new Store.
+//
+// Until DualStore.Cutover is called, changes are written to both stores, and
+// reads are made against the new Store, falling back to the old Store for keys
+// that have not been written since the migration began. Existing keys can be
+// copied into the new Store ahead of time using a Replicator, and the result
+// checked using DualStore.Verify. After the cutover, the old Store is no
+// longer used.
+func NewDualStore(oldStore, newStore Store) *DualStore {
+	return &DualStore{
+		oldStore: oldStore,
+		newStore: newStore,
+	}
+}
+
+// Cutover stops the DualStore from using the old Store, so that all reads and
+// writes are made against the new Store only.
+func (s *DualStore) Cutover() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cutover = true
+}
+
+// cutOver returns true if the DualStore no longer uses the old Store.
+func (s *DualStore) cutOver() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cutover
+}
+
+// Get reads the named entry from the new Store, falling back to the old Store
+// if the entry does not exist, and stores the contents into the given value
+// pointer.
+func (s *DualStore) Get(ctx context.Context, key string, value interface{}) error {
+	err := s.newStore.Get(ctx, key, value)
+	if !errors.Is(err, ErrorKeyNotFound) || s.cutOver() {
+		return err
+	}
+	return s.oldStore.Get(ctx, key, value)
+}
+
+// Set writes the named entry and value into the new Store, and into the old
+// Store if the cutover has not yet happened.
+func (s *DualStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if err := s.newStore.Set(ctx, key, json.RawMessage(data)); err != nil {
+		return err
+	}
+	if s.cutOver() {
+		return nil
+	}
+	return s.oldStore.Set(ctx, key, json.RawMessage(data))
+}
+
+// List returns a list of all keys in the new Store, along with the keys in the
+// old Store if the cutover has not yet happened.
+func (s *DualStore) List(ctx context.Context) ([]string, error) {
+	keys, err := s.newStore.List(ctx)
+	if err != nil || s.cutOver() {
+		return keys, err
+	}
+
+	old, err := s.oldStore.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		seen[key] = true
+	}
+	for _, key := range old {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// Delete removes the named entry from the new Store, and from the old Store if
+// the cutover has not yet happened. The ErrorKeyNotFound sentinel error is
+// only returned if the entry did not exist in either Store.
+//
+// The entry is removed from the old Store first, so that a failure can not
+// leave the entry in the old Store only, where reads would fall back to it.
+func (s *DualStore) Delete(ctx context.Context, key string) error {
+	if s.cutOver() {
+		return s.newStore.Delete(ctx, key)
+	}
+
+	err := s.oldStore.Delete(ctx, key)
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	err = s.newStore.Delete(ctx, key)
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	if err != nil && !found {
+		return ErrorKeyNotFound
+	}
+	return nil
+}
+
+// Describe returns a description of the new Store.
+func (s *DualStore) Describe() Description {
+	return Describe(s.newStore)
+}
+
+// Verify compares every key in the old Store against the new Store, and
+// returns the keys that are missing from the new Store or hold a different
+// value, in sorted order. An empty result indicates that the migration is
+// complete, and that it is safe to cut over.
+func (s *DualStore) Verify(ctx context.Context) ([]string, error) {
+	keys, err := s.oldStore.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	oldValues, err := GetMulti(ctx, s.oldStore, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	newValues, err := GetMulti(ctx, s.newStore, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatched []string
+	for key, value := range oldValues {
+		if !jsonEqual(value, newValues[key]) {
+			mismatched = append(mismatched, key)
+		}
+	}
+	sort.Strings(mismatched)
+
+	return mismatched, nil
+}
+
+// jsonEqual returns true if the given JSON values are identical, disregarding
+// insignificant whitespace.
+func jsonEqual(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	var left, right bytes.Buffer
+	if json.Compact(&left, a) != nil || json.Compact(&right, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(left.Bytes(), right.Bytes())
+}
@@ -0,0 +1,164 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Dumper represents a Store that is capable of writing the raw contents of its
+// backing medium, for debugging purposes.
+type Dumper interface {
+	// Dump writes the raw contents of the backing medium to the given writer.
+	Dump(ctx context.Context, w io.Writer, opts ...Option) error
+}
+
+// Dump writes the raw contents of the medium backing the given Store, such as
+// the backing ConfigMap or Secret, to the given writer as indented JSON. This
+// is intended for capturing the state of a Store when reporting bugs.
+//
+// Secret values are redacted when using the WithRedaction option.
+//
+// If the Store does not implement the Dumper interface, then its description
+// and the contents of every key are written instead, in which case every value
+// is redacted when using the WithRedaction option.
+func Dump(ctx context.Context, store Store, w io.Writer, opts ...Option) error {
+	if dumper, ok := store.(Dumper); ok {
+		return dumper.Dump(ctx, w, opts...)
+	}
+
+	keys, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	values, err := GetMulti(ctx, store, keys, opts...)
+	if err != nil {
+		return err
+	}
+
+	if newOptions(opts).redact {
+		for key, value := range values {
+			values[key] = redacted(value)
+		}
+	}
+
+	return dumpJSON(w, struct {
+		Description Description                `json:"description"`
+		Values      map[string]json.RawMessage `json:"values"`
+	}{
+		Description: Describe(store),
+		Values:      values,
+	})
+}
+
+// dumpJSON writes the given value to the given writer as indented JSON.
+func dumpJSON(w io.Writer, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// redacted returns a placeholder for the given value, which indicates only its
+// size.
+func redacted(data []byte) json.RawMessage {
+	return json.RawMessage(fmt.Sprintf("%q", fmt.Sprintf("<redacted %d bytes>", len(data))))
+}
+
+// Dump writes the backing resource, limited to its identifying metadata and
+// annotations, to the given writer.
+func (c annotationStore) Dump(ctx context.Context, w io.Writer, _ ...Option) error {
+	// Use the Kuberneties API to get the backing resource.
+	resource, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	return dumpJSON(w, struct {
+		Resource string            `json:"resource"`
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}{
+		Resource: c.gvr.String(),
+		Metadata: metav1.ObjectMeta{
+			Name:            resource.GetName(),
+			Namespace:       resource.GetNamespace(),
+			UID:             resource.GetUID(),
+			ResourceVersion: resource.GetResourceVersion(),
+			Annotations:     resource.GetAnnotations(),
+		},
+	})
+}
+
+// Dump writes the backing ConfigMap to the given writer.
+func (c configMapStore) Dump(ctx context.Context, w io.Writer, _ ...Option) error {
+	// Use the Kuberneties API to get the backing ConfigMap.
+	configMap, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	// Omit managed fields, as they are noisy and irrelevant.
+	configMap.ManagedFields = nil
+	configMap.APIVersion = "v1"
+	configMap.Kind = "ConfigMap"
+
+	return dumpJSON(w, configMap)
+}
+
+// Dump writes the backing Secret to the given writer. Secret values are
+// redacted when using the WithRedaction option.
+func (c secretStore) Dump(ctx context.Context, w io.Writer, opts ...Option) error {
+	// Use the Kuberneties API to get the backing Secret.
+	secret, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	// Omit managed fields, as they are noisy and irrelevant.
+	secret.ManagedFields = nil
+	secret.APIVersion = "v1"
+	secret.Kind = "Secret"
+
+	// Replace each value with a readable placeholder, as the data values
+	// would otherwise be base64 encoded.
+	if newOptions(opts).redact {
+		secret.StringData = make(map[string]string, len(secret.Data))
+		for key, value := range secret.Data {
+			secret.StringData[key] = fmt.Sprintf("<redacted %d bytes>", len(value))
+		}
+		secret.Data = nil
+	}
+
+	return dumpJSON(w, secret)
+}
+
+// Dump writes the raw contents of the wrapped Store.
+func (s decodeStore) Dump(ctx context.Context, w io.Writer, opts ...Option) error {
+	return Dump(ctx, s.store, w, opts...)
+}
+
+// Dump writes the raw contents of the wrapped Store.
+func (s valueHookStore) Dump(ctx context.Context, w io.Writer, opts ...Option) error {
+	return Dump(ctx, s.store, w, opts...)
+}
+
+// Dump writes the raw contents of the wrapped Store.
+func (s keyTransformStore) Dump(ctx context.Context, w io.Writer, opts ...Option) error {
+	return Dump(ctx, s.store, w, opts...)
+}
+
+// Dump writes the raw contents of the wrapped Store.
+func (s quotaStore) Dump(ctx context.Context, w io.Writer, opts ...Option) error {
+	return Dump(ctx, s.store, w, opts...)
+}
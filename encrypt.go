@@ -0,0 +1,336 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// encryptedEnvelope is the stored form of an encrypted value.
+type encryptedEnvelope struct {
+	// KeyID identifies the key used to encrypt the value. It is empty for
+	// values encrypted without key IDs.
+	KeyID string `json:"kid,omitempty"`
+
+	// Data is the nonce followed by the sealed value.
+	Data []byte `json:"data"`
+}
+
+// KeySelector returns the ID of the key that should be used to encrypt the
+// value of the given key, allowing different entries to be encrypted with
+// different keys, for instance one key per tenant.
+type KeySelector func(key string) string
+
+// KeyResolver returns the encryption key with the given ID. The key for a
+// given ID must never change, as resolved keys are cached.
+type KeyResolver func(ctx context.Context, id string) ([]byte, error)
+
+// Assert that EncryptedStore implements the Store interface.
+var _ Store = (*EncryptedStore)(nil)
+
+// EncryptedStore is a Store that wraps another Store, and encrypts the values
+// that are written to it.
+type EncryptedStore struct {
+	store   Store
+	current cipher.AEAD
+	ring    []cipher.AEAD
+
+	selector KeySelector
+	resolver KeyResolver
+
+	mu       sync.Mutex
+	resolved map[string]cipher.AEAD
+}
+
+// NewEncryptedStore returns an EncryptedStore that wraps the given Store, and
+// encrypts values using AES-GCM with the given key, which must be 16, 24, or
+// 32 bytes long. Each value is bound to the name of its key, so an encrypted
+// value cannot be moved to a different key.
+//
+// Any additional keys are only used for decrypting values, in order to allow
+// the key to be rotated without downtime. Values that were encrypted with a
+// previous key remain readable, and can be encrypted with the current key by
+// calling EncryptedStore.ReEncryptAll.
+func NewEncryptedStore(store Store, key []byte, decryptOnly ...[]byte) (*EncryptedStore, error) {
+	current, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ring := make([]cipher.AEAD, 0, len(decryptOnly))
+	for _, key := range decryptOnly {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		ring = append(ring, aead)
+	}
+
+	return &EncryptedStore{
+		store:   store,
+		current: current,
+		ring:    ring,
+	}, nil
+}
+
+// NewKeyedEncryptedStore returns an EncryptedStore that wraps the given Store,
+// and encrypts the value of each entry using AES-GCM with the key whose ID is
+// returned by the given selector. Each value is tagged with the ID of the key
+// used, and the given resolver is used to fetch the key with that ID when the
+// value is read.
+//
+// Keys can be rotated gradually by changing the ID returned by the selector.
+// Values that were encrypted with a previous key remain readable for as long
+// as the resolver can fetch that key, and can be encrypted with the selected
+// key by calling EncryptedStore.ReEncryptAll.
+func NewKeyedEncryptedStore(store Store, selector KeySelector, resolver KeyResolver) *EncryptedStore {
+	return &EncryptedStore{
+		store:    store,
+		selector: selector,
+		resolver: resolver,
+		resolved: make(map[string]cipher.AEAD),
+	}
+}
+
+// newAEAD returns an AES-GCM cipher using the given key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Get reads the named entry from the wrapped Store, decrypts it, and stores
+// the contents into the given value pointer.
+//
+// If the entry could not be decrypted by any of the keys, the
+// ErrorDecryptionFailed sentinel error is returned.
+func (s *EncryptedStore) Get(ctx context.Context, key string, value interface{}) error {
+	var data json.RawMessage
+	if err := s.store.Get(ctx, key, &data); err != nil {
+		return err
+	}
+
+	plaintext, _, err := s.decrypt(ctx, key, data)
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(plaintext, value)
+}
+
+// Set encrypts the given value with the current key (or the selected key),
+// and writes it into the wrapped Store.
+func (s *EncryptedStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.encrypt(ctx, key, plaintext)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Set(ctx, key, data)
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s *EncryptedStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s *EncryptedStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s *EncryptedStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s *EncryptedStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// Update atomically modifies the named entry in the wrapped Store, decrypting
+// its current contents and encrypting the result with the current key. If the
+// given function returns the current contents unchanged, then nothing is
+// written.
+func (s *EncryptedStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		var plaintext json.RawMessage
+		if current != nil {
+			var err error
+			if plaintext, _, err = s.decrypt(ctx, key, current); err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := fn(plaintext)
+		if err != nil || result == nil {
+			return nil, err
+		}
+		if unchanged(plaintext, result) {
+			return current, nil
+		}
+
+		return s.encrypt(ctx, key, result)
+	})
+}
+
+// ReEncryptAll encrypts every entry in the wrapped Store that was encrypted
+// with a previous key using the current key (or the selected key, when using
+// key IDs), so that the previous keys may then be retired. Entries are
+// modified atomically if the wrapped Store supports updates.
+func (s *EncryptedStore) ReEncryptAll(ctx context.Context) error {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := s.reEncrypt(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reEncrypt encrypts the named entry with the current key (or the selected
+// key), if it was encrypted with a previous key.
+func (s *EncryptedStore) reEncrypt(ctx context.Context, key string) error {
+	fn := func(current json.RawMessage) (json.RawMessage, error) {
+		if current == nil {
+			return nil, nil
+		}
+
+		plaintext, stale, err := s.decrypt(ctx, key, current)
+		if err != nil || !stale {
+			return current, err
+		}
+
+		return s.encrypt(ctx, key, plaintext)
+	}
+
+	return updateOrSet(ctx, s.store, key, fn)
+}
+
+// encrypt seals the given plaintext with the current key (or the selected key),
+// binding it to the given key name.
+func (s *EncryptedStore) encrypt(ctx context.Context, key string, plaintext []byte) (json.RawMessage, error) {
+	var envelope encryptedEnvelope
+	aead := s.current
+	if s.selector != nil {
+		envelope.KeyID = s.selector(key)
+
+		var err error
+		if aead, err = s.resolve(ctx, envelope.KeyID); err != nil {
+			return nil, err
+		}
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	envelope.Data = aead.Seal(nonce, nonce, plaintext, []byte(key))
+
+	return json.Marshal(envelope)
+}
+
+// decrypt opens the given envelope, bound to the given key name. Envelopes that
+// are tagged with a key ID are opened with the resolved key, otherwise the
+// current key is tried followed by each of the previous keys. It also returns
+// true if the envelope was not encrypted with the current key (or the selected
+// key).
+func (s *EncryptedStore) decrypt(ctx context.Context, key string, data []byte) ([]byte, bool, error) {
+	var envelope encryptedEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, false, err
+	}
+
+	if envelope.KeyID != "" {
+		if s.resolver == nil {
+			return nil, false, ErrorDecryptionFailed
+		}
+
+		aead, err := s.resolve(ctx, envelope.KeyID)
+		if err != nil {
+			return nil, false, err
+		}
+
+		plaintext, err := openSealed(aead, key, envelope.Data)
+		if err != nil {
+			return nil, false, err
+		}
+		return plaintext, s.selector == nil || s.selector(key) != envelope.KeyID, nil
+	}
+
+	ring := s.ring
+	if s.current != nil {
+		ring = append([]cipher.AEAD{s.current}, ring...)
+	}
+	for index, aead := range ring {
+		if plaintext, err := openSealed(aead, key, envelope.Data); err == nil {
+			return plaintext, index > 0 || s.current == nil, nil
+		}
+	}
+
+	return nil, false, ErrorDecryptionFailed
+}
+
+// openSealed opens the given nonce and sealed value, bound to the given key
+// name.
+func openSealed(aead cipher.AEAD, key string, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrorDecryptionFailed
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		return nil, ErrorDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// resolve returns a cipher using the key with the given ID, fetching the key
+// with the resolver if it was not previously fetched.
+func (s *EncryptedStore) resolve(ctx context.Context, id string) (cipher.AEAD, error) {
+	s.mu.Lock()
+	aead, found := s.resolved[id]
+	s.mu.Unlock()
+	if found {
+		return aead, nil
+	}
+
+	key, err := s.resolver(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if aead, err = newAEAD(key); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.resolved[id] = aead
+	s.mu.Unlock()
+
+	return aead, nil
+}
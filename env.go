@@ -0,0 +1,58 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+)
+
+// NewFromEnv returns a Store for the backend described by environment
+// variables, allowing the same binary to switch between backends without code
+// changes.
+//
+// If KUBESTORE_URL is set, it is used as a DSN as described by Open.
+// Otherwise, KUBESTORE_BACKEND selects one of "configmap", "secret",
+// "annotation", or "file", which are configured by the following variables:
+//
+//	KUBESTORE_NAME       name of the backing resource
+//	KUBESTORE_NAMESPACE  namespace of the backing resource (optional)
+//	KUBESTORE_GROUP      API group of the annotated resource (optional)
+//	KUBESTORE_VERSION    API version of the annotated resource
+//	KUBESTORE_RESOURCE   plural name of the annotated resource
+//	KUBESTORE_DIRECTORY  path of the backing directory
+func NewFromEnv(opts ...Option) (Store, error) {
+	if dsn := os.Getenv("KUBESTORE_URL"); dsn != "" {
+		return Open(context.Background(), dsn, opts...)
+	}
+
+	u := url.URL{Scheme: os.Getenv("KUBESTORE_BACKEND")}
+	switch u.Scheme {
+	case "configmap", "secret":
+		u.Host = os.Getenv("KUBESTORE_NAMESPACE")
+		u.Path = "/" + os.Getenv("KUBESTORE_NAME")
+	case "annotation":
+		u.Host = os.Getenv("KUBESTORE_GROUP")
+		u.Path = "/" + path.Join(os.Getenv("KUBESTORE_VERSION"), os.Getenv("KUBESTORE_RESOURCE"), os.Getenv("KUBESTORE_NAME"))
+		if namespace := os.Getenv("KUBESTORE_NAMESPACE"); namespace != "" {
+			u.RawQuery = url.Values{"namespace": {namespace}}.Encode()
+		}
+	case "file":
+		// Construct the file store directly, as the directory may be a
+		// relative path, which cannot be represented as a DSN.
+		directory := os.Getenv("KUBESTORE_DIRECTORY")
+		if directory == "" {
+			return nil, fmt.Errorf("%w: KUBESTORE_DIRECTORY is not set", ErrorInvalidDSN)
+		}
+		return NewFileStore(directory, opts...), nil
+	case "":
+		return nil, fmt.Errorf("%w: neither KUBESTORE_URL nor KUBESTORE_BACKEND are set", ErrorInvalidDSN)
+	}
+
+	return Open(context.Background(), u.String(), opts...)
+}
@@ -4,8 +4,113 @@
 
 package kubestore
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
 
 // ErrorKeyNotFound is a sentinel error for indicating that a key used when
-// calling Store.Get was not found.
+// calling Store.Get was not found. Errors should be compared against it using
+// errors.Is, as it may be wrapped by a more specific error.
 var ErrorKeyNotFound = errors.New("key not found")
+
+// ErrorValueTooLarge is a sentinel error for indicating that a value used when
+// calling Store.Set could not be stored, as it would exceed the size limits of
+// the backing medium.
+var ErrorValueTooLarge = errors.New("value too large")
+
+// ErrorConflict is a sentinel error for indicating that a change could not be
+// made, as the backing medium was concurrently modified by another writer.
+var ErrorConflict = errors.New("conflicting modification")
+
+// ErrorNotSupported is a sentinel error for indicating that an operation is
+// not supported by a Store.
+var ErrorNotSupported = errors.New("operation not supported")
+
+// ErrorQueueEmpty is a sentinel error for indicating that a Queue has no items
+// that are available to be claimed.
+var ErrorQueueEmpty = errors.New("queue empty")
+
+// ErrorQuotaExceeded is a sentinel error for indicating that a key used when
+// calling Store.Set could not be created, as the Store already contains the
+// maximum number of keys.
+var ErrorQuotaExceeded = errors.New("quota exceeded")
+
+// ErrorResourceMissing is a sentinel error for indicating that the resource
+// backing a Store does not exist, as opposed to a single key not existing.
+var ErrorResourceMissing = errors.New("resource missing")
+
+// ErrorDecryptionFailed is a sentinel error for indicating that a value read
+// when calling Store.Get could not be decrypted by any of the available keys.
+var ErrorDecryptionFailed = errors.New("decryption failed")
+
+// ErrorTampered is a sentinel error for indicating that a value read when
+// calling Store.Get does not match its signature, and so was modified by
+// something other than a Store configured with the same key.
+var ErrorTampered = errors.New("value tampered")
+
+// ErrorVersionMismatch is a sentinel error for indicating that a value read
+// when calling Store.Get was written with a schema version other than the
+// current version, and so must first be migrated.
+var ErrorVersionMismatch = errors.New("schema version mismatch")
+
+// ErrorIndexOutOfRange is a sentinel error for indicating that an index used
+// when accessing an element of an array value is outside of the bounds of the
+// array.
+var ErrorIndexOutOfRange = errors.New("index out of range")
+
+// ErrorInvalidName is a sentinel error for indicating that the name of the
+// resource backing a Store is not a valid Kubernetes object name.
+var ErrorInvalidName = errors.New("invalid name")
+
+// InvalidNameError describes a name that is not a valid Kubernetes object name.
+// It matches ErrorInvalidName when using errors.Is.
+type InvalidNameError struct {
+	// Name is the invalid name.
+	Name string
+
+	// Reasons describes why the name is invalid.
+	Reasons []string
+}
+
+func (e *InvalidNameError) Error() string {
+	return fmt.Sprintf("%s %q: %s", ErrorInvalidName, e.Name, strings.Join(e.Reasons, ", "))
+}
+
+func (e *InvalidNameError) Is(target error) bool {
+	return target == ErrorInvalidName
+}
+
+// resourceMissingError wraps an error returned by the Kubernetes API when the
+// resource backing a Store does not exist. It matches ErrorResourceMissing when
+// using errors.Is, and optionally ErrorKeyNotFound as well.
+type resourceMissingError struct {
+	err         error
+	keyNotFound bool
+}
+
+func (e resourceMissingError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrorResourceMissing, e.err)
+}
+
+func (e resourceMissingError) Unwrap() error {
+	return e.err
+}
+
+func (e resourceMissingError) Is(target error) bool {
+	return target == ErrorResourceMissing || (e.keyNotFound && target == ErrorKeyNotFound)
+}
+
+// errNotSnapshot is a sentinel error for indicating that an object in object
+// storage is not a snapshot written by ExportToObjectStorage.
+var errNotSnapshot = errors.New("not a snapshot")
+
+// isKeyNotFound returns true if the given error indicates that a key did not
+// exist. Some Stores, such as file Stores, report a missing key when calling
+// Store.Delete with an error matching os.ErrNotExist instead of
+// ErrorKeyNotFound.
+func isKeyNotFound(err error) bool {
+	return errors.Is(err, ErrorKeyNotFound) || errors.Is(err, os.ErrNotExist)
+}
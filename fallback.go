@@ -0,0 +1,362 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Assert that FallbackStore implements the Store interface.
+var _ Store = (*FallbackStore)(nil)
+
+// FallbackStore is a Store that wraps a primary Store, and degrades to a local
+// mirror when the Kubernetes API is unavailable.
+type FallbackStore struct {
+	primary   Store
+	local     Store
+	threshold int
+	retry     time.Duration
+
+	// persistMu serializes reconciling changes to the primary Store.
+	persistMu sync.Mutex
+
+	mu         sync.Mutex
+	failures   int
+	degraded   bool
+	pending    map[string]pendingWrite
+	generation uint64
+	policy     ConflictPolicy
+
+	// observed holds the last value seen in the primary Store for each key,
+	// or nil if the key was seen to not exist.
+	observed map[string]json.RawMessage
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewFallbackStore returns a FallbackStore that wraps the given primary Store,
+// and mirrors values into the given local Store (typically a file store).
+//
+// After the given number of consecutive calls to the primary Store fail due
+// to the Kubernetes API being unavailable, the FallbackStore is degraded.
+// While degraded, reads are served from the local Store, and changes are
+// written to the local Store and queued in memory. A background worker checks
+// the primary Store at the given interval, and once it is available again,
+// replays the queued changes and recovers.
+//
+// Only values that have been read from, or written to, the primary Store
+// before it became unavailable are present in the local Store.
+func NewFallbackStore(primary, local Store, threshold int, retry time.Duration) *FallbackStore {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &FallbackStore{
+		primary:   primary,
+		local:     local,
+		threshold: threshold,
+		retry:     retry,
+		pending:   make(map[string]pendingWrite),
+		observed:  make(map[string]json.RawMessage),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	go s.run(ctx)
+
+	return s
+}
+
+// Get reads the named entry from the primary Store, and mirrors it into the
+// local Store. If degraded, the named entry is read from the local Store
+// instead.
+func (s *FallbackStore) Get(ctx context.Context, key string, value interface{}) error {
+	if !s.Degraded() {
+		var data json.RawMessage
+		err := s.primary.Get(ctx, key, &data)
+		switch {
+		case s.observe(err):
+			// Fallthrough to reading from the local Store.
+		case err == nil:
+			// Repair the value in the local Store. Intentionally ignore any
+			// errors, as this is non-essential.
+			s.remember(key, data)
+			_ = s.local.Set(ctx, key, data)
+			return json.Unmarshal(data, value)
+		case errors.Is(err, ErrorKeyNotFound):
+			// Intentionally ignore any errors, as this is non-essential.
+			s.remember(key, nil)
+			_ = s.local.Delete(ctx, key)
+			return err
+		default:
+			return err
+		}
+	}
+
+	return s.local.Get(ctx, key, value)
+}
+
+// Set writes the named entry and value into the primary Store, and mirrors it
+// into the local Store. If degraded, the change is written to the local Store
+// and queued to be replayed once the primary Store is available again.
+func (s *FallbackStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if !s.Degraded() {
+		err := s.primary.Set(ctx, key, json.RawMessage(data))
+		if !s.observe(err) {
+			if err == nil {
+				// Intentionally ignore any errors, as this is non-essential.
+				s.remember(key, data)
+				_ = s.local.Set(ctx, key, json.RawMessage(data))
+			}
+			return err
+		}
+	}
+
+	if err := s.local.Set(ctx, key, json.RawMessage(data)); err != nil {
+		return err
+	}
+	s.record(key, pendingWrite{data: data})
+	return nil
+}
+
+// List returns a list of all keys in the primary Store. If degraded, the keys
+// are listed from the local Store instead.
+func (s *FallbackStore) List(ctx context.Context) ([]string, error) {
+	if !s.Degraded() {
+		keys, err := s.primary.List(ctx)
+		if !s.observe(err) {
+			return keys, err
+		}
+	}
+
+	return s.local.List(ctx)
+}
+
+// Delete removes the named entry from the primary Store, and from the local
+// Store. If degraded, the entry is removed from the local Store and the
+// removal is queued to be replayed once the primary Store is available again.
+func (s *FallbackStore) Delete(ctx context.Context, key string) error {
+	if !s.Degraded() {
+		err := s.primary.Delete(ctx, key)
+		if !s.observe(err) {
+			if err == nil {
+				// Intentionally ignore any errors, as this is non-essential.
+				s.remember(key, nil)
+				_ = s.local.Delete(ctx, key)
+			}
+			return err
+		}
+	}
+
+	err := s.local.Delete(ctx, key)
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	s.record(key, pendingWrite{deleted: true})
+	return nil
+}
+
+// Describe returns a description of the primary Store.
+func (s *FallbackStore) Describe() Description {
+	return Describe(s.primary)
+}
+
+// Degraded returns true if the primary Store is currently considered to be
+// unavailable.
+func (s *FallbackStore) Degraded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.degraded
+}
+
+// OnConflict registers a policy for resolving conflicts when recovering. A
+// conflict occurs when a key that was changed locally while degraded was also
+// changed in the primary Store since it was last observed. By default, the
+// PreferLocal policy is used.
+func (s *FallbackStore) OnConflict(policy ConflictPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// Close stops the background worker. Any changes that have yet to be replayed
+// to the primary Store remain only in the local Store.
+func (s *FallbackStore) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// observe records the result of a call to the primary Store, and returns true
+// if the FallbackStore is now degraded.
+func (s *FallbackStore) observe(err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Any result other than the Kubernetes API being unavailable resets the
+	// count of consecutive failures.
+	if !isUnavailableError(err) {
+		s.failures = 0
+		return false
+	}
+
+	s.failures++
+	if s.failures >= s.threshold {
+		s.degraded = true
+	}
+	return s.degraded
+}
+
+// record queues the given change to be replayed to the primary Store.
+func (s *FallbackStore) record(key string, write pendingWrite) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	write.generation = s.generation
+	write.modified = time.Now()
+	s.pending[key] = write
+}
+
+// remember records the given value as the last value seen in the primary
+// Store for the given key.
+func (s *FallbackStore) remember(key string, data json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observed[key] = data
+}
+
+// run attempts to recover at the configured interval, until the given context
+// is done.
+func (s *FallbackStore) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.retry):
+		}
+
+		if s.Degraded() {
+			// Intentionally ignore any errors, as recovering will be retried.
+			_ = s.recover(ctx)
+		}
+	}
+}
+
+// recover checks that the primary Store is available, and replays all queued
+// changes to it. Once every change has been replayed, the FallbackStore is no
+// longer degraded.
+func (s *FallbackStore) recover(ctx context.Context) error {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	// Check that the primary Store is available.
+	if _, err := s.primary.List(ctx); err != nil {
+		return err
+	}
+
+	for {
+		// Take a snapshot of the queued changes.
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			// Every change has been replayed, so recover while still
+			// holding the lock, in order to not miss any new changes.
+			s.degraded = false
+			s.failures = 0
+			s.mu.Unlock()
+			return nil
+		}
+		snapshot := make(map[string]pendingWrite, len(s.pending))
+		for key, write := range s.pending {
+			snapshot[key] = write
+		}
+		s.mu.Unlock()
+
+		for key, write := range snapshot {
+			if err := s.reconcile(ctx, key, write); err != nil {
+				return err
+			}
+
+			// Forget the change, unless it was replaced in the interim.
+			s.mu.Lock()
+			if current, found := s.pending[key]; found && current.generation == write.generation {
+				delete(s.pending, key)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// reconcile replays the given queued change to the primary Store, resolving
+// any conflict with the configured policy, and repairs the local Store to
+// match.
+func (s *FallbackStore) reconcile(ctx context.Context, key string, write pendingWrite) error {
+	var local json.RawMessage
+	if !write.deleted {
+		local = write.data
+	}
+
+	s.mu.Lock()
+	policy := s.policy
+	observed := s.observed[key]
+	s.mu.Unlock()
+
+	resolved := local
+	if policy != nil {
+		// Read the current value from the primary Store, in order to
+		// determine if it changed since it was last observed.
+		var primary json.RawMessage
+		if err := s.primary.Get(ctx, key, &primary); err != nil {
+			if !errors.Is(err, ErrorKeyNotFound) {
+				return err
+			}
+			primary = nil
+		}
+
+		// A key that was never observed is treated as having not existed.
+		if !bytes.Equal(primary, observed) {
+			var err error
+			resolved, err = policy(ctx, Conflict{
+				Key:      key,
+				Primary:  primary,
+				Local:    local,
+				Modified: write.modified,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if resolved == nil {
+		err := s.primary.Delete(ctx, key)
+		// The key may have never existed in the first place.
+		if err != nil && !isKeyNotFound(err) {
+			return err
+		}
+		// Intentionally ignore any errors, as this is non-essential.
+		_ = s.local.Delete(ctx, key)
+	} else {
+		if err := s.primary.Set(ctx, key, resolved); err != nil {
+			return err
+		}
+		// Intentionally ignore any errors, as this is non-essential.
+		_ = s.local.Set(ctx, key, resolved)
+	}
+
+	s.remember(key, resolved)
+	return nil
+}
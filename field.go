@@ -0,0 +1,76 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// GetField reads the value of the named key from the given Store, and stores
+// only the field selected by the given JSONPath expression into the given
+// value pointer, such as "{.spec.replicas}" or ".items[0].name". The enclosing
+// braces may be omitted.
+//
+// If the expression selects multiple fields, such as when using wildcards or
+// filters, they are stored as an array. If the expression does not select any
+// field, an error is returned.
+func GetField(ctx context.Context, store Store, key, path string, value interface{}) error {
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+
+	parser := jsonpath.New(key)
+	if err := parser.Parse(path); err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := store.Get(ctx, key, &data); err != nil {
+		return err
+	}
+
+	// Decode numbers as a json.Number, so that they are passed through to
+	// the given value pointer without losing precision.
+	var document interface{}
+	if err := unmarshalNumber(data, &document); err != nil {
+		return err
+	}
+
+	results, err := parser.FindResults(document)
+	if err != nil {
+		return err
+	}
+
+	var fields []interface{}
+	for _, result := range results {
+		for _, field := range result {
+			fields = append(fields, field.Interface())
+		}
+	}
+
+	// Wildcards and filters that match nothing do not cause an error when
+	// finding results, so check for that here.
+	if len(fields) == 0 {
+		return fmt.Errorf("%s: no fields selected", path)
+	}
+
+	var selected interface{} = fields
+	if len(fields) == 1 {
+		selected = fields[0]
+	}
+
+	encoded, err := json.Marshal(selected)
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(encoded, value)
+}
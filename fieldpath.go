@@ -0,0 +1,233 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// Assert that fieldPathStore implements the Store interface.
+var _ Store = fieldPathStore{}
+
+type fieldPathStore struct {
+	client    dynamic.ResourceInterface
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+	fields    []string
+}
+
+// NewFieldPathStore returns a Store backed by a map of strings located at the
+// given field path within a resource, such as ".spec.kubestore" or
+// ".status.kubestore" within a custom resource.
+//
+// Field paths beneath .status are written using the status subresource. The
+// schema of the custom resource must permit an object with arbitrary string
+// values at the given field path, such as by using
+// x-kubernetes-preserve-unknown-fields or additionalProperties.
+//
+// This Store is intended to be used when running inside of a pod, as it
+// depends on the presence of a service account in order to interact with the
+// Kubernetes API.
+func NewFieldPathStore(group, version, resource, name, fieldPath string, opts ...Option) (Store, error) {
+	// Split the field path into individual fields.
+	fields := strings.Split(strings.TrimPrefix(fieldPath, "."), ".")
+	for _, field := range fields {
+		if field == "" {
+			return nil, fmt.Errorf("invalid field path %q", fieldPath)
+		}
+	}
+
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Lookup the current pod's namespace.
+	namespace, err := inClusterNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a dynamic Kubernetes client.
+	dynclient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// We're only interested in the client for this specific resource.
+	gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+	client := dynclient.Resource(gvr).Namespace(namespace)
+
+	return wrap(&fieldPathStore{
+		client:    client,
+		gvr:       gvr,
+		namespace: namespace,
+		name:      name,
+		fields:    fields,
+	}, newOptions(opts)), nil
+}
+
+// read is a helper for reading the map located at the field path within the
+// backing resource.
+func (c fieldPathStore) read(ctx context.Context) (map[string]string, error) {
+	// Use the Kuberneties API to get the backing resource.
+	resource, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := unstructured.NestedStringMap(resource.Object, c.fields...)
+	return data, err
+}
+
+// patch is a helper for applying a merge patch to the map located at the
+// field path within the backing resource. A nil value represents the deletion
+// of that entry.
+func (c fieldPathStore) patch(ctx context.Context, key string, value interface{}) error {
+	// Construct a patch, nesting the entry within each field of the field
+	// path, from the innermost field outwards.
+	var patch interface{} = map[string]interface{}{key: value}
+	for i := len(c.fields) - 1; i >= 0; i-- {
+		patch = map[string]interface{}{c.fields[i]: patch}
+	}
+
+	// Convert the patch to JSON.
+	payload, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	// Fields beneath status can only be written using the status
+	// subresource.
+	var subresources []string
+	if c.fields[0] == "status" {
+		subresources = []string{"status"}
+	}
+
+	// Use the Kuberneties API to patch the backing resource.
+	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx), subresources...)
+	return err
+}
+
+// Get reads the named entry from the map at the field path and stores the
+// contents into the given value pointer.
+//
+// If the backing resource does not exist, an error matching both the
+// ErrorResourceMissing and ErrorKeyNotFound sentinel errors is returned.
+func (c fieldPathStore) Get(ctx context.Context, key string, value interface{}) error {
+	entries, err := c.read(ctx)
+	if err != nil {
+		// If the backing resource does not exist, then the key also does not
+		// exist, so return an error matching both sentinel errors.
+		if isResourceMissingError(err) {
+			return resourceMissingError{err: err, keyNotFound: true}
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	// Lookup the given key in the map.
+	data, found := entries[key]
+	if !found {
+		// The given key does not exist in the map, so return the not found
+		// sentinel error.
+		return ErrorKeyNotFound
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal([]byte(data), value)
+}
+
+// Set writes the named entry and value into the map at the field path.
+//
+// If the backing resource does not exist, an error matching the
+// ErrorResourceMissing sentinel error is returned.
+func (c fieldPathStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if err := c.patch(ctx, key, string(data)); err != nil {
+		// The backing resource is not created on-demand, so report that it
+		// does not exist.
+		if isResourceMissingError(err) {
+			return resourceMissingError{err: err}
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	return nil
+}
+
+// List finds all entries in the map at the field path and returns a list of
+// keys that can be used in subsequent calls to Store.Get or Store.Delete.
+//
+// If the backing resource does not exist, no keys are returned.
+func (c fieldPathStore) List(ctx context.Context) ([]string, error) {
+	entries, err := c.read(ctx)
+	if err != nil {
+		// If the backing resource does not exist, then the keys also no not
+		// exist, so return an empty (nil) slice.
+		if isResourceMissingError(err) {
+			return nil, nil
+		}
+		// Some other kind of error was encountered.
+		return nil, err
+	}
+
+	// Build a list of all the keys.
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Delete removes the named entry from the map at the field path.
+func (c fieldPathStore) Delete(ctx context.Context, key string) error {
+	// Use a hardcoded value of null as that will cause the merge patch to
+	// delete the named key.
+	if err := c.patch(ctx, key, nil); err != nil {
+		// If the backing resource does not exist, then the key also does not
+		// exist, so there's nothing else to do.
+		if isResourceMissingError(err) {
+			return nil
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	return nil
+}
+
+// Describe returns a description of the backing resource.
+func (c fieldPathStore) Describe() Description {
+	return Description{
+		Backend:   "fieldpath",
+		Resource:  path.Join(c.gvr.Group, c.gvr.Version, c.gvr.Resource),
+		Namespace: c.namespace,
+		Name:      c.name,
+		Options: map[string]string{
+			"fieldPath": "." + strings.Join(c.fields, "."),
+		},
+	}
+}
@@ -5,11 +5,16 @@
 package kubestore
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 )
 
 // Assert that fileStore implements the Store interface.
@@ -17,6 +22,25 @@ var _ Store = fileStore{}
 
 type fileStore struct {
 	directory string
+	listing   *listingCache
+}
+
+// listingCache holds the most recent listing of the backing directory, along
+// with the modification time of the directory when that listing was taken.
+type listingCache struct {
+	sync.Mutex
+	modTime time.Time
+	keys    []string
+	valid   bool
+}
+
+// invalidate discards the cached listing, forcing the next call to
+// Store.List to re-read the backing directory.
+func (c *listingCache) invalidate() {
+	c.Lock()
+	defer c.Unlock()
+	c.valid = false
+	c.keys = nil
 }
 
 // NewFileStore returns a Store backed by files contained within the given
@@ -30,10 +54,11 @@ type fileStore struct {
 // directory as it will be created on-demand when calling Store.Set and
 // automatically deleted when calling Store.Delete (in the event that it does
 // not contain any other files).
-func NewFileStore(directory string) Store {
-	return &fileStore{
+func NewFileStore(directory string, opts ...Option) Store {
+	return wrap(&fileStore{
 		directory: directory,
-	}
+		listing:   &listingCache{},
+	}, newOptions(opts))
 }
 
 // Get reads the named file from the backing directory and stores the contents
@@ -41,11 +66,11 @@ func NewFileStore(directory string) Store {
 //
 // If the backing file does not exist, the ErrorKeyNotFound sentinel error
 // is returned.
-func (s fileStore) Get(_ context.Context, key string, value interface{}) error {
+func (s fileStore) Get(ctx context.Context, key string, value interface{}) error {
 	// Determine the name of the backing file.
 	filename := filepath.Join(s.directory, key)
 
-	data, err := ioutil.ReadFile(filename)
+	data, err := readFile(ctx, filename)
 	if err != nil {
 		// If the backing file does not exist, then return the not found
 		// sentinel error.
@@ -63,21 +88,40 @@ func (s fileStore) Get(_ context.Context, key string, value interface{}) error {
 // Set writes the given value into the backing file.
 //
 // If the backing directory does not exist, it is created on-demand.
-func (s fileStore) Set(_ context.Context, key string, value interface{}) error {
-	// Determine the name of the backing file.
-	filename := filepath.Join(s.directory, key)
-
+func (s fileStore) Set(ctx context.Context, key string, value interface{}) error {
 	// Marshal the the given value as JSON.
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
 
+	defer s.lock()()
+	return s.set(ctx, key, data)
+}
+
+// set is a helper for writing the given data into the backing file. The caller
+// must hold the lock for the backing directory.
+func (s fileStore) set(ctx context.Context, key string, data []byte) error {
+	// Once started, a write is never interrupted, so that the backing file is
+	// never left partially written.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Determine the name of the backing file.
+	filename := filepath.Join(s.directory, key)
+
 	// Create a directory to contain the backing file.
 	if err := os.MkdirAll(s.directory, 0755); err != nil {
 		return err
 	}
 
+	// The set of keys may have changed, so discard any cached listing once
+	// the backing file has been written. Discarding it beforehand would allow
+	// a concurrent call to Store.List to cache the listing from before the
+	// write.
+	defer s.listing.invalidate()
+
 	// Write the value to the backing file.
 	return ioutil.WriteFile(filename, data, 0644)
 }
@@ -85,33 +129,90 @@ func (s fileStore) Set(_ context.Context, key string, value interface{}) error {
 // List finds all files in the backing directory and returns a list of keys
 // that can be used in subsequent calls to Store.Get or Store.Delete.
 //
-// If the backing directory does not exist, no keys are returned.
-func (s fileStore) List(_ context.Context) ([]string, error) {
-	// Stat all files in the backing directory.
-	infos, err := ioutil.ReadDir(s.directory)
+// The listing is cached, and is only re-read when the backing directory has
+// been modified, or after a call to Store.Set or Store.Delete. Only the names
+// of the directory entries are read, so individual files are never stat'd.
+//
+// If the backing directory does not exist, no keys are returned. Any other
+// error encountered while reading the backing directory, such as permission
+// being denied, is returned.
+func (s fileStore) List(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Stat the backing directory itself, in order to determine if it has been
+	// modified since it was last listed.
+	info, err := os.Stat(s.directory)
 	if err != nil {
 		// If the backing directory does not exist, then the keys also no not
 		// exist, so return an empty (nil) slice.
-		return nil, nil
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		// Some other kind of error was encountered.
+		return nil, err
 	}
 
-	// Build a list of all the keys.
-	keys := make([]string, len(infos))
-	for i, info := range infos {
-		keys[i] = info.Name()
+	s.listing.Lock()
+	defer s.listing.Unlock()
+
+	// Serve the cached listing if the backing directory is unchanged.
+	if s.listing.valid && s.listing.modTime.Equal(info.ModTime()) {
+		return append([]string(nil), s.listing.keys...), nil
 	}
 
-	return keys, nil
+	// Read the names of all files in the backing directory.
+	dir, err := os.Open(s.directory)
+	if err != nil {
+		// The backing directory may have been deleted since it was stat'd.
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		// Some other kind of error was encountered.
+		return nil, err
+	}
+	defer dir.Close()
+
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the keys in lexical order, consistent with ioutil.ReadDir.
+	sort.Strings(names)
+
+	// Cache the listing for subsequent calls.
+	s.listing.modTime = info.ModTime()
+	s.listing.keys = names
+	s.listing.valid = true
+
+	return append([]string(nil), names...), nil
 }
 
 // Delete removes the named file from the backing directory.
 //
 // If the backing directory is empty (if it contains no other files), it is
 // also deleted.
-func (s fileStore) Delete(_ context.Context, key string) error {
+func (s fileStore) Delete(ctx context.Context, key string) error {
+	defer s.lock()()
+	return s.delete(ctx, key)
+}
+
+// delete is a helper for removing the backing file. The caller must hold the
+// lock for the backing directory.
+func (s fileStore) delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Determine the name of the backing file.
 	filename := filepath.Join(s.directory, key)
 
+	// The set of keys has changed, so discard any cached listing once the
+	// backing file has been deleted.
+	defer s.listing.invalidate()
+
 	// Delete the backing file.
 	if err := os.Remove(filename); err != nil {
 		return err
@@ -125,3 +226,35 @@ func (s fileStore) Delete(_ context.Context, key string) error {
 
 	return nil
 }
+
+// readChunkSize is the amount of data read from a backing file at a time,
+// between checks for the cancellation of the context.
+const readChunkSize = 64 * 1024
+
+// readFile reads the contents of the named file, in chunks so that reading a
+// large file can be interrupted by the cancellation of the given context.
+func readFile(ctx context.Context, filename string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var buf bytes.Buffer
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Copying fewer bytes than requested results in io.EOF.
+		if _, err := io.CopyN(&buf, file, readChunkSize); err == io.EOF {
+			return buf.Bytes(), nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Package flags provides typed, live-reloadable feature flags backed by a
+// kubestore.Store.
+package flags
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/joshdk/kubestore"
+)
+
+// pollInterval is how often a flag is read when subscribing to a Store that
+// does not implement the kubestore.Subscriber interface.
+const pollInterval = 10 * time.Second
+
+// Flags provides typed access to feature flags, where each flag is stored as
+// a single key in a kubestore.Store.
+//
+// Flag values are read from the Store on every access, so changes take effect
+// immediately. Wrapping the Store with a kubestore.CacheStore can be used to
+// reduce the number of reads, at the expense of changes taking effect after a
+// delay.
+type Flags struct {
+	store kubestore.Store
+}
+
+// New returns a Flags backed by the given Store.
+func New(store kubestore.Store) *Flags {
+	return &Flags{
+		store: store,
+	}
+}
+
+// Bool returns a BoolFlag with the given name and default value.
+func (f *Flags) Bool(name string, def bool) *BoolFlag {
+	return &BoolFlag{flag: flag{store: f.store, name: name}, def: def}
+}
+
+// Int returns an IntFlag with the given name and default value.
+func (f *Flags) Int(name string, def int) *IntFlag {
+	return &IntFlag{flag: flag{store: f.store, name: name}, def: def}
+}
+
+// String returns a StringFlag with the given name and default value.
+func (f *Flags) String(name string, def string) *StringFlag {
+	return &StringFlag{flag: flag{store: f.store, name: name}, def: def}
+}
+
+// flag holds the details common to all flag types.
+type flag struct {
+	store kubestore.Store
+	name  string
+}
+
+// read reads the value of the flag into the given value pointer, and returns
+// true if successful.
+func (f flag) read(ctx context.Context, value interface{}) bool {
+	return f.store.Get(ctx, f.name, value) == nil
+}
+
+// subscribe calls the changed function whenever the flag may have changed,
+// until the given context is done, after which the closed function is called.
+//
+// If the Store does not implement the kubestore.Subscriber interface, the flag
+// is polled instead.
+func (f flag) subscribe(ctx context.Context, changed, closed func()) error {
+	events, err := kubestore.Subscribe(ctx, f.store)
+	if errors.Is(err, kubestore.ErrorNotSupported) {
+		go func() {
+			defer closed()
+			f.poll(ctx, changed)
+		}()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer closed()
+		for event := range events {
+			if event.Key == f.name {
+				changed()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// poll reads the flag at a regular interval, and calls the changed function
+// whenever its value has changed, until the given context is done.
+func (f flag) poll(ctx context.Context, changed func()) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var previous json.RawMessage
+	f.read(ctx, &previous)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// A flag that could not be read is treated as unset.
+		var current json.RawMessage
+		f.read(ctx, &current)
+		if !bytes.Equal(current, previous) {
+			previous = current
+			changed()
+		}
+	}
+}
+
+// BoolFlag is a feature flag with a boolean value.
+type BoolFlag struct {
+	flag
+	def bool
+}
+
+// Value returns the current value of the flag, or the default value if the
+// flag is not set or could not be read.
+func (f *BoolFlag) Value(ctx context.Context) bool {
+	value := f.def
+	if !f.read(ctx, &value) {
+		return f.def
+	}
+	return value
+}
+
+// Subscribe returns a channel which receives the value of the flag whenever it
+// changes. The channel is closed once the given context is done. If the Store
+// does not implement the kubestore.Subscriber interface, the flag is polled
+// for changes every 10 seconds instead.
+func (f *BoolFlag) Subscribe(ctx context.Context) (<-chan bool, error) {
+	values := make(chan bool, 1)
+	err := f.subscribe(ctx, func() {
+		select {
+		case values <- f.Value(ctx):
+		case <-ctx.Done():
+		}
+	}, func() {
+		close(values)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// IntFlag is a feature flag with an integer value.
+type IntFlag struct {
+	flag
+	def int
+}
+
+// Value returns the current value of the flag, or the default value if the
+// flag is not set or could not be read.
+func (f *IntFlag) Value(ctx context.Context) int {
+	value := f.def
+	if !f.read(ctx, &value) {
+		return f.def
+	}
+	return value
+}
+
+// Subscribe returns a channel which receives the value of the flag whenever it
+// changes. The channel is closed once the given context is done. If the Store
+// does not implement the kubestore.Subscriber interface, the flag is polled
+// for changes every 10 seconds instead.
+func (f *IntFlag) Subscribe(ctx context.Context) (<-chan int, error) {
+	values := make(chan int, 1)
+	err := f.subscribe(ctx, func() {
+		select {
+		case values <- f.Value(ctx):
+		case <-ctx.Done():
+		}
+	}, func() {
+		close(values)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// StringFlag is a feature flag with a string value.
+type StringFlag struct {
+	flag
+	def string
+}
+
+// Value returns the current value of the flag, or the default value if the
+// flag is not set or could not be read.
+func (f *StringFlag) Value(ctx context.Context) string {
+	value := f.def
+	if !f.read(ctx, &value) {
+		return f.def
+	}
+	return value
+}
+
+// Subscribe returns a channel which receives the value of the flag whenever it
+// changes. The channel is closed once the given context is done. If the Store
+// does not implement the kubestore.Subscriber interface, the flag is polled
+// for changes every 10 seconds instead.
+func (f *StringFlag) Subscribe(ctx context.Context) (<-chan string, error) {
+	values := make(chan string, 1)
+	err := f.subscribe(ctx, func() {
+		select {
+		case values <- f.Value(ctx):
+		case <-ctx.Done():
+		}
+	}, func() {
+		close(values)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
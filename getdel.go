@@ -0,0 +1,40 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// GetDel reads the named entry from the given Store, stores the contents into
+// the given value pointer, and removes the entry, as a single operation. This
+// is useful for one-shot tokens and for handing off values between pods, as
+// exactly one of many concurrent callers receives the value.
+//
+// The entry is removed atomically using the preconditions of the Updater
+// interface, rather than being read and removed separately. If the Store does
+// not implement the Updater interface, an error matching the
+// ErrorNotSupported sentinel error is returned. If the entry does not exist,
+// the ErrorKeyNotFound sentinel error is returned.
+func GetDel(ctx context.Context, store Store, key string, value interface{}) error {
+	var data json.RawMessage
+	err := Update(ctx, store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		if current == nil {
+			return nil, ErrorKeyNotFound
+		}
+
+		// Record the value from the latest attempt, as the function is called
+		// again if the entry was concurrently modified.
+		data = current
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(data, value)
+}
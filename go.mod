@@ -3,7 +3,9 @@ module github.com/joshdk/kubestore
 go 1.15
 
 require (
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	k8s.io/api v0.20.0
 	k8s.io/apimachinery v0.20.0
 	k8s.io/client-go v0.20.0
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -0,0 +1,291 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// helmSecretType is the type of the Secrets used by Helm for storing
+	// releases.
+	helmSecretType = "helm.sh/release.v1"
+
+	// helmDataKey is the Secret data key that holds the encoded release.
+	helmDataKey = "release"
+)
+
+// helmMagic is the header of gzip compressed data, which Helm uses to detect
+// whether a release is compressed.
+var helmMagic = []byte{0x1f, 0x8b, 0x08}
+
+// Assert that helmStore implements the Store interface.
+var _ Store = helmStore{}
+
+type helmStore struct {
+	client    v1.SecretInterface
+	namespace string
+}
+
+// NewHelmReleaseStore returns a Store backed by Secrets that follow Helm's
+// release storage conventions, so that Helm release data can be inspected, and
+// written in a form that Helm can read.
+//
+// Each key is the name of a release, and each value is a release encoded as
+// JSON. Every call to Store.Set creates a new revision of the release, stored
+// in a Secret named sh.helm.release.v1.<name>.v<revision> with the release
+// gzip compressed and base64 encoded, along with the name, owner, status, and
+// version labels. Calls to Store.Get return the latest revision, and calls to
+// Store.Delete remove every revision.
+//
+// This Store is intended to be used when running inside of a pod, as it
+// depends on the presence of a service account in order to interact with the
+// Kubernetes API.
+func NewHelmReleaseStore(opts ...Option) (Store, error) {
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Lookup the current pod's namespace.
+	namespace, err := inClusterNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a set of Kubernetes clients.
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// We're only interested in the Secrets client.
+	client := clientSet.CoreV1().Secrets(namespace)
+
+	return wrap(&helmStore{
+		client:    client,
+		namespace: namespace,
+	}, newOptions(opts)), nil
+}
+
+// revisions returns every Secret holding a revision of the named release, or
+// of every release if the name is empty, ordered by revision.
+func (c helmStore) revisions(ctx context.Context, name string) ([]apiv1.Secret, error) {
+	labels := map[string]string{"owner": "helm"}
+	if name != "" {
+		labels["name"] = name
+	}
+
+	// Use the Kuberneties API to list the Secrets for the release.
+	secrets, err := c.client.List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(metav1.SetAsLabelSelector(labels)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := secrets.Items
+	sort.Slice(items, func(i, j int) bool {
+		return helmRevision(items[i]) < helmRevision(items[j])
+	})
+	return items, nil
+}
+
+// helmRevision returns the revision of the release held by the given Secret.
+func helmRevision(secret apiv1.Secret) int {
+	revision, _ := strconv.Atoi(secret.Labels["version"])
+	return revision
+}
+
+// Get reads the latest revision of the named release and stores the decoded
+// release into the given value pointer.
+//
+// If the release does not exist, the ErrorKeyNotFound sentinel error is
+// returned.
+func (c helmStore) Get(ctx context.Context, key string, value interface{}) error {
+	revisions, err := c.revisions(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		return ErrorKeyNotFound
+	}
+
+	data, err := decodeHelmRelease(revisions[len(revisions)-1].Data[helmDataKey])
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(data, value)
+}
+
+// Set writes the given release as a new revision of the named release.
+func (c helmStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := encodeHelmRelease(data)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := c.revisions(ctx, key)
+	if err != nil {
+		return err
+	}
+	revision := 1
+	if len(revisions) > 0 {
+		revision = helmRevision(revisions[len(revisions)-1]) + 1
+	}
+
+	// Use the status of the release, if it has one.
+	var release struct {
+		Info struct {
+			Status string `json:"status"`
+		} `json:"info"`
+	}
+	_ = json.Unmarshal(data, &release)
+	status := release.Info.Status
+	if status == "" {
+		status = "unknown"
+	}
+
+	// Use the Kuberneties API to create the Secret for the revision. A
+	// conflict indicates that the revision was concurrently created.
+	_, err = c.client.Create(ctx, &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("sh.helm.release.v1.%s.v%d", key, revision),
+			Labels: map[string]string{
+				"name":    key,
+				"owner":   "helm",
+				"status":  status,
+				"version": strconv.Itoa(revision),
+			},
+		},
+		Type: helmSecretType,
+		Data: map[string][]byte{helmDataKey: encoded},
+	}, createOptions(ctx))
+	if isConflictError(err) {
+		return fmt.Errorf("%w: %v", ErrorConflict, err)
+	}
+	return err
+}
+
+// List returns the names of every release.
+func (c helmStore) List(ctx context.Context) ([]string, error) {
+	revisions, err := c.revisions(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, revision := range revisions {
+		name := revision.Labels["name"]
+		if !seen[name] {
+			seen[name] = true
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Delete removes every revision of the named release.
+//
+// If the release does not exist, the ErrorKeyNotFound sentinel error is
+// returned.
+func (c helmStore) Delete(ctx context.Context, key string) error {
+	revisions, err := c.revisions(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		return ErrorKeyNotFound
+	}
+
+	for _, revision := range revisions {
+		// Use the Kuberneties API to delete the Secret for the revision.
+		err := c.client.Delete(ctx, revision.Name, metav1.DeleteOptions{})
+		if err != nil && !isResourceMissingError(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Describe returns a description of the Secrets backing the Store.
+func (c helmStore) Describe() Description {
+	return Description{
+		Backend:   "helm",
+		Resource:  "v1/secrets",
+		Namespace: c.namespace,
+		Name:      "sh.helm.release.v1.*",
+	}
+}
+
+// encodeHelmRelease gzip compresses and base64 encodes the given release, as
+// Helm does.
+func encodeHelmRelease(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(buf.Len()))
+	base64.StdEncoding.Encode(encoded, buf.Bytes())
+	return encoded, nil
+}
+
+// decodeHelmRelease base64 decodes, and gzip decompresses if compressed, the
+// given release, as Helm does.
+func decodeHelmRelease(encoded []byte) ([]byte, error) {
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	n, err := base64.StdEncoding.Decode(data, encoded)
+	if err != nil {
+		return nil, err
+	}
+	data = data[:n]
+
+	if !bytes.HasPrefix(data, helmMagic) {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
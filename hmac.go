@@ -0,0 +1,171 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TamperError is returned when a value read when calling Store.Get has a
+// signature that does not match its contents. It matches ErrorTampered when
+// using errors.Is.
+type TamperError struct {
+	// Key is the name of the key whose value was tampered with.
+	Key string
+}
+
+func (e TamperError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrorTampered, e.Key)
+}
+
+func (e TamperError) Is(target error) bool {
+	return target == ErrorTampered
+}
+
+// signedEnvelope is the stored form of a signed value.
+type signedEnvelope struct {
+	Value json.RawMessage `json:"value"`
+	MAC   []byte          `json:"mac"`
+}
+
+// Assert that hmacStore implements the Store interface.
+var _ Store = hmacStore{}
+
+type hmacStore struct {
+	store Store
+	key   []byte
+}
+
+// Get reads the named entry from the wrapped Store, verifies its signature,
+// and stores the contents into the given value pointer.
+//
+// If the signature does not match, a TamperError is returned.
+func (s hmacStore) Get(ctx context.Context, key string, value interface{}) error {
+	var data json.RawMessage
+	if err := s.store.Get(ctx, key, &data); err != nil {
+		return err
+	}
+
+	verified, err := s.verify(key, data)
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(verified, value)
+}
+
+// Set signs the given value, and writes it into the wrapped Store.
+func (s hmacStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	signed, err := s.sign(key, data)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Set(ctx, key, signed)
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s hmacStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s hmacStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s hmacStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s hmacStore) resourceVersion(ctx context.Context) (string, error) {
+	return resourceVersionOf(ctx, s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s hmacStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// Dump writes the raw contents of the wrapped Store.
+func (s hmacStore) Dump(ctx context.Context, w io.Writer, opts ...Option) error {
+	return Dump(ctx, s.store, w, opts...)
+}
+
+// Update atomically modifies the named entry in the wrapped Store, verifying
+// the signature of its current contents and signing the result.
+func (s hmacStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		var value json.RawMessage
+		if current != nil {
+			var err error
+			if value, err = s.verify(key, current); err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := fn(value)
+		if err != nil || result == nil {
+			return nil, err
+		}
+
+		return s.sign(key, result)
+	})
+}
+
+// mac computes the signature of the given value, bound to the given key name.
+func (s hmacStore) mac(key string, data []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	// Write the length of the key name first, so that the boundary between
+	// the key name and value is unambiguous.
+	fmt.Fprintf(mac, "%d:%s", len(key), key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// sign wraps the given value in an envelope along with its signature.
+func (s hmacStore) sign(key string, data []byte) (json.RawMessage, error) {
+	// Marshalling the envelope compacts the value, so the value is first
+	// compacted in the same way, in order to sign the exact data that is
+	// stored.
+	data, err := json.Marshal(json.RawMessage(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(signedEnvelope{
+		Value: data,
+		MAC:   s.mac(key, data),
+	})
+}
+
+// verify unwraps the given envelope, and checks that its signature matches.
+func (s hmacStore) verify(key string, data []byte) (json.RawMessage, error) {
+	var envelope signedEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, TamperError{Key: key}
+	}
+
+	if !hmac.Equal(envelope.MAC, s.mac(key, envelope.Value)) {
+		return nil, TamperError{Key: key}
+	}
+
+	return envelope.Value, nil
+}
@@ -0,0 +1,70 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestHMACUpdateRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir(), WithHMAC([]byte("secret")))
+
+	// Results that are not compact, or that contain characters which are
+	// escaped when marshalled, must still verify once stored.
+	for _, result := range []string{`{"x": 1}`, `{"html":"<a href='#'>&</a>"}`, "[\n  1,\n  2\n]"} {
+		err := Update(ctx, store, "key", func(json.RawMessage) (json.RawMessage, error) {
+			return json.RawMessage(result), nil
+		})
+		if err != nil {
+			t.Fatalf("update %s: %v", result, err)
+		}
+
+		var value interface{}
+		if err := store.Get(ctx, "key", &value); err != nil {
+			t.Fatalf("get %s: %v", result, err)
+		}
+
+		var expected interface{}
+		if err := json.Unmarshal([]byte(result), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(mustMarshal(t, value), mustMarshal(t, expected)) {
+			t.Fatalf("expected %v, got %v", expected, value)
+		}
+	}
+}
+
+func TestHMACDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	directory := t.TempDir()
+	store := NewFileStore(directory, WithHMAC([]byte("secret")))
+
+	if err := store.Set(ctx, "key", map[string]int{"x": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write a modified value directly, without a valid signature.
+	if err := NewFileStore(directory).Set(ctx, "key", signedEnvelope{Value: json.RawMessage(`{"x":2}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Get(ctx, "key", new(interface{})); !errors.Is(err, ErrorTampered) {
+		t.Fatalf("expected a tampered value, got %v", err)
+	}
+}
+
+// mustMarshal marshals the given value as JSON, failing the test otherwise.
+func mustMarshal(t *testing.T, value interface{}) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
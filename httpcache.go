@@ -0,0 +1,68 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// HTTPCache adapts a Store to the Get, Set, and Delete methods of the Cache
+// interface from github.com/gregjones/httpcache, so that cached HTTP responses
+// can persist across pod restarts.
+//
+// Cache keys (which are typically URLs) are hashed in order to form valid
+// Store keys. As the Cache interface does not report errors, any errors
+// encountered are treated as a cache miss.
+type HTTPCache struct {
+	store Store
+
+	// Timeout bounds each operation made against the Store.
+	Timeout time.Duration
+}
+
+// NewHTTPCache returns an HTTPCache backed by the given Store.
+func NewHTTPCache(store Store) *HTTPCache {
+	return &HTTPCache{
+		store:   store,
+		Timeout: 10 * time.Second,
+	}
+}
+
+// Get returns the cached response for the given key, and true if it exists.
+func (c *HTTPCache) Get(key string) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	var response []byte
+	if err := c.store.Get(ctx, httpCacheKey(key), &response); err != nil {
+		return nil, false
+	}
+	return response, true
+}
+
+// Set caches the given response under the given key.
+func (c *HTTPCache) Set(key string, response []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	_ = c.store.Set(ctx, httpCacheKey(key), response)
+}
+
+// Delete removes the cached response for the given key.
+func (c *HTTPCache) Delete(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	_ = c.store.Delete(ctx, httpCacheKey(key))
+}
+
+// httpCacheKey returns the Store key used for the given cache key.
+func httpCacheKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "httpcache-" + hex.EncodeToString(sum[:])
+}
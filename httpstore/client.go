@@ -0,0 +1,154 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package httpstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/joshdk/kubestore"
+)
+
+// Assert that Client implements the kubestore.Store interface.
+var _ kubestore.Store = (*Client)(nil)
+
+// Client is a kubestore.Store that uses a Store served by a Handler.
+type Client struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+// NewClient returns a Client for the Handler served at the given base URL,
+// such as "http://kubestore:8080". If the given token is not empty, it is sent
+// as a bearer token with every request. If the given HTTP client is nil,
+// http.DefaultClient is used.
+func NewClient(endpoint, token string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		token:    token,
+		client:   client,
+	}
+}
+
+// Get reads the named entry and stores the contents into the given value
+// pointer.
+//
+// If the entry does not exist, the ErrorKeyNotFound sentinel error is
+// returned.
+func (c *Client) Get(ctx context.Context, key string, value interface{}) error {
+	body, err := c.do(ctx, http.MethodGet, keyPath(key), nil)
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(body, value)
+}
+
+// Set writes the named entry and value.
+func (c *Client) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(ctx, http.MethodPut, keyPath(key), data)
+	return err
+}
+
+// List returns a list of all keys.
+func (c *Client) List(ctx context.Context) ([]string, error) {
+	body, err := c.do(ctx, http.MethodGet, keysPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Delete removes the named entry.
+//
+// If the entry does not exist, the ErrorKeyNotFound sentinel error is
+// returned.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, keyPath(key), nil)
+	return err
+}
+
+// Describe returns a description of the Client.
+func (c *Client) Describe() kubestore.Description {
+	return kubestore.Description{
+		Backend: "http",
+		Name:    c.endpoint,
+	}
+}
+
+// keyPath returns the path at which the given key is served.
+func keyPath(key string) string {
+	return keysPath + "/" + url.PathEscape(key)
+}
+
+// do sends a request with the given method, path, and body, and returns the
+// body of a successful response.
+func (c *Client) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return data, nil
+	}
+
+	// Decode the error message, and wrap the sentinel error corresponding to
+	// the status code.
+	var response errorResponse
+	if err := json.Unmarshal(data, &response); err != nil || response.Error == "" {
+		response.Error = resp.Status
+	}
+	for _, code := range statusCodes {
+		if code.status == resp.StatusCode {
+			return nil, fmt.Errorf("%w: %s", code.err, response.Error)
+		}
+	}
+	return nil, fmt.Errorf("%s %s: %s", method, path, response.Error)
+}
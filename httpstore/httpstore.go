@@ -0,0 +1,210 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Package httpstore provides an HTTP frontend for a kubestore.Store, along
+// with a client which is itself a kubestore.Store, so that processes without
+// access to the Kubernetes API can use a Store through a gateway.
+//
+// The following endpoints are served:
+//
+//	GET    /keys        list every key, as a JSON array of strings
+//	GET    /keys/<key>  get the value of a key, as JSON
+//	PUT    /keys/<key>  set the value of a key, given as JSON
+//	DELETE /keys/<key>  remove a key
+//
+// Errors are returned as a JSON object with an "error" field, using a status
+// code that corresponds to the kubestore sentinel error, such as 404 for
+// ErrorKeyNotFound.
+package httpstore
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/joshdk/kubestore"
+)
+
+// keysPath is the path under which keys are served.
+const keysPath = "/keys"
+
+// DefaultMaxBodyBytes is the default limit on the size of a value given to
+// PUT, which is larger than any single Kubernetes object may be.
+const DefaultMaxBodyBytes = 4 * 1024 * 1024
+
+// Handler serves a kubestore.Store over HTTP.
+type Handler struct {
+	store kubestore.Store
+	token string
+
+	// MaxBodyBytes is the limit on the size of a value given to PUT, which
+	// defaults to DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+}
+
+// NewHandler returns a Handler that serves the given Store. If the given
+// token is not empty, every request must carry it as a bearer token in the
+// Authorization header.
+func NewHandler(store kubestore.Store, token string) *Handler {
+	return &Handler{
+		store:        store,
+		token:        token,
+		MaxBodyBytes: DefaultMaxBodyBytes,
+	}
+}
+
+// ServeHTTP serves a single request.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kubestore"`)
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	switch {
+	case r.URL.Path == keysPath || r.URL.Path == keysPath+"/":
+		h.serveList(w, r)
+	case strings.HasPrefix(r.URL.Path, keysPath+"/"):
+		h.serveKey(w, r, strings.TrimPrefix(r.URL.Path, keysPath+"/"))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+	}
+}
+
+// authorized returns true if the given request carries the configured token,
+// using a constant time comparison.
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return true
+	}
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, prefix)), []byte(h.token)) == 1
+}
+
+// serveList serves the list of every key.
+func (h *Handler) serveList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	keys, err := kubestore.ListSorted(r.Context(), h.store)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// serveKey serves a single key.
+func (h *Handler) serveKey(w http.ResponseWriter, r *http.Request, key string) {
+	if key == "" {
+		writeError(w, http.StatusNotFound, errors.New("key is required"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var value json.RawMessage
+		if err := h.store.Get(r.Context(), key, &value); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, value)
+
+	case http.MethodPut:
+		limit := h.MaxBodyBytes
+		if limit <= 0 {
+			limit = DefaultMaxBodyBytes
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if int64(len(body)) > limit {
+			writeError(w, http.StatusRequestEntityTooLarge, kubestore.ErrorValueTooLarge)
+			return
+		}
+		if !json.Valid(body) {
+			writeError(w, http.StatusBadRequest, errors.New("value is not valid JSON"))
+			return
+		}
+		if err := h.store.Set(r.Context(), key, json.RawMessage(body)); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if err := h.store.Delete(r.Context(), key); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// statusCodes maps sentinel errors to the status codes used to report them.
+var statusCodes = []struct {
+	err    error
+	status int
+}{
+	{kubestore.ErrorKeyNotFound, http.StatusNotFound},
+	{os.ErrNotExist, http.StatusNotFound},
+	{kubestore.ErrorValueTooLarge, http.StatusRequestEntityTooLarge},
+	{kubestore.ErrorQuotaExceeded, http.StatusInsufficientStorage},
+	{kubestore.ErrorConflict, http.StatusConflict},
+	{kubestore.ErrorNotSupported, http.StatusNotImplemented},
+	{kubestore.ErrorInvalidName, http.StatusBadRequest},
+	{kubestore.ErrorResourceMissing, http.StatusServiceUnavailable},
+}
+
+// writeStoreError writes the given error returned by the Store, using the
+// status code corresponding to its sentinel error.
+func writeStoreError(w http.ResponseWriter, err error) {
+	for _, code := range statusCodes {
+		if errors.Is(err, code.err) {
+			writeError(w, code.status, err)
+			return
+		}
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+// writeError writes the given error with the given status code.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// writeJSON writes the given value as JSON with the given status code.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// Intentionally ignore any errors, as the client has gone away.
+	_ = json.NewEncoder(w).Encode(value)
+}
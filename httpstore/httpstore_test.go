@@ -0,0 +1,114 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package httpstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joshdk/kubestore"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(NewHandler(kubestore.NewFileStore(t.TempDir()), "secret"))
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret", nil)
+
+	type config struct {
+		Replicas int `json:"replicas"`
+	}
+	if err := client.Set(ctx, "config", config{Replicas: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set(ctx, "name", "web"); err != nil {
+		t.Fatal(err)
+	}
+
+	var value config
+	if err := client.Get(ctx, "config", &value); err != nil {
+		t.Fatal(err)
+	}
+	if value.Replicas != 3 {
+		t.Fatalf("expected 3 replicas, got %d", value.Replicas)
+	}
+
+	keys, err := client.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"config", "name"}; !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected %v, got %v", expected, keys)
+	}
+
+	if err := client.Delete(ctx, "name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Get(ctx, "name", &value); !errors.Is(err, kubestore.ErrorKeyNotFound) {
+		t.Fatalf("expected ErrorKeyNotFound, got %v", err)
+	}
+	if err := client.Delete(ctx, "name"); !errors.Is(err, kubestore.ErrorKeyNotFound) {
+		t.Fatalf("expected ErrorKeyNotFound, got %v", err)
+	}
+}
+
+func TestHandlerAuthorization(t *testing.T) {
+	handler := NewHandler(kubestore.NewFileStore(t.TempDir()), "secret")
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "Bearer wrong", http.StatusUnauthorized},
+		{"scheme", "Basic secret", http.StatusUnauthorized},
+		{"valid", "Bearer secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/keys", nil)
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != test.status {
+				t.Fatalf("expected status %d, got %d", test.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerPut(t *testing.T) {
+	handler := NewHandler(kubestore.NewFileStore(t.TempDir()), "")
+	handler.MaxBodyBytes = 16
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"a":1}`, http.StatusNoContent},
+		{"invalid", `{"a":`, http.StatusBadRequest},
+		{"large", `"` + strings.Repeat("x", 32) + `"`, http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/keys/key", strings.NewReader(test.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, rec.Code, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// GetIndex reads the element at the given index of the JSON array stored
+// under the named key, and stores the element into the given value pointer.
+//
+// If the index is outside of the bounds of the array, an error matching the
+// ErrorIndexOutOfRange sentinel error is returned.
+func GetIndex(ctx context.Context, store Store, key string, index int, value interface{}) error {
+	var elements []json.RawMessage
+	if err := store.Get(ctx, key, &elements); err != nil {
+		return err
+	}
+
+	if index < 0 || index >= len(elements) {
+		return indexError(key, index, len(elements))
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(elements[index], value)
+}
+
+// SetIndex replaces the element at the given index of the JSON array stored
+// under the named key with the given value. An index equal to the length of
+// the array appends the value, and if the key does not exist, it is created
+// with the value as its only element when the index is zero.
+//
+// The array is modified atomically if the Store implements the Updater
+// interface, so that concurrent changes to other elements are not lost.
+// Otherwise, the array is read and written separately.
+//
+// If the index is outside of the bounds of the array, an error matching the
+// ErrorIndexOutOfRange sentinel error is returned.
+func SetIndex(ctx context.Context, store Store, key string, index int, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return updateIndex(ctx, store, key, func(elements []json.RawMessage) ([]json.RawMessage, error) {
+		switch {
+		case index >= 0 && index < len(elements):
+			elements[index] = data
+		case index == len(elements):
+			elements = append(elements, data)
+		default:
+			return nil, indexError(key, index, len(elements))
+		}
+		return elements, nil
+	})
+}
+
+// DeleteIndex removes the element at the given index of the JSON array stored
+// under the named key, shifting any subsequent elements down by one. The array
+// is modified atomically, as with SetIndex.
+//
+// If the index is outside of the bounds of the array, an error matching the
+// ErrorIndexOutOfRange sentinel error is returned.
+func DeleteIndex(ctx context.Context, store Store, key string, index int) error {
+	return updateIndex(ctx, store, key, func(elements []json.RawMessage) ([]json.RawMessage, error) {
+		if index < 0 || index >= len(elements) {
+			return nil, indexError(key, index, len(elements))
+		}
+		return append(elements[:index], elements[index+1:]...), nil
+	})
+}
+
+// updateIndex modifies the JSON array stored under the named key with the
+// given function.
+func updateIndex(ctx context.Context, store Store, key string, fn func(elements []json.RawMessage) ([]json.RawMessage, error)) error {
+	return updateOrSet(ctx, store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		var elements []json.RawMessage
+		if current != nil {
+			if err := json.Unmarshal(current, &elements); err != nil {
+				return nil, fmt.Errorf("key %s does not hold an array: %w", key, err)
+			}
+		}
+
+		elements, err := fn(elements)
+		if err != nil {
+			return nil, err
+		}
+
+		// An empty array is stored as such, rather than as null.
+		if elements == nil {
+			elements = []json.RawMessage{}
+		}
+		return json.Marshal(elements)
+	})
+}
+
+// indexError returns an error matching the ErrorIndexOutOfRange sentinel error.
+func indexError(key string, index, length int) error {
+	return fmt.Errorf("%w: index %d of key %s, which has %d elements", ErrorIndexOutOfRange, index, key, length)
+}
@@ -0,0 +1,348 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// journalEntry is a single change recorded in the journal.
+type journalEntry struct {
+	Sequence uint64          `json:"seq"`
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Deleted  bool            `json:"deleted,omitempty"`
+}
+
+// Assert that JournalStore implements the Store interface.
+var _ Store = (*JournalStore)(nil)
+
+// JournalStore is a Store that wraps another Store, and appends changes to a
+// durable local journal before replaying them to it asynchronously.
+type JournalStore struct {
+	store Store
+	retry time.Duration
+
+	// replayMu serializes replaying changes to the wrapped Store.
+	replayMu sync.Mutex
+
+	mu       sync.Mutex
+	file     *os.File
+	entries  []journalEntry
+	sequence uint64
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewJournalStore returns a JournalStore that wraps the given Store, and
+// records changes in a journal file at the given path.
+//
+// Calls to Store.Set and Store.Delete return after the change has been
+// appended to the journal and synced to disk. A background worker replays the
+// changes to the wrapped Store in the order that they were made, retrying at
+// the given interval if replaying fails. Reads observe any changes that have
+// yet to be replayed. Once every change has been replayed, the journal is
+// truncated.
+//
+// Any changes that remain in an existing journal, for instance from before
+// the process was restarted, are replayed first.
+func NewJournalStore(store Store, path string, retry time.Duration) (*JournalStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read any changes that remain in an existing journal.
+	entries, err := recoverJournal(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &JournalStore{
+		store:   store,
+		retry:   retry,
+		file:    file,
+		entries: entries,
+		wake:    make(chan struct{}, 1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	if len(entries) > 0 {
+		s.sequence = entries[len(entries)-1].Sequence
+		s.wake <- struct{}{}
+	}
+
+	go s.run(ctx)
+
+	return s, nil
+}
+
+// recoverJournal reads every complete entry from the given journal file.
+//
+// A partially written final entry indicates that the process exited before
+// the change was acknowledged, so it is dropped, and the journal is truncated
+// back to the end of the last complete entry. Otherwise, new entries would be
+// appended onto the partial entry, and lost along with it when next recovered.
+func recoverJournal(file *os.File) ([]journalEntry, error) {
+	var entries []journalEntry
+	var offset int64
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		// Every complete entry is terminated by a newline.
+		var entry journalEntry
+		if err == io.EOF || json.Unmarshal(line, &entry) != nil {
+			break
+		}
+
+		entries = append(entries, entry)
+		offset += int64(len(line))
+	}
+
+	// Discard anything that follows the last complete entry.
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != offset {
+		if err := file.Truncate(offset); err != nil {
+			return nil, err
+		}
+		if err := file.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// Get reads the named entry, preferring the most recent change that has yet
+// to be replayed, and stores the contents into the given value pointer.
+func (s *JournalStore) Get(ctx context.Context, key string, value interface{}) error {
+	s.mu.Lock()
+	entry, found := s.latest(key)
+	s.mu.Unlock()
+
+	if !found {
+		return s.store.Get(ctx, key, value)
+	}
+	if entry.Deleted {
+		return ErrorKeyNotFound
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(entry.Value, value)
+}
+
+// Set appends the named entry and value to the journal, to be replayed to the
+// wrapped Store asynchronously.
+func (s *JournalStore) Set(_ context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return s.append(journalEntry{Key: key, Value: data})
+}
+
+// List returns a list of all keys in the wrapped Store, including any changes
+// that have yet to be replayed.
+func (s *JournalStore) List(ctx context.Context) ([]string, error) {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Determine the most recent change for each key.
+	latest := make(map[string]journalEntry)
+	for _, entry := range s.entries {
+		latest[entry.Key] = entry
+	}
+
+	// Disregard keys that are pending deletion.
+	listed := make(map[string]bool, len(keys))
+	filtered := keys[:0]
+	for _, key := range keys {
+		listed[key] = true
+		if entry, found := latest[key]; found && entry.Deleted {
+			continue
+		}
+		filtered = append(filtered, key)
+	}
+
+	// Include keys that are pending creation.
+	for key, entry := range latest {
+		if !entry.Deleted && !listed[key] {
+			filtered = append(filtered, key)
+		}
+	}
+
+	return filtered, nil
+}
+
+// Delete appends the removal of the named entry to the journal, to be
+// replayed to the wrapped Store asynchronously.
+func (s *JournalStore) Delete(_ context.Context, key string) error {
+	return s.append(journalEntry{Key: key, Deleted: true})
+}
+
+// Describe returns a description of the wrapped Store.
+func (s *JournalStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// Pending returns the number of changes that have yet to be replayed to the
+// wrapped Store.
+func (s *JournalStore) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Flush synchronously replays all pending changes to the wrapped Store.
+func (s *JournalStore) Flush(ctx context.Context) error {
+	return s.replay(ctx)
+}
+
+// Close stops the background worker, replays all pending changes to the
+// wrapped Store, and closes the journal. Changes that could not be replayed
+// remain in the journal.
+func (s *JournalStore) Close(ctx context.Context) error {
+	s.cancel()
+	<-s.done
+
+	err := s.Flush(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// latest returns the most recent change for the given key, if any. The caller
+// must hold s.mu.
+func (s *JournalStore) latest(key string) (journalEntry, bool) {
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.entries[i].Key == key {
+			return s.entries[i], true
+		}
+	}
+	return journalEntry{}, false
+}
+
+// append durably records the given change in the journal, and wakes the
+// background worker.
+func (s *JournalStore) append(entry journalEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Sequence = s.sequence + 1
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+
+	s.sequence = entry.Sequence
+	s.entries = append(s.entries, entry)
+
+	// Wake the background worker, if it is not already awake.
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// run replays changes whenever woken, until the given context is done.
+func (s *JournalStore) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+
+		// Keep retrying until all pending changes have been replayed.
+		for s.replay(ctx) != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.retry):
+			}
+		}
+	}
+}
+
+// replay applies pending changes to the wrapped Store, strictly in the order
+// that they were made, stopping at the first failure. Once every change has
+// been replayed, the journal is truncated.
+func (s *JournalStore) replay(ctx context.Context) error {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	for {
+		s.mu.Lock()
+		if len(s.entries) == 0 {
+			// Every change has been replayed, so truncate the journal while
+			// still holding the lock, in order to not lose any new changes.
+			err := s.file.Truncate(0)
+			s.mu.Unlock()
+			return err
+		}
+		entry := s.entries[0]
+		s.mu.Unlock()
+
+		var err error
+		if entry.Deleted {
+			err = s.store.Delete(ctx, entry.Key)
+			// The key may have never been replayed in the first place.
+			if isKeyNotFound(err) {
+				err = nil
+			}
+		} else {
+			err = s.store.Set(ctx, entry.Key, entry.Value)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Forget the change, now that it has been replayed.
+		s.mu.Lock()
+		s.entries = s.entries[1:]
+		s.mu.Unlock()
+	}
+}
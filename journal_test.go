@@ -0,0 +1,88 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// unavailableStore is a Store that fails every operation, so that changes
+// remain in a journal.
+type unavailableStore struct{}
+
+var errUnavailable = errors.New("unavailable")
+
+func (unavailableStore) Get(context.Context, string, interface{}) error { return errUnavailable }
+func (unavailableStore) Set(context.Context, string, interface{}) error { return errUnavailable }
+func (unavailableStore) List(context.Context) ([]string, error)         { return nil, errUnavailable }
+func (unavailableStore) Delete(context.Context, string) error           { return errUnavailable }
+
+func TestJournalRecoveryAfterTornWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "journal")
+
+	open := func() *JournalStore {
+		store, err := NewJournalStore(unavailableStore{}, path, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}
+
+	store := open()
+	if err := store.Set(ctx, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	store.cancel()
+	<-store.done
+	store.file.Close()
+
+	// Simulate the process exiting partway through appending an entry.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"seq":2,"key":"b","val`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	// Changes acknowledged after recovering must survive another restart.
+	store = open()
+	if err := store.Set(ctx, "c", "3"); err != nil {
+		t.Fatal(err)
+	}
+	store.cancel()
+	<-store.done
+	store.file.Close()
+
+	store = open()
+	defer func() {
+		store.cancel()
+		<-store.done
+		store.file.Close()
+	}()
+
+	if pending := store.Pending(); pending != 2 {
+		t.Fatalf("expected 2 pending changes, got %d", pending)
+	}
+	for key, expected := range map[string]string{"a": "1", "c": "3"} {
+		var value string
+		if err := store.Get(ctx, key, &value); err != nil {
+			t.Fatalf("get %q: %v", key, err)
+		}
+		if value != expected {
+			t.Fatalf("get %q: expected %q, got %q", key, expected, value)
+		}
+	}
+	if err := store.Get(ctx, "b", new(string)); !errors.Is(err, errUnavailable) {
+		t.Fatalf("get %q: expected the torn change to be dropped, got %v", "b", err)
+	}
+}
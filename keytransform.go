@@ -0,0 +1,118 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Assert that keyTransformStore implements the Store interface.
+var _ Store = keyTransformStore{}
+
+type keyTransformStore struct {
+	store     Store
+	transform func(string) string
+	inverse   func(string) string
+}
+
+// Get reads the transformed key from the wrapped Store.
+func (s keyTransformStore) Get(ctx context.Context, key string, value interface{}) error {
+	return s.store.Get(ctx, s.transform(key), value)
+}
+
+// Set writes the transformed key into the wrapped Store.
+func (s keyTransformStore) Set(ctx context.Context, key string, value interface{}) error {
+	return s.store.Set(ctx, s.transform(key), value)
+}
+
+// List returns a list of all keys in the wrapped Store, converted back using
+// the inverse function.
+func (s keyTransformStore) List(ctx context.Context) ([]string, error) {
+	keys, err := s.store.List(ctx)
+	if err != nil || s.inverse == nil {
+		return keys, err
+	}
+
+	converted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		// Disregard keys that have no inverse.
+		if key = s.inverse(key); key != "" {
+			converted = append(converted, key)
+		}
+	}
+
+	return converted, nil
+}
+
+// Delete removes the transformed key from the wrapped Store.
+func (s keyTransformStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, s.transform(key))
+}
+
+// GetMulti reads the transformed keys from the wrapped Store.
+func (s keyTransformStore) GetMulti(ctx context.Context, keys []string) (map[string]json.RawMessage, error) {
+	transformed := make([]string, len(keys))
+	originals := make(map[string]string, len(keys))
+	for i, key := range keys {
+		transformed[i] = s.transform(key)
+		originals[transformed[i]] = key
+	}
+
+	values, err := GetMulti(ctx, s.store, transformed)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := make(map[string]json.RawMessage, len(values))
+	for key, value := range values {
+		converted[originals[key]] = value
+	}
+
+	return converted, nil
+}
+
+// Describe returns a description of the wrapped Store.
+func (s keyTransformStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s keyTransformStore) resourceVersion(ctx context.Context) (string, error) {
+	return resourceVersionOf(ctx, s.store)
+}
+
+// Subscribe watches the wrapped Store for changes, converting keys back using
+// the inverse function.
+func (s keyTransformStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	events, err := Subscribe(ctx, s.store)
+	if err != nil || s.inverse == nil {
+		return events, err
+	}
+
+	converted := make(chan Event)
+	go func() {
+		defer close(converted)
+		for event := range events {
+			// Disregard keys that have no inverse.
+			if event.Key = s.inverse(event.Key); event.Key == "" {
+				continue
+			}
+			select {
+			case converted <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return converted, nil
+}
+
+// Update atomically modifies the transformed key in the wrapped Store.
+func (s keyTransformStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return Update(ctx, s.store, s.transform(key), fn)
+}
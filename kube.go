@@ -5,8 +5,13 @@
 package kubestore
 
 import (
+	stderrors "errors"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 )
@@ -21,6 +26,20 @@ func inClusterNamespace() (string, error) {
 	return strings.TrimSpace(string(data)), nil
 }
 
+// nodeNameEnv is the environment variable that holds the name of the node that
+// the current pod is running on, when exposed using the downward API.
+const nodeNameEnv = "NODE_NAME"
+
+// inClusterNodeName reads the name of the node that the current pod is running
+// on.
+func inClusterNodeName() (string, error) {
+	name := os.Getenv(nodeNameEnv)
+	if name == "" {
+		return "", fmt.Errorf("%s is not set, expose spec.nodeName using the downward API", nodeNameEnv)
+	}
+	return name, nil
+}
+
 // isResourceMissingError returns true if the given error indicates that a
 // Kubernetes API call failed because the targeted resource did not exist.
 func isResourceMissingError(err error) bool {
@@ -29,3 +48,46 @@ func isResourceMissingError(err error) bool {
 	}
 	return false
 }
+
+// isConflictError returns true if the given error indicates that a Kubernetes
+// API call failed because the targeted resource was concurrently modified, or
+// already existed.
+func isConflictError(err error) bool {
+	if sterr, ok := err.(*errors.StatusError); ok {
+		return sterr.ErrStatus.Code == 409
+	}
+	return false
+}
+
+// isUnavailableError returns true if the given error indicates that a
+// Kubernetes API call failed because the API server was unreachable or
+// temporarily unable to serve the request.
+func isUnavailableError(err error) bool {
+	var neterr net.Error
+	if stderrors.As(err, &neterr) {
+		return true
+	}
+	return errors.IsServerTimeout(err) ||
+		errors.IsTimeout(err) ||
+		errors.IsTooManyRequests(err) ||
+		errors.IsInternalError(err) ||
+		errors.IsServiceUnavailable(err) ||
+		errors.IsUnexpectedServerError(err)
+}
+
+// isThrottledError returns true if the given error indicates that a Kubernetes
+// API call was rejected because the client was being throttled, such as by API
+// Priority and Fairness.
+func isThrottledError(err error) bool {
+	return errors.IsTooManyRequests(err)
+}
+
+// retryAfter returns the delay requested by the API server before a failed
+// Kubernetes API call is retried, as given by the Retry-After header.
+func retryAfter(err error) (time.Duration, bool) {
+	seconds, ok := errors.SuggestsClientDelay(err)
+	if !ok || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
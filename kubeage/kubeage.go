@@ -0,0 +1,215 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Package kubeage provides a kubestore.Store that encrypts values to one or
+// more age recipients, such as X25519 keys or SSH keys, so that values can be
+// decrypted by any holder of a matching identity, including by standard age
+// tooling outside of the application.
+//
+// Each value is stored as an ASCII armored age file, encoded as a JSON string.
+// For example, a value stored in a ConfigMap can be decrypted with:
+//
+//	kubectl get configmap settings -o jsonpath='{.data.password}' | jq -r . | age -d -i key.txt
+//
+// Unlike a kubestore.EncryptedStore, values are not bound to the name of their
+// key, since age has no notion of associated data, and so an encrypted value
+// can be moved to a different key by anyone with write access to the backing
+// medium.
+package kubeage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"github.com/joshdk/kubestore"
+)
+
+// Assert that Store implements the kubestore.Store interface.
+var _ kubestore.Store = (*Store)(nil)
+
+// Store is a kubestore.Store that wraps another Store, and encrypts the values
+// that are written to it using age.
+type Store struct {
+	store      kubestore.Store
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+// NewStore returns a Store that wraps the given Store, and encrypts values to
+// every one of the given recipients. Values are decrypted using whichever of
+// the given identities matches one of the recipients that the value was
+// encrypted to. A Store without identities can only write values.
+func NewStore(store kubestore.Store, recipients []age.Recipient, identities ...age.Identity) (*Store, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("kubeage: at least one recipient is required")
+	}
+
+	return &Store{
+		store:      store,
+		recipients: recipients,
+		identities: identities,
+	}, nil
+}
+
+// ParseRecipients parses the given recipients, each of which is either an age
+// X25519 public key, such as "age1...", or an SSH public key in the
+// authorized_keys format, such as "ssh-ed25519 AAAA...".
+func ParseRecipients(recipients ...string) ([]age.Recipient, error) {
+	parsed := make([]age.Recipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		recipient = strings.TrimSpace(recipient)
+
+		var (
+			r   age.Recipient
+			err error
+		)
+		if strings.HasPrefix(recipient, "ssh-") {
+			r, err = agessh.ParseRecipient(recipient)
+		} else {
+			r, err = age.ParseX25519Recipient(recipient)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("kubeage: invalid recipient %q: %w", recipient, err)
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// Get reads the named entry from the wrapped Store, decrypts it, and stores
+// the contents into the given value pointer.
+//
+// If the entry could not be decrypted by any of the identities, an error
+// matching the kubestore.ErrorDecryptionFailed sentinel error is returned.
+func (s *Store) Get(ctx context.Context, key string, value interface{}) error {
+	var data json.RawMessage
+	if err := s.store.Get(ctx, key, &data); err != nil {
+		return err
+	}
+
+	plaintext, err := s.decrypt(data)
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(plaintext, value)
+}
+
+// Set encrypts the given value to every recipient, and writes it into the
+// wrapped Store.
+func (s *Store) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Set(ctx, key, data)
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s *Store) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s *Store) Describe() kubestore.Description {
+	return kubestore.Describe(s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s *Store) Subscribe(ctx context.Context) (<-chan kubestore.Event, error) {
+	return kubestore.Subscribe(ctx, s.store)
+}
+
+// Update atomically modifies the named entry in the wrapped Store, decrypting
+// its current contents and encrypting the result to every recipient. If the
+// given function returns the current contents unchanged, then nothing is
+// written, since encrypting them again would produce a different value.
+func (s *Store) Update(ctx context.Context, key string, fn kubestore.UpdateFunc) error {
+	return kubestore.Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		var plaintext json.RawMessage
+		if current != nil {
+			var err error
+			if plaintext, err = s.decrypt(current); err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := fn(plaintext)
+		if err != nil || result == nil {
+			return nil, err
+		}
+		if current != nil && bytes.Equal(plaintext, result) {
+			return current, nil
+		}
+
+		return s.encrypt(result)
+	})
+}
+
+// encrypt encrypts the given plaintext to every recipient, returning an ASCII
+// armored age file encoded as a JSON string.
+func (s *Store) encrypt(plaintext []byte) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	armored := armor.NewWriter(&buf)
+
+	w, err := age.Encrypt(armored, s.recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := armored.Close(); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(buf.String())
+}
+
+// decrypt decrypts the given ASCII armored age file, encoded as a JSON string,
+// using any of the identities.
+func (s *Store) decrypt(data []byte) ([]byte, error) {
+	var armored string
+	if err := json.Unmarshal(data, &armored); err != nil {
+		return nil, err
+	}
+	if len(s.identities) == 0 {
+		return nil, fmt.Errorf("%w: no identities were given", kubestore.ErrorDecryptionFailed)
+	}
+
+	r, err := age.Decrypt(armor.NewReader(strings.NewReader(armored)), s.identities...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", kubestore.ErrorDecryptionFailed, err)
+	}
+
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", kubestore.ErrorDecryptionFailed, err)
+	}
+	return plaintext, nil
+}
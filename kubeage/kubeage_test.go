@@ -0,0 +1,114 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubeage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/joshdk/kubestore"
+)
+
+func TestStoreRecipients(t *testing.T) {
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	alice, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients, err := ParseRecipients(alice.Recipient().String(), bob.Recipient().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewStore(kubestore.NewFileStore(directory), recipients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Set(ctx, "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every recipient can decrypt the value.
+	for _, identity := range []age.Identity{alice, bob} {
+		reader, err := NewStore(kubestore.NewFileStore(directory), recipients, identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var value string
+		if err := reader.Get(ctx, "key", &value); err != nil {
+			t.Fatal(err)
+		}
+		if value != "value" {
+			t.Fatalf("expected %q, got %q", "value", value)
+		}
+	}
+
+	// A store without identities cannot decrypt the value.
+	if err := writer.Get(ctx, "key", new(string)); !errors.Is(err, kubestore.ErrorDecryptionFailed) {
+		t.Fatalf("expected %v, got %v", kubestore.ErrorDecryptionFailed, err)
+	}
+
+	// The stored value is a standard armored age file.
+	var armored string
+	if err := kubestore.NewFileStore(directory).Get(ctx, "key", &armored); err != nil {
+		t.Fatal(err)
+	}
+	r, err := age.Decrypt(armor.NewReader(strings.NewReader(armored)), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != `"value"` {
+		t.Fatalf("expected %q, got %q", `"value"`, plaintext)
+	}
+}
+
+func TestStoreNoopUpdate(t *testing.T) {
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(kubestore.NewFileStore(directory), []age.Recipient{identity.Recipient()}, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(ctx, "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after string
+	if err := kubestore.NewFileStore(directory).Get(ctx, "key", &before); err != nil {
+		t.Fatal(err)
+	}
+
+	// A losing SetNX returns the current contents unchanged.
+	if created, err := kubestore.SetNX(ctx, store, "key", "other"); err != nil || created {
+		t.Fatalf("expected the existing entry to be kept: %v", err)
+	}
+
+	if err := kubestore.NewFileStore(directory).Get(ctx, "key", &after); err != nil {
+		t.Fatal(err)
+	}
+	if after != before {
+		t.Fatal("expected the encrypted value not to be rewritten")
+	}
+}
@@ -0,0 +1,406 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Package kubekv provides a valkeyrie store.Store backed by a kubestore.Store,
+// so that tools built on valkeyrie (or libkv) can keep their data in
+// ConfigMaps, Secrets, or any other kubestore backend.
+//
+// Valkeyrie keys are paths such as "services/web/1". Since Kuberneties keys
+// cannot contain slashes, every byte outside of [-.a-zA-Z0-9] is escaped as an
+// underscore followed by its value in hex, so that "services/web/1" is stored
+// as "services_2Fweb_2F1".
+//
+// Each entry is stored alongside an index that changes on every write, which
+// is used by AtomicPut and AtomicDelete. Atomic operations require a Store
+// that implements the kubestore.Updater interface, and otherwise return
+// store.ErrCallNotSupported.
+//
+// Importing this package registers a valkeyrie constructor named "kubestore",
+// whose endpoints are kubestore DSNs, as described by kubestore.Open.
+package kubekv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshdk/kubestore"
+	"github.com/kvtools/valkeyrie"
+	"github.com/kvtools/valkeyrie/store"
+)
+
+// StoreName is the name under which the valkeyrie constructor is registered.
+const StoreName = "kubestore"
+
+func init() {
+	valkeyrie.Register(StoreName, newStore)
+}
+
+// Config is the configuration given to valkeyrie.NewStore.
+type Config struct {
+	// Store is used instead of the given endpoints, if it is not nil.
+	Store kubestore.Store
+
+	// PollInterval is the interval at which watched keys are re-read, if the
+	// Store does not implement the kubestore.Subscriber interface.
+	PollInterval time.Duration
+}
+
+// newStore constructs a Store for valkeyrie.NewStore. If no Store is
+// configured, a single endpoint must be given, which is opened with
+// kubestore.Open.
+func newStore(ctx context.Context, endpoints []string, options valkeyrie.Config) (store.Store, error) {
+	var cfg Config
+	switch options := options.(type) {
+	case nil:
+	case *Config:
+		if options != nil {
+			cfg = *options
+		}
+	default:
+		return nil, &store.InvalidConfigurationError{Store: StoreName, Config: options}
+	}
+
+	if cfg.Store == nil {
+		if len(endpoints) != 1 {
+			return nil, fmt.Errorf("%s: exactly one endpoint is required, got %d", StoreName, len(endpoints))
+		}
+		var err error
+		if cfg.Store, err = kubestore.Open(ctx, endpoints[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	return New(cfg.Store, cfg.PollInterval), nil
+}
+
+// Assert that Store implements the valkeyrie store.Store interface.
+var _ store.Store = (*Store)(nil)
+
+// Store is a valkeyrie store.Store that is backed by a kubestore.Store.
+type Store struct {
+	store        kubestore.Store
+	pollInterval time.Duration
+}
+
+// New returns a Store that is backed by the given kubestore.Store. Watched
+// keys are re-read at the given interval, which defaults to 1 second, if the
+// Store does not implement the kubestore.Subscriber interface.
+func New(s kubestore.Store, pollInterval time.Duration) *Store {
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	return &Store{
+		store:        s,
+		pollInterval: pollInterval,
+	}
+}
+
+// entry is the stored form of a single key.
+type entry struct {
+	Value   []byte    `json:"value"`
+	Index   uint64    `json:"index"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// expired returns true if the entry has expired as of the given time.
+func (e entry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+// newEntry returns an entry holding the given value, with an index that is
+// greater than the given previous index.
+func newEntry(value []byte, previous uint64, opts *store.WriteOptions) entry {
+	// Indexes are derived from the current time, so that a key which is
+	// deleted and then recreated is not given an index that it had before.
+	index := uint64(time.Now().UnixNano())
+	if index <= previous {
+		index = previous + 1
+	}
+
+	e := entry{Value: value, Index: index}
+	if opts != nil && opts.TTL > 0 {
+		e.Expires = time.Now().Add(opts.TTL).UTC()
+	}
+	return e
+}
+
+// decodeEntry decodes the given stored entry, or returns nil if it does not
+// exist or has expired.
+func decodeEntry(data json.RawMessage) (*entry, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	if e.expired(time.Now()) {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+// normalize removes any leading and trailing slashes from the given key.
+func normalize(key string) string {
+	return strings.Trim(key, "/")
+}
+
+// escapeKey escapes the given key so that it only contains characters that
+// are valid in a Store key.
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '.':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "_%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// unescapeKey reverses escapeKey, and returns false if the given key was not
+// escaped correctly.
+func unescapeKey(key string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		if key[i] != '_' {
+			b.WriteByte(key[i])
+			continue
+		}
+		if i+2 >= len(key) {
+			return "", false
+		}
+
+		c, err := strconv.ParseUint(key[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), true
+}
+
+// get reads the entry for the given key, or returns nil if it does not exist.
+func (s *Store) get(ctx context.Context, key string) (*entry, error) {
+	var data json.RawMessage
+	if err := s.store.Get(ctx, escapeKey(key), &data); err != nil {
+		if errors.Is(err, kubestore.ErrorKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return decodeEntry(data)
+}
+
+// Put stores the given value under the given key.
+func (s *Store) Put(ctx context.Context, key string, value []byte, opts *store.WriteOptions) error {
+	key = normalize(key)
+
+	// Use an atomic update where possible, so that the index is guaranteed to
+	// change.
+	err := kubestore.Update(ctx, s.store, escapeKey(key), func(current json.RawMessage) (json.RawMessage, error) {
+		var previous uint64
+		if e, err := decodeEntry(current); err == nil && e != nil {
+			previous = e.Index
+		}
+		return json.Marshal(newEntry(value, previous, opts))
+	})
+	if !errors.Is(err, kubestore.ErrorNotSupported) {
+		return err
+	}
+	return s.store.Set(ctx, escapeKey(key), newEntry(value, 0, opts))
+}
+
+// Get returns the value of the given key.
+func (s *Store) Get(ctx context.Context, key string, _ *store.ReadOptions) (*store.KVPair, error) {
+	key = normalize(key)
+	e, err := s.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, store.ErrKeyNotFound
+	}
+	return &store.KVPair{Key: key, Value: e.Value, LastIndex: e.Index}, nil
+}
+
+// Delete removes the given key.
+//
+// If the key does not exist, store.ErrKeyNotFound is returned.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	err := s.store.Delete(ctx, escapeKey(normalize(key)))
+	if errors.Is(err, kubestore.ErrorKeyNotFound) || errors.Is(err, os.ErrNotExist) {
+		return store.ErrKeyNotFound
+	}
+	return err
+}
+
+// Exists returns true if the given key exists.
+func (s *Store) Exists(ctx context.Context, key string, _ *store.ReadOptions) (bool, error) {
+	e, err := s.get(ctx, normalize(key))
+	return e != nil, err
+}
+
+// List returns every key under the given directory, along with its value,
+// not including the directory itself.
+//
+// If neither the directory nor any keys under it exist, store.ErrKeyNotFound
+// is returned.
+func (s *Store) List(ctx context.Context, directory string, _ *store.ReadOptions) ([]*store.KVPair, error) {
+	directory = normalize(directory)
+	pairs, err := s.list(ctx, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make([]*store.KVPair, 0, len(pairs))
+	for _, pair := range pairs {
+		if pair.Key != directory {
+			children = append(children, pair)
+		}
+	}
+	if len(children) == len(pairs) && len(children) == 0 {
+		return nil, store.ErrKeyNotFound
+	}
+	return children, nil
+}
+
+// list returns every key under the given directory, along with its value,
+// sorted by key. The directory itself is included if it exists.
+func (s *Store) list(ctx context.Context, directory string) ([]*store.KVPair, error) {
+	names, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []string
+	for _, name := range names {
+		key, ok := unescapeKey(name)
+		if ok && (directory == "" || key == directory || strings.HasPrefix(key, directory+"/")) {
+			matching = append(matching, name)
+		}
+	}
+
+	values, err := kubestore.GetMulti(ctx, s.store, matching)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]*store.KVPair, 0, len(values))
+	for name, data := range values {
+		e, err := decodeEntry(data)
+		if err != nil {
+			// The key was not written by this package, so is skipped.
+			continue
+		}
+		if e == nil {
+			continue
+		}
+		key, _ := unescapeKey(name)
+		pairs = append(pairs, &store.KVPair{Key: key, Value: e.Value, LastIndex: e.Index})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key < pairs[j].Key
+	})
+	return pairs, nil
+}
+
+// DeleteTree removes every key under the given directory.
+func (s *Store) DeleteTree(ctx context.Context, directory string) error {
+	pairs, err := s.list(ctx, normalize(directory))
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		if err := s.Delete(ctx, pair.Key); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// AtomicPut stores the given value under the given key, if the key has not
+// been modified since the given previous pair was read. If previous is nil,
+// the key is only created if it does not exist.
+func (s *Store) AtomicPut(ctx context.Context, key string, value []byte, previous *store.KVPair, opts *store.WriteOptions) (bool, *store.KVPair, error) {
+	key = normalize(key)
+
+	var result entry
+	err := kubestore.Update(ctx, s.store, escapeKey(key), func(current json.RawMessage) (json.RawMessage, error) {
+		e, err := decodeEntry(current)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case previous == nil && e != nil:
+			return nil, store.ErrKeyExists
+		case previous != nil && e == nil:
+			return nil, store.ErrKeyNotFound
+		case previous != nil && e.Index != previous.LastIndex:
+			return nil, store.ErrKeyModified
+		}
+
+		var index uint64
+		if e != nil {
+			index = e.Index
+		}
+		result = newEntry(value, index, opts)
+		return json.Marshal(result)
+	})
+	if err != nil {
+		return false, nil, translate(err)
+	}
+	return true, &store.KVPair{Key: key, Value: result.Value, LastIndex: result.Index}, nil
+}
+
+// AtomicDelete removes the given key, if it has not been modified since the
+// given previous pair was read.
+func (s *Store) AtomicDelete(ctx context.Context, key string, previous *store.KVPair) (bool, error) {
+	if previous == nil {
+		return false, store.ErrPreviousNotSpecified
+	}
+
+	err := kubestore.Update(ctx, s.store, escapeKey(normalize(key)), func(current json.RawMessage) (json.RawMessage, error) {
+		e, err := decodeEntry(current)
+		switch {
+		case err != nil:
+			return nil, err
+		case e == nil:
+			return nil, store.ErrKeyNotFound
+		case e.Index != previous.LastIndex:
+			return nil, store.ErrKeyModified
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return false, translate(err)
+	}
+	return true, nil
+}
+
+// translate converts errors returned by kubestore into their valkeyrie
+// equivalents.
+func translate(err error) error {
+	if errors.Is(err, kubestore.ErrorNotSupported) {
+		return store.ErrCallNotSupported
+	}
+	return err
+}
+
+// Close does nothing, as the kubestore.Store has no resources to release.
+func (s *Store) Close() error {
+	return nil
+}
@@ -0,0 +1,27 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubekv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joshdk/kubestore"
+	"github.com/kvtools/valkeyrie/testsuite"
+)
+
+func TestStore(t *testing.T) {
+	directory := t.TempDir()
+	kv := New(kubestore.NewFileStore(directory), 10*time.Millisecond)
+	backup := New(kubestore.NewFileStore(directory), 10*time.Millisecond)
+
+	testsuite.RunTestCommon(t, kv)
+	testsuite.RunTestAtomic(t, kv)
+	testsuite.RunTestWatch(t, kv)
+	testsuite.RunTestLock(t, kv)
+	testsuite.RunTestLockTTL(t, kv, backup)
+	testsuite.RunTestTTL(t, kv, backup)
+	testsuite.RunCleanup(t, kv)
+}
@@ -0,0 +1,138 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubekv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joshdk/kubestore"
+	"github.com/kvtools/valkeyrie/store"
+)
+
+// defaultLockTTL is the duration after which a lock that is not renewed is
+// considered abandoned, if no TTL is given.
+const defaultLockTTL = 20 * time.Second
+
+// lockSuffix is appended to the escaped form of a key to name the key that
+// holds the state of its lock. Since the suffix is not a valid escape
+// sequence, lock keys are never returned by List.
+const lockSuffix = "_lock"
+
+// NewLock returns a lock for the given key, which is implemented using a
+// kubestore.Semaphore with a single slot. The lock is renewed in the
+// background while it is held, unless the RenewLock channel is closed.
+//
+// If a Value is given, it is stored under the key once the lock is acquired.
+func (s *Store) NewLock(_ context.Context, key string, opts *store.LockOptions) (store.Locker, error) {
+	l := &locker{
+		store: s,
+		key:   normalize(key),
+		ttl:   defaultLockTTL,
+	}
+	if opts != nil {
+		if opts.TTL > 0 {
+			l.ttl = opts.TTL
+		}
+		l.value = opts.Value
+		l.renewLock = opts.RenewLock
+		l.deleteOnUnlock = opts.DeleteOnUnlock
+	}
+	l.semaphore = kubestore.NewSemaphore(s.store, escapeKey(l.key)+lockSuffix, 1, l.ttl)
+	return l, nil
+}
+
+// locker is a store.Locker that is backed by a kubestore.Semaphore.
+type locker struct {
+	store          *Store
+	semaphore      *kubestore.Semaphore
+	key            string
+	value          []byte
+	ttl            time.Duration
+	renewLock      chan struct{}
+	deleteOnUnlock bool
+
+	mu     sync.Mutex
+	token  string
+	cancel context.CancelFunc
+}
+
+// Lock blocks until the lock is acquired, or the given context is done. The
+// returned channel is closed if the lock is lost, such as if it could not be
+// renewed before it expired.
+func (l *locker) Lock(ctx context.Context) (<-chan struct{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.token != "" {
+		return nil, fmt.Errorf("%w: lock is already held", store.ErrCannotLock)
+	}
+
+	token, err := l.semaphore.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.value != nil {
+		if err := l.store.Put(ctx, l.key, l.value, nil); err != nil {
+			// Intentionally ignore any errors, as the lock expires anyway.
+			_ = l.semaphore.Release(ctx, token)
+			return nil, err
+		}
+	}
+
+	renewCtx, cancel := context.WithCancel(context.Background())
+	lost := make(chan struct{})
+	go l.renew(renewCtx, token, lost)
+
+	l.token = token
+	l.cancel = cancel
+	return lost, nil
+}
+
+// renew periodically renews the lock held with the given token, until the
+// given context is done. The given channel is closed once the lock is no
+// longer renewed.
+func (l *locker) renew(ctx context.Context, token string, lost chan struct{}) {
+	defer close(lost)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.semaphore.Renew(ctx, token); err != nil {
+				return
+			}
+		case <-l.renewLock:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Unlock releases the lock, and deletes the key if DeleteOnUnlock was given.
+func (l *locker) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.token == "" {
+		return fmt.Errorf("%w: lock is not held", store.ErrCannotLock)
+	}
+
+	l.cancel()
+	token := l.token
+	l.token = ""
+	l.cancel = nil
+
+	if l.deleteOnUnlock {
+		if err := l.store.Delete(ctx, l.key); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+			return err
+		}
+	}
+	return l.semaphore.Release(ctx, token)
+}
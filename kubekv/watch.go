@@ -0,0 +1,152 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubekv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/joshdk/kubestore"
+	"github.com/kvtools/valkeyrie/store"
+)
+
+// Watch returns a channel which receives the value of the given key, and then
+// its new value every time that it changes. The channel is closed once the
+// given context is done, or if the key is deleted.
+func (s *Store) Watch(ctx context.Context, key string, _ *store.ReadOptions) (<-chan *store.KVPair, error) {
+	key = normalize(key)
+
+	// The key must exist before it can be watched.
+	pair, err := s.Get(ctx, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.changes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *store.KVPair, 1)
+	go func() {
+		defer close(ch)
+		ch <- pair
+
+		last := pair.LastIndex
+		for range changes {
+			pair, err := s.Get(ctx, key, nil)
+			if err != nil {
+				// The key was deleted, or can no longer be read.
+				return
+			}
+			if pair.LastIndex == last {
+				continue
+			}
+			last = pair.LastIndex
+
+			select {
+			case ch <- pair:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// WatchTree returns a channel which receives every key under the given
+// directory, along with its value, and then again every time that any of them
+// change. The channel is closed once the given context is done.
+func (s *Store) WatchTree(ctx context.Context, directory string, _ *store.ReadOptions) (<-chan []*store.KVPair, error) {
+	directory = normalize(directory)
+
+	pairs, err := s.list(ctx, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.changes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []*store.KVPair, 1)
+	go func() {
+		defer close(ch)
+		ch <- pairs
+
+		last := pairs
+		for range changes {
+			pairs, err := s.list(ctx, directory)
+			if err != nil || equalPairs(pairs, last) {
+				continue
+			}
+			last = pairs
+
+			select {
+			case ch <- pairs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// changes returns a channel which receives a value whenever a key may have
+// changed. If the Store does not implement the kubestore.Subscriber interface,
+// the channel receives a value at the configured poll interval instead. The
+// channel is closed once the given context is done.
+func (s *Store) changes(ctx context.Context) (<-chan struct{}, error) {
+	events, err := kubestore.Subscribe(ctx, s.store)
+	if err != nil && !errors.Is(err, kubestore.ErrorNotSupported) {
+		return nil, err
+	}
+
+	ch := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+
+	go func() {
+		defer close(ch)
+		if events != nil {
+			for range events {
+				notify()
+			}
+			return
+		}
+
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				notify()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// equalPairs returns true if the given lists of pairs are identical.
+func equalPairs(a, b []*store.KVPair) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || a[i].LastIndex != b[i].LastIndex || !bytes.Equal(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
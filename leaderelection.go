@@ -0,0 +1,140 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Assert that resourceLock implements the resourcelock.Interface interface.
+var _ resourcelock.Interface = (*resourceLock)(nil)
+
+type resourceLock struct {
+	store    Store
+	key      string
+	identity string
+
+	mu       sync.Mutex
+	observed json.RawMessage
+}
+
+// NewResourceLock returns a resourcelock.Interface that stores the leader
+// election record under the given key of the given Store, so that the
+// client-go leaderelection package can be used with any kind of Store,
+// including a file Store for local development.
+//
+// The Store must implement the Updater interface, as the leader election
+// record is modified using atomic updates.
+func NewResourceLock(store Store, key, identity string) resourcelock.Interface {
+	return &resourceLock{
+		store:    store,
+		key:      key,
+		identity: identity,
+	}
+}
+
+// Get returns the current leader election record.
+//
+// If there is no leader election record, a Kubernetes NotFound error is
+// returned, as expected by the leaderelection package.
+func (l *resourceLock) Get(ctx context.Context) (*resourcelock.LeaderElectionRecord, []byte, error) {
+	var data json.RawMessage
+	if err := l.store.Get(ctx, l.key, &data); err != nil {
+		if errors.Is(err, ErrorKeyNotFound) {
+			return nil, nil, apierrors.NewNotFound(schema.GroupResource{Group: annotationPrefix, Resource: "locks"}, l.key)
+		}
+		return nil, nil, err
+	}
+
+	var record resourcelock.LeaderElectionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, nil, err
+	}
+
+	// Remember the record, so that subsequent updates can be made
+	// conditional on it being unchanged.
+	l.mu.Lock()
+	l.observed = data
+	l.mu.Unlock()
+
+	return &record, data, nil
+}
+
+// Create creates the leader election record, failing if it already exists.
+func (l *resourceLock) Create(ctx context.Context, record resourcelock.LeaderElectionRecord) error {
+	return l.write(ctx, record, func(current json.RawMessage) error {
+		if current != nil {
+			return fmt.Errorf("%w: leader election record %q already exists", ErrorConflict, l.key)
+		}
+		return nil
+	})
+}
+
+// Update replaces the leader election record, failing if it has changed since
+// it was last retrieved with Get.
+func (l *resourceLock) Update(ctx context.Context, record resourcelock.LeaderElectionRecord) error {
+	l.mu.Lock()
+	observed := l.observed
+	l.mu.Unlock()
+
+	if observed == nil {
+		return errors.New("leader election record not initialized, call Get first")
+	}
+
+	return l.write(ctx, record, func(current json.RawMessage) error {
+		if !bytes.Equal(current, observed) {
+			return fmt.Errorf("%w: leader election record %q has been modified", ErrorConflict, l.key)
+		}
+		return nil
+	})
+}
+
+// RecordEvent does nothing, as Kubernetes events must be recorded against a
+// Kubernetes object, and a Store is not necessarily backed by one.
+func (l *resourceLock) RecordEvent(string) {}
+
+// Identity returns the identity of this candidate.
+func (l *resourceLock) Identity() string {
+	return l.identity
+}
+
+// Describe returns a description of the lock.
+func (l *resourceLock) Describe() string {
+	return fmt.Sprintf("%s (key %s)", Describe(l.store), l.key)
+}
+
+// write atomically stores the given record, provided that the given
+// precondition is satisfied by the current record.
+func (l *resourceLock) write(ctx context.Context, record resourcelock.LeaderElectionRecord, precondition func(current json.RawMessage) error) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	err = Update(ctx, l.store, l.key, func(current json.RawMessage) (json.RawMessage, error) {
+		if err := precondition(current); err != nil {
+			return nil, err
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.observed = data
+	l.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// Merge deep-merges the given partial value into the JSON value stored under
+// the named key, following the semantics of a JSON merge patch (RFC 7386).
+// Fields of objects are merged recursively, fields set to null are removed,
+// and any other values, including arrays, are replaced. If the key does not
+// exist, it is created.
+//
+// The value is modified atomically if the Store implements the Updater
+// interface, so that concurrent writers merging different fields of the same
+// value do not overwrite each other. Otherwise, the value is read and written
+// separately.
+func Merge(ctx context.Context, store Store, key string, partial interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(partial)
+	if err != nil {
+		return err
+	}
+
+	var patch interface{}
+	if err := unmarshalNumber(data, &patch); err != nil {
+		return err
+	}
+
+	return updateOrSet(ctx, store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		var document interface{}
+		if current != nil {
+			if err := unmarshalNumber(current, &document); err != nil {
+				return nil, err
+			}
+		}
+
+		return json.Marshal(mergePatch(document, patch))
+	})
+}
+
+// mergePatch returns the result of applying the given merge patch to the given
+// document.
+func mergePatch(document, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		// Anything other than an object replaces the document entirely.
+		return patch
+	}
+
+	documentObject, ok := document.(map[string]interface{})
+	if !ok {
+		documentObject = make(map[string]interface{}, len(patchObject))
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(documentObject, name)
+			continue
+		}
+		documentObject[name] = mergePatch(documentObject[name], value)
+	}
+
+	return documentObject
+}
+
+// unmarshalNumber unmarshals the given JSON data into the given value pointer,
+// decoding numbers as a json.Number so that they do not lose precision when
+// marshalled again.
+func unmarshalNumber(data []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
@@ -0,0 +1,308 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Migration upgrades a value from one schema version to the next.
+type Migration func(value json.RawMessage) (json.RawMessage, error)
+
+// versionedEnvelope is the stored form of a value along with its schema
+// version.
+type versionedEnvelope struct {
+	Version int             `json:"schemaVersion"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// migrationSet is the ordered list of migrations registered for a key prefix.
+type migrationSet struct {
+	prefix     string
+	migrations []Migration
+}
+
+// Assert that MigrationStore implements the Store interface.
+var _ Store = (*MigrationStore)(nil)
+
+// MigrationStore is a Store that wraps another Store, and tracks the schema
+// version of every value so that values can be upgraded by registered
+// migrations.
+type MigrationStore struct {
+	store Store
+
+	mu        sync.Mutex
+	sets      []migrationSet
+	lazy      bool
+	writeBack bool
+}
+
+// NewMigrationStore returns a MigrationStore that wraps the given Store.
+//
+// Values are written along with the current schema version for their key,
+// which is the number of migrations registered for the longest prefix that
+// matches the key. Values that were written without a schema version, such as
+// those written before a MigrationStore was used, are version zero.
+func NewMigrationStore(store Store) *MigrationStore {
+	return &MigrationStore{
+		store: store,
+	}
+}
+
+// Register adds the given migrations for keys that begin with the given
+// prefix, which may also be the entire name of a single key. The migration at
+// index N upgrades a value from version N to version N+1, so migrations must
+// only ever be appended to in subsequent releases.
+func (s *MigrationStore) Register(prefix string, migrations ...Migration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, set := range s.sets {
+		if set.prefix == prefix {
+			s.sets[i].migrations = append(set.migrations, migrations...)
+			return
+		}
+	}
+	s.sets = append(s.sets, migrationSet{prefix: prefix, migrations: migrations})
+}
+
+// UpgradeOnRead configures calls to Store.Get to upgrade entries that were
+// written with an older schema version by running the registered migrations,
+// rather than returning an error. If writeBack is true, the upgraded entry is
+// also written back to the wrapped Store, so that the Store is upgraded
+// gradually as entries are read.
+func (s *MigrationStore) UpgradeOnRead(writeBack bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lazy = true
+	s.writeBack = writeBack
+}
+
+// Get reads the named entry from the wrapped Store and stores the contents
+// into the given value pointer.
+//
+// If the entry was written with an older schema version, and the
+// MigrationStore is configured with UpgradeOnRead, the entry is upgraded.
+// Otherwise, if the entry was written with a schema version other than the
+// current version, an error matching the ErrorVersionMismatch sentinel error is
+// returned.
+func (s *MigrationStore) Get(ctx context.Context, key string, value interface{}) error {
+	var data json.RawMessage
+	if err := s.store.Get(ctx, key, &data); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	lazy, writeBack := s.lazy, s.writeBack
+	s.mu.Unlock()
+
+	envelope := unwrapVersioned(data)
+	if current := len(s.migrations(key)); envelope.Version < current && lazy {
+		var err error
+		if envelope, err = s.upgrade(key, envelope); err != nil {
+			return err
+		}
+
+		if writeBack {
+			// Intentionally ignore any errors, as the entry will be
+			// upgraded again the next time it is read.
+			_ = s.migrate(ctx, key)
+		}
+	} else if envelope.Version != current {
+		return fmt.Errorf("%w: key %s is version %d, expected version %d", ErrorVersionMismatch, key, envelope.Version, current)
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(envelope.Value, value)
+}
+
+// Set writes the named entry and value into the wrapped Store, along with the
+// current schema version for the key.
+func (s *MigrationStore) Set(ctx context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Set(ctx, key, versionedEnvelope{
+		Version: len(s.migrations(key)),
+		Value:   data,
+	})
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s *MigrationStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s *MigrationStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s *MigrationStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s *MigrationStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// Update atomically modifies the named entry in the wrapped Store. The current
+// contents are upgraded to the current schema version before being given to
+// the given function.
+func (s *MigrationStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		var value json.RawMessage
+		if current != nil {
+			envelope, err := s.upgrade(key, unwrapVersioned(current))
+			if err != nil {
+				return nil, err
+			}
+			value = envelope.Value
+		}
+
+		result, err := fn(value)
+		if err != nil || result == nil {
+			return nil, err
+		}
+
+		return json.Marshal(versionedEnvelope{
+			Version: len(s.migrations(key)),
+			Value:   result,
+		})
+	})
+}
+
+// MigrateAll upgrades every entry in the wrapped Store that was written with
+// an older schema version, by running the registered migrations in order.
+// Entries are modified atomically if the wrapped Store supports updates.
+func (s *MigrationStore) MigrateAll(ctx context.Context) error {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := s.migrate(ctx, key); err != nil {
+			return fmt.Errorf("migrating key %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// migrate upgrades the named entry to the current schema version, if it was
+// written with an older version.
+func (s *MigrationStore) migrate(ctx context.Context, key string) error {
+	fn := func(current json.RawMessage) (json.RawMessage, error) {
+		if current == nil {
+			return nil, nil
+		}
+
+		envelope := unwrapVersioned(current)
+		if envelope.Version == len(s.migrations(key)) {
+			return current, nil
+		}
+
+		upgraded, err := s.upgrade(key, envelope)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(upgraded)
+	}
+
+	return updateOrSet(ctx, s.store, key, fn)
+}
+
+// upgrade runs the migrations needed to bring the given envelope from its
+// schema version up to the current version for the given key.
+func (s *MigrationStore) upgrade(key string, envelope versionedEnvelope) (versionedEnvelope, error) {
+	migrations := s.migrations(key)
+	if envelope.Version > len(migrations) {
+		return envelope, fmt.Errorf("%w: key %s is version %d, which is newer than version %d", ErrorVersionMismatch, key, envelope.Version, len(migrations))
+	}
+
+	for _, migration := range migrations[envelope.Version:] {
+		value, err := migration(envelope.Value)
+		if err != nil {
+			return envelope, err
+		}
+		envelope.Version++
+		envelope.Value = value
+	}
+
+	return envelope, nil
+}
+
+// migrations returns the migrations registered for the longest prefix that
+// matches the given key.
+func (s *MigrationStore) migrations(key string) []Migration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var best *migrationSet
+	for i, set := range s.sets {
+		if strings.HasPrefix(key, set.prefix) && (best == nil || len(set.prefix) > len(best.prefix)) {
+			best = &s.sets[i]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.migrations
+}
+
+// unwrapVersioned returns the envelope held by the given data, or an envelope
+// at version zero holding the data as-is, if the data was written without a
+// schema version.
+func unwrapVersioned(data json.RawMessage) versionedEnvelope {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err == nil && len(fields) == 2 {
+		var envelope versionedEnvelope
+		if _, found := fields["schemaVersion"]; found {
+			if _, found := fields["value"]; found && json.Unmarshal(data, &envelope) == nil {
+				return envelope
+			}
+		}
+	}
+	return versionedEnvelope{Value: data}
+}
+
+// updateOrSet atomically modifies the named entry in the given Store with the
+// given function if the Store supports updates, otherwise the entry is read
+// and written separately.
+func updateOrSet(ctx context.Context, store Store, key string, fn UpdateFunc) error {
+	err := Update(ctx, store, key, fn)
+	if !errors.Is(err, ErrorNotSupported) {
+		return err
+	}
+
+	var current json.RawMessage
+	if err := store.Get(ctx, key, &current); err != nil {
+		// The entry may not exist, in which case the function is given nil.
+		if !errors.Is(err, ErrorKeyNotFound) {
+			return err
+		}
+		current = nil
+	}
+
+	result, err := fn(current)
+	if err != nil || unchanged(current, result) {
+		return err
+	}
+	if result == nil {
+		return store.Delete(ctx, key)
+	}
+	return store.Set(ctx, key, result)
+}
@@ -0,0 +1,260 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ModTimer represents a Store that is capable of reporting when each of its
+// keys was last modified.
+type ModTimer interface {
+	// ModTimes returns the time that each key was last modified.
+	ModTimes(ctx context.Context) (map[string]time.Time, error)
+}
+
+// ModTimes returns the time that each key in the given Store was last
+// modified, so that operators can find stale or frequently rewritten keys.
+// Keys whose modification time is unknown are omitted.
+//
+// Stores backed by a Kubernetes resource report the time of the most recent
+// write by any field manager that owns each key, as recorded in the managed
+// fields of the backing object. Since a field manager may have written other
+// keys since, this is the latest time at which the key could have been
+// modified.
+//
+// If the Store does not implement the ModTimer interface, an error matching
+// the ErrorNotSupported sentinel error is returned.
+func ModTimes(ctx context.Context, store Store) (map[string]time.Time, error) {
+	if modTimer, ok := store.(ModTimer); ok {
+		return modTimer.ModTimes(ctx)
+	}
+	return nil, fmt.Errorf("%w: %s does not report modification times", ErrorNotSupported, Describe(store).Backend)
+}
+
+// fieldTimes returns, for each field under the given path of the given managed
+// fields, the time of the most recent write by a field manager that owns it.
+func fieldTimes(entries []metav1.ManagedFieldsEntry, path ...string) map[string]time.Time {
+	times := make(map[string]time.Time)
+	for _, entry := range entries {
+		if entry.Time == nil || entry.FieldsV1 == nil {
+			continue
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+
+		// Descend to the given path.
+		for _, name := range path {
+			data, found := fields["f:"+name]
+			if !found {
+				fields = nil
+				break
+			}
+			fields = nil
+			if err := json.Unmarshal(data, &fields); err != nil {
+				break
+			}
+		}
+
+		for name := range fields {
+			if !strings.HasPrefix(name, "f:") {
+				continue
+			}
+			name = strings.TrimPrefix(name, "f:")
+			if entry.Time.Time.After(times[name]) {
+				times[name] = entry.Time.Time
+			}
+		}
+	}
+	return times
+}
+
+// latest records the given time for the given key, unless a later time has
+// already been recorded.
+func latest(times map[string]time.Time, key string, t time.Time) {
+	if t.After(times[key]) {
+		times[key] = t
+	}
+}
+
+// ModTimes returns the time that each annotation in the backing resource was
+// last modified, as recorded in its managed fields. Chunks are reported under
+// the key that they belong to.
+func (c annotationStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	resource, err := c.client.Get(ctx, c.name, c.options.getOptions())
+	if err != nil {
+		if isResourceMissingError(err) {
+			return map[string]time.Time{}, nil
+		}
+		return nil, err
+	}
+
+	annotations := resource.GetAnnotations()
+	times := make(map[string]time.Time)
+	for annotation, t := range fieldTimes(resource.GetManagedFields(), "metadata", "annotations") {
+		// Disregard annotations that do not match, or no longer exist.
+		if _, found := annotations[annotation]; !found || !strings.HasPrefix(annotation, annotationPrefix+"/") {
+			continue
+		}
+		key := strings.TrimPrefix(annotation, annotationPrefix+"/")
+		if base, ok := chunkKey(annotations, key); ok {
+			key = base
+		}
+		latest(times, key, t)
+	}
+	return times, nil
+}
+
+// ModTimes returns the time that each entry in the backing ConfigMap data was
+// last modified, as recorded in its managed fields. Only the metadata of the
+// ConfigMap is read, when possible.
+func (c configMapStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	var object metav1.Object
+	var err error
+	if c.metadata != nil {
+		object, err = c.metadata.Get(ctx, c.name, c.getOptions)
+	} else {
+		object, err = c.client.Get(ctx, c.name, c.getOptions)
+	}
+	if err != nil {
+		if isResourceMissingError(err) {
+			return map[string]time.Time{}, nil
+		}
+		return nil, err
+	}
+
+	return fieldTimes(object.GetManagedFields(), "data"), nil
+}
+
+// ModTimes returns the time that each entry in the backing Secret data was
+// last modified, as recorded in its managed fields. Only the metadata of the
+// Secret is read, when possible.
+func (c secretStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	var object metav1.Object
+	var err error
+	if c.metadata != nil {
+		object, err = c.metadata.Get(ctx, c.name, c.getOptions)
+	} else {
+		object, err = c.client.Get(ctx, c.name, c.getOptions)
+	}
+	if err != nil {
+		if isResourceMissingError(err) {
+			return map[string]time.Time{}, nil
+		}
+		return nil, err
+	}
+
+	return fieldTimes(object.GetManagedFields(), "data"), nil
+}
+
+// ModTimes returns the modification time of each file in the backing
+// directory.
+func (s fileStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	keys, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make(map[string]time.Time, len(keys))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		info, err := os.Stat(filepath.Join(s.directory, key))
+		if err != nil {
+			// The file may have been removed since it was listed.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		times[key] = info.ModTime()
+	}
+	return times, nil
+}
+
+// ModTimes returns the modification times of the wrapped Store.
+func (s auditStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	return ModTimes(ctx, s.store)
+}
+
+// ModTimes returns the modification times of the wrapped Store.
+func (s canonicalStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	return ModTimes(ctx, s.store)
+}
+
+// ModTimes returns the modification times of the wrapped Store.
+func (s decodeStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	return ModTimes(ctx, s.store)
+}
+
+// ModTimes returns the modification times of the wrapped Store. Deltas are
+// reported under the key that they belong to.
+func (s deltaStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	times, err := ModTimes(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, t := range times {
+		if base, _, _, ok := parseDeltaKey(key); ok {
+			if _, exists := times[base]; exists {
+				latest(times, base, t)
+				delete(times, key)
+			}
+		}
+	}
+	return times, nil
+}
+
+// ModTimes returns the modification times of the wrapped Store.
+func (s hmacStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	return ModTimes(ctx, s.store)
+}
+
+// ModTimes returns the modification times of the wrapped Store, converting
+// keys back using the inverse function.
+func (s keyTransformStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	times, err := ModTimes(ctx, s.store)
+	if err != nil || s.inverse == nil {
+		return times, err
+	}
+
+	converted := make(map[string]time.Time, len(times))
+	for key, t := range times {
+		// Disregard keys that have no inverse.
+		if key = s.inverse(key); key != "" {
+			converted[key] = t
+		}
+	}
+	return converted, nil
+}
+
+// ModTimes returns the modification times of the wrapped Store.
+func (s quotaStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	return ModTimes(ctx, s.store)
+}
+
+// ModTimes returns the modification times of the wrapped Store.
+func (s sortedStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	return ModTimes(ctx, s.store)
+}
+
+// ModTimes returns the modification times of the wrapped Store.
+func (s valueHookStore) ModTimes(ctx context.Context) (map[string]time.Time, error) {
+	return ModTimes(ctx, s.store)
+}
@@ -0,0 +1,46 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFieldTimes(t *testing.T) {
+	older := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	newer := metav1.NewTime(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	entries := []metav1.ManagedFieldsEntry{
+		{
+			Manager:  "first",
+			Time:     &older,
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{".":{},"f:a":{},"f:b":{}},"f:metadata":{"f:labels":{"f:x":{}}}}`)},
+		},
+		{
+			Manager:  "second",
+			Time:     &newer,
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:b":{}}}`)},
+		},
+		{
+			Manager:  "third",
+			Time:     &newer,
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:c":{}}}}`)},
+		},
+	}
+
+	times := fieldTimes(entries, "data")
+	if len(times) != 2 {
+		t.Fatalf("expected 2 fields, got %v", times)
+	}
+	if !times["a"].Equal(older.Time) {
+		t.Fatalf("expected %v for a, got %v", older, times["a"])
+	}
+	if !times["b"].Equal(newer.Time) {
+		t.Fatalf("expected %v for b, got %v", newer, times["b"])
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// MultiGetter represents a Store that is capable of retrieving the contents
+// of multiple keys at once.
+type MultiGetter interface {
+	// GetMulti retrieves the contents of the given keys. Keys that do not
+	// exist are omitted from the result.
+	GetMulti(ctx context.Context, keys []string) (map[string]json.RawMessage, error)
+}
+
+// GetMulti retrieves the contents of the given keys from the given Store. Keys
+// that do not exist are omitted from the result.
+//
+// If the Store implements the MultiGetter interface, then the keys are
+// retrieved at once. Otherwise, each key is retrieved using a call to
+// Store.Get, with up to the number of concurrent calls configured by the
+// WithConcurrency option.
+func GetMulti(ctx context.Context, store Store, keys []string, opts ...Option) (map[string]json.RawMessage, error) {
+	if getter, ok := store.(MultiGetter); ok {
+		return getter.GetMulti(ctx, keys)
+	}
+
+	var mu sync.Mutex
+	values := make(map[string]json.RawMessage, len(keys))
+	err := forEach(ctx, keys, newOptions(opts).concurrency, func(ctx context.Context, key string) error {
+		var value json.RawMessage
+		if err := store.Get(ctx, key, &value); err != nil {
+			// Disregard keys that do not exist.
+			if errors.Is(err, ErrorKeyNotFound) {
+				return nil
+			}
+			// Some other kind of error was encountered.
+			return err
+		}
+
+		mu.Lock()
+		values[key] = value
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// GetMulti reads the given keys from the backing resource annotations using a
+// single API call.
+func (c annotationStore) GetMulti(ctx context.Context, keys []string) (map[string]json.RawMessage, error) {
+	// Use the Kuberneties API to get the backing resource.
+	resource, err := c.client.Get(ctx, c.name, c.options.getOptions())
+	if err != nil {
+		// If the backing resource does not exist, then the keys also do not
+		// exist, so return an empty result.
+		if isResourceMissingError(err) {
+			return map[string]json.RawMessage{}, nil
+		}
+		// Some other kind of error was encountered.
+		return nil, err
+	}
+
+	values := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		if data, found := readAnnotation(resource.GetAnnotations(), key); found {
+			values[key] = json.RawMessage(data)
+		}
+	}
+
+	return values, nil
+}
+
+// GetMulti reads the given keys from the backing ConfigMap using a single API
+// call.
+func (c configMapStore) GetMulti(ctx context.Context, keys []string) (map[string]json.RawMessage, error) {
+	// Use the Kuberneties API to get the backing ConfigMap.
+	configMap, err := c.client.Get(ctx, c.name, c.getOptions)
+	if err != nil {
+		// If the backing ConfigMap does not exist, then the keys also do not
+		// exist, so return an empty result.
+		if isResourceMissingError(err) {
+			return map[string]json.RawMessage{}, nil
+		}
+		// Some other kind of error was encountered.
+		return nil, err
+	}
+
+	values := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		if data, found := configMap.Data[key]; found {
+			values[key] = json.RawMessage(data)
+		}
+	}
+
+	return values, nil
+}
+
+// GetMulti reads the given keys from the backing Secret using a single API
+// call.
+func (c secretStore) GetMulti(ctx context.Context, keys []string) (map[string]json.RawMessage, error) {
+	// Use the Kuberneties API to get the backing Secret.
+	secret, err := c.client.Get(ctx, c.name, c.getOptions)
+	if err != nil {
+		// If the backing Secret does not exist, then the keys also do not
+		// exist, so return an empty result.
+		if isResourceMissingError(err) {
+			return map[string]json.RawMessage{}, nil
+		}
+		// Some other kind of error was encountered.
+		return nil, err
+	}
+
+	values := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		if data, found := secret.Data[key]; found {
+			values[key] = json.RawMessage(data)
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,40 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"fmt"
+)
+
+// MustGet calls Store.Get with the given arguments, and panics if an error is
+// returned. It is intended for use during program initialization, for values
+// that are required in order to proceed.
+func MustGet(ctx context.Context, store Store, key string, value interface{}) {
+	if err := store.Get(ctx, key, value); err != nil {
+		panic(fmt.Sprintf("kubestore: get %q: %v", key, err))
+	}
+}
+
+// MustSet calls Store.Set with the given arguments, and panics if an error is
+// returned. It is intended for use during program initialization, for values
+// that are required in order to proceed.
+func MustSet(ctx context.Context, store Store, key string, value interface{}) {
+	if err := store.Set(ctx, key, value); err != nil {
+		panic(fmt.Sprintf("kubestore: set %q: %v", key, err))
+	}
+}
+
+// Must is a helper that wraps a call to a function returning (Store, error)
+// and panics if the error is non-nil. It is intended for use in variable
+// initializations such as:
+//
+//	var store = kubestore.Must(kubestore.NewConfigMapStore("example"))
+func Must(store Store, err error) Store {
+	if err != nil {
+		panic(fmt.Sprintf("kubestore: %v", err))
+	}
+	return store
+}
@@ -0,0 +1,68 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// nameAnnotation is the annotation that holds the original name of a Store,
+// when the name of the backing object was normalized.
+const nameAnnotation = annotationPrefix + "/name"
+
+// invalidNameChars matches runs of characters which are replaced when
+// normalizing a name. Dots are replaced as well, as they are only permitted
+// between alphanumeric characters.
+var invalidNameChars = regexp.MustCompile(`[^-a-z0-9]+`)
+
+// resolveName returns the name of the object backing a Store with the given
+// name, along with the original name if it had to be normalized. Names which
+// are not valid object names are normalized, unless strict names are enabled,
+// in which case an InvalidNameError is returned.
+func resolveName(name string, o options) (string, string, error) {
+	reasons := validation.IsDNS1123Subdomain(name)
+	if len(reasons) == 0 {
+		return name, "", nil
+	}
+	if o.strictNames {
+		return "", "", &InvalidNameError{Name: name, Reasons: reasons}
+	}
+	return normalizeName(name), name, nil
+}
+
+// normalizeName converts the given name into a valid object name, by
+// lowercasing it, replacing invalid characters, and truncating it. A hash of
+// the original name is appended, so that distinct names remain distinct.
+func normalizeName(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	suffix := hex.EncodeToString(sum[:])[:10]
+
+	normalized := invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	if limit := validation.DNS1123SubdomainMaxLength - len(suffix) - 1; len(normalized) > limit {
+		normalized = normalized[:limit]
+	}
+
+	// Names must begin and end with an alphanumeric character.
+	normalized = strings.Trim(normalized, "-")
+	if normalized == "" {
+		return suffix
+	}
+	return normalized + "-" + suffix
+}
+
+// nameAnnotations returns the annotations applied to an object backing a Store
+// when it is created on-demand, which preserve the given original name if the
+// name was normalized.
+func nameAnnotations(original string) map[string]string {
+	if original == "" {
+		return nil
+	}
+	return map[string]string{nameAnnotation: original}
+}
@@ -0,0 +1,42 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+// NewNodeConfigMapStore returns a Store backed by a ConfigMap that is specific
+// to the node that the current pod is running on, named <name>-<node>. This
+// allows each pod of a DaemonSet to keep per-node state that survives pod
+// restarts, without inventing its own naming conventions.
+//
+// The name of the node is read from the NODE_NAME environment variable, which
+// must be set from the spec.nodeName field using the downward API:
+//
+//	env:
+//	- name: NODE_NAME
+//	  valueFrom:
+//	    fieldRef:
+//	      fieldPath: spec.nodeName
+//
+// This Store is otherwise identical to the Store returned by
+// NewConfigMapStore.
+func NewNodeConfigMapStore(name string, opts ...Option) (Store, error) {
+	node, err := inClusterNodeName()
+	if err != nil {
+		return nil, err
+	}
+	return NewConfigMapStore(name+"-"+node, opts...)
+}
+
+// NewNodeSecretStore returns a Store backed by a Secret that is specific to
+// the node that the current pod is running on, named <name>-<node>, as
+// described by NewNodeConfigMapStore.
+//
+// This Store is otherwise identical to the Store returned by NewSecretStore.
+func NewNodeSecretStore(name string, opts ...Option) (Store, error) {
+	node, err := inClusterNodeName()
+	if err != nil {
+		return nil, err
+	}
+	return NewSecretStore(name+"-"+node, opts...)
+}
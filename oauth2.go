@@ -0,0 +1,97 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Assert that tokenSource implements the oauth2.TokenSource interface.
+var _ oauth2.TokenSource = (*tokenSource)(nil)
+
+type tokenSource struct {
+	store   Store
+	key     string
+	src     oauth2.TokenSource
+	timeout time.Duration
+
+	mu sync.Mutex
+}
+
+// NewTokenSource returns an oauth2.TokenSource that caches tokens under the
+// given key of the given Store, so that all replicas share a single token.
+//
+// The cached token is returned for as long as it is valid. Once it expires,
+// a new token is obtained from the given TokenSource and cached in its place.
+// If the Store implements the Updater interface, the new token is cached using
+// an atomic update, so that when different replicas refresh concurrently, they
+// all settle on the same token. Backing the Store with a Secret is
+// recommended.
+func NewTokenSource(store Store, key string, src oauth2.TokenSource) oauth2.TokenSource {
+	return &tokenSource{
+		store:   store,
+		key:     key,
+		src:     src,
+		timeout: 30 * time.Second,
+	}
+}
+
+// Token returns the cached token if it is valid, or a refreshed token
+// otherwise.
+func (s *tokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	// Return the cached token, if it is still valid.
+	var token oauth2.Token
+	err := s.store.Get(ctx, s.key, &token)
+	switch {
+	case err == nil && token.Valid():
+		return &token, nil
+	case err != nil && !errors.Is(err, ErrorKeyNotFound):
+		return nil, err
+	}
+
+	// Obtain a new token before updating the Store, as the update function
+	// may be called more than once.
+	fresh, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the new token atomically, unless another replica has cached a
+	// valid token in the interim, in which case that token is used instead.
+	refreshed := fresh
+	err = Update(ctx, s.store, s.key, func(current json.RawMessage) (json.RawMessage, error) {
+		refreshed = fresh
+		if current != nil {
+			var cached oauth2.Token
+			if err := json.Unmarshal(current, &cached); err == nil && cached.Valid() {
+				refreshed = &cached
+				return current, nil
+			}
+		}
+		return json.Marshal(fresh)
+	})
+
+	// Fall back to caching the token non-atomically.
+	if errors.Is(err, ErrorNotSupported) {
+		err = s.store.Set(ctx, s.key, fresh)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return refreshed, nil
+}
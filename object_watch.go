@@ -0,0 +1,155 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"sort"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Assert that configMapStore and secretStore implement the Subscriber
+// interface.
+var (
+	_ Subscriber = configMapStore{}
+	_ Subscriber = secretStore{}
+)
+
+// Subscribe watches the backing ConfigMap using an informer, and emits an
+// event whenever one of its data entries is changed. The ConfigMap does not
+// need to exist, and events are emitted for every entry once it is created.
+func (c configMapStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	// Create an informer that only watches the single backing ConfigMap.
+	selector := fields.OneTermEqualSelector("metadata.name", c.name).String()
+	informer := cache.NewSharedInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return c.client.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return c.client.Watch(ctx, options)
+		},
+	}, &apiv1.ConfigMap{}, 0)
+
+	return watchData(ctx, informer, func(obj interface{}) map[string]string {
+		if configMap, ok := obj.(*apiv1.ConfigMap); ok {
+			return configMap.Data
+		}
+		return nil
+	}), nil
+}
+
+// Subscribe watches the backing Secret using an informer, and emits an event
+// whenever one of its data entries is changed. The Secret does not need to
+// exist, and events are emitted for every entry once it is created.
+func (c secretStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	// Create an informer that only watches the single backing Secret.
+	selector := fields.OneTermEqualSelector("metadata.name", c.name).String()
+	informer := cache.NewSharedInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return c.client.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return c.client.Watch(ctx, options)
+		},
+	}, &apiv1.Secret{}, 0)
+
+	return watchData(ctx, informer, func(obj interface{}) map[string]string {
+		secret, ok := obj.(*apiv1.Secret)
+		if !ok {
+			return nil
+		}
+		data := make(map[string]string, len(secret.Data))
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+		return data
+	}), nil
+}
+
+// watchData runs the given informer until the given context is done, and
+// emits events for every data entry that differs between successive versions
+// of the watched object, as returned by the given function.
+func watchData(ctx context.Context, informer cache.SharedInformer, dataOf func(obj interface{}) map[string]string) <-chan Event {
+	events := make(chan Event)
+
+	// notify emits events for every key that differs between the given old
+	// and new versions of the backing object.
+	notify := func(oldObj, newObj interface{}) {
+		for _, event := range dataEvents(dataOf(objectOf(oldObj)), dataOf(objectOf(newObj))) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			notify(nil, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			notify(oldObj, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			notify(obj, nil)
+		},
+	})
+
+	go func() {
+		// Run blocks until the context is done, and all event handlers have
+		// returned, after which it is safe to close the channel.
+		informer.Run(ctx.Done())
+		close(events)
+	}()
+
+	return events
+}
+
+// objectOf returns the given informer object, unwrapping deleted objects
+// whose final state was unknown.
+func objectOf(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
+
+// dataEvents returns events for every key whose value differs between the
+// given old and new sets of data entries.
+func dataEvents(oldData, newData map[string]string) []Event {
+	var events []Event
+
+	// Find all keys that were created or changed.
+	for key, newValue := range newData {
+		if oldValue, found := oldData[key]; !found || oldValue != newValue {
+			events = append(events, Event{Type: EventSet, Key: key})
+		}
+	}
+
+	// Find all keys that were removed.
+	for key := range oldData {
+		if _, found := newData[key]; !found {
+			events = append(events, Event{Type: EventDelete, Key: key})
+		}
+	}
+
+	// Emit events in a stable order.
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+
+	return events
+}
@@ -0,0 +1,99 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+// ErrorInvalidDSN is a sentinel error for indicating that a DSN used when
+// calling Open could not be parsed, or referenced an unknown backend.
+var ErrorInvalidDSN = errors.New("invalid dsn")
+
+// Open returns a Store for the backend described by the given DSN, allowing
+// the choice of backend to be made a configuration value. The following forms
+// are supported:
+//
+//	configmap://namespace/name
+//	secret://namespace/name
+//	annotation://group/version/resource/name
+//	file:///path/to/directory
+//
+// For the configmap and secret backends, the namespace may be omitted (as in
+// "configmap:///name") in order to use the current pod's namespace. For the
+// annotation backend, the group is empty for the core API group (as in
+// "annotation:///v1/pods/name"), and the namespace may be given with a
+// "namespace" query parameter, otherwise the current pod's namespace is used.
+//
+// The Kubernetes backends depend on the presence of a service account in order
+// to interact with the Kubernetes API.
+func Open(ctx context.Context, dsn string, opts ...Option) (Store, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorInvalidDSN, err)
+	}
+
+	// The file backend has no dependence on the Kubernetes API.
+	if u.Scheme == "file" {
+		if u.Path == "" {
+			return nil, fmt.Errorf("%w: %q is missing a directory", ErrorInvalidDSN, dsn)
+		}
+		// A host would otherwise be silently disregarded, such as in the
+		// mistaken "file://relative/path".
+		if u.Host != "" && u.Host != "localhost" {
+			return nil, fmt.Errorf("%w: %q must be of the form file:///path/to/directory", ErrorInvalidDSN, dsn)
+		}
+		return NewFileStore(u.Path, opts...), nil
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch u.Scheme {
+	case "configmap", "secret":
+		if len(segments) != 1 || segments[0] == "" {
+			return nil, fmt.Errorf("%w: %q must be of the form %s://namespace/name", ErrorInvalidDSN, dsn, u.Scheme)
+		}
+	case "annotation":
+		if len(segments) != 3 {
+			return nil, fmt.Errorf("%w: %q must be of the form annotation://group/version/resource/name", ErrorInvalidDSN, dsn)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown backend %q", ErrorInvalidDSN, u.Scheme)
+	}
+
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Lookup the namespace, which defaults to the current pod's namespace.
+	namespace := u.Host
+	if u.Scheme == "annotation" {
+		namespace = u.Query().Get("namespace")
+	}
+	if namespace == "" {
+		if namespace, err = inClusterNamespace(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch u.Scheme {
+	case "configmap":
+		return newConfigMapStore(config, namespace, segments[0], opts)
+	case "secret":
+		return newSecretStore(config, namespace, segments[0], opts)
+	default:
+		gvr := schema.GroupVersionResource{Group: u.Host, Version: segments[0], Resource: segments[1]}
+		return newAnnotationStore(config, gvr, namespace, segments[2], opts)
+	}
+}
@@ -0,0 +1,428 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Option represents a configurable behavior that can be applied when
+// constructing a Store.
+type Option func(*options)
+
+// options holds the set of configurable behaviors for a Store.
+type options struct {
+	// chunkSize is the maximum size of a single annotation value, beyond
+	// which values are split across multiple annotations. A value of zero
+	// disables chunking.
+	chunkSize int
+
+	// jsonPatch enables the use of JSON Patches with test operations, in
+	// place of merge patches, when modifying annotations.
+	jsonPatch bool
+
+	// negativeTTL is the duration for which a cache remembers that a key was
+	// not found. A value of zero disables negative caching.
+	negativeTTL time.Duration
+
+	// maxBytes is the maximum combined size of the keys and values held by a
+	// cache. A value of zero disables the limit.
+	maxBytes int
+
+	// revalidate enables a cache to revalidate expired values using the
+	// resource version of the backing object.
+	revalidate bool
+
+	// maxKeys is the maximum number of keys that a Store may contain. A value
+	// of zero disables the limit.
+	maxKeys int
+
+	// keyTransform and keyInverse convert keys to and from the form in which
+	// they are stored.
+	keyTransform func(string) string
+	keyInverse   func(string) string
+
+	// beforeSet and afterGet are hooks that are called with every value that
+	// is written or read.
+	beforeSet func(key string, value interface{}) (interface{}, error)
+	afterGet  func(key string, value interface{}) error
+
+	// useNumber and disallowUnknownFields configure how values are decoded
+	// when read.
+	useNumber             bool
+	disallowUnknownFields bool
+
+	// hmacKey is the key used to sign and verify every value.
+	hmacKey []byte
+
+	// auditEndpoint is the URL to which a record of every change is posted,
+	// using auditClient.
+	auditEndpoint string
+	auditClient   *http.Client
+
+	// redact replaces secret values with placeholders when dumping a Store.
+	redact bool
+
+	// concurrency is the maximum number of concurrent calls made by batch
+	// operations against a Store that does not support them natively.
+	concurrency int
+
+	// snapshotEvery is the number of deltas written between full snapshots
+	// of a value. A value of zero disables delta encoding.
+	snapshotEvery int
+
+	// sortedList sorts the keys returned by Store.List lexicographically.
+	sortedList bool
+
+	// batchBytes is the maximum combined size of the values in a single
+	// batch written by BulkLoad.
+	batchBytes int
+
+	// rateLimit and rateBurst limit the rate at which batches are written by
+	// BulkLoad. A rateLimit of zero disables the limit.
+	rateLimit float32
+	rateBurst int
+
+	// readConsistency controls whether reads may be served from the watch
+	// cache of the Kubernetes API server.
+	readConsistency ReadConsistency
+
+	// impersonate enables impersonating the actor carried by the context of
+	// each request.
+	impersonate bool
+
+	// canonicalJSON enables writing every value as canonical JSON.
+	canonicalJSON bool
+
+	// strictNames disables the normalization of names which are not valid
+	// for a backing object.
+	strictNames bool
+}
+
+// describe returns a summary of all non-default options, for use in a
+// Description.
+func (o options) describe() map[string]string {
+	described := make(map[string]string)
+	if o.chunkSize > 0 {
+		described["chunkSize"] = strconv.Itoa(o.chunkSize)
+	}
+	if o.jsonPatch {
+		described["jsonPatch"] = "true"
+	}
+	if o.negativeTTL > 0 {
+		described["negativeTTL"] = o.negativeTTL.String()
+	}
+	if o.readConsistency == ReadAny {
+		described["readConsistency"] = "any"
+	}
+	if len(described) == 0 {
+		return nil
+	}
+	return described
+}
+
+// getOptions returns the options used when reading the backing object, which
+// reflect the configured read consistency.
+func (o options) getOptions() metav1.GetOptions {
+	if o.readConsistency == ReadAny {
+		// A resource version of "0" permits the API server to serve the
+		// object from its watch cache, rather than reading from etcd.
+		return metav1.GetOptions{ResourceVersion: "0"}
+	}
+	return metav1.GetOptions{}
+}
+
+// newOptions returns the result of applying all of the given options.
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithAnnotationChunking configures an annotation Store to split values that
+// are larger than the given size across multiple kubestore/<key>.N
+// annotations.
+//
+// Since chunked annotations are identified by their numeric suffix, keys
+// which themselves end in a dot followed by a number should be avoided when
+// chunking is in use.
+func WithAnnotationChunking(size int) Option {
+	return func(o *options) {
+		o.chunkSize = size
+	}
+}
+
+// WithJSONPatch configures an annotation Store to modify annotations using a
+// JSON Patch, rather than a merge patch. Every existing annotation that is
+// modified is tested against the value that was previously read, so Store.Set
+// and Store.Delete will return the ErrorConflict sentinel error when the
+// annotation was concurrently changed by another writer, rather than silently
+// overwriting it.
+func WithJSONPatch() Option {
+	return func(o *options) {
+		o.jsonPatch = true
+	}
+}
+
+// WithNegativeCache configures a CacheStore to remember that a key was not
+// found for the given duration, so that repeated calls to Store.Get for a key
+// that does not exist are not passed through to the wrapped Store. Remembered
+// keys are forgotten when set using the CacheStore.
+func WithNegativeCache(ttl time.Duration) Option {
+	return func(o *options) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithMaxBytes configures a CacheStore to hold at most the given number of
+// bytes, counting the size of every cached key and value, in addition to the
+// limit on the number of entries. When the limit is exceeded, the least
+// recently used values are evicted until the cache is within the limit, so a
+// few large values may evict many small ones. Values that are larger than the
+// limit are never cached.
+func WithMaxBytes(size int) Option {
+	return func(o *options) {
+		o.maxBytes = size
+	}
+}
+
+// WithRevalidation configures a CacheStore to revalidate expired values,
+// rather than discarding them. When an expired value is read, only the
+// resource version of the object backing the wrapped Store is fetched, and the
+// cached value is served if the object is unchanged. Otherwise, the value is
+// read again.
+//
+// This is a middle ground between not caching at all, and watching the backing
+// object with an informer. Used with a TTL of zero, every read is revalidated,
+// so changes made by other processes are observed immediately. Revalidation
+// only applies to Stores backed by a single Kubernetes object, such as a
+// ConfigMap or Secret, and has no effect otherwise.
+func WithRevalidation() Option {
+	return func(o *options) {
+		o.revalidate = true
+	}
+}
+
+// WithMaxKeys configures a Store to contain at most the given number of keys.
+// Calling Store.Set with a new key once the limit has been reached returns the
+// ErrorQuotaExceeded sentinel error.
+func WithMaxKeys(max int) Option {
+	return func(o *options) {
+		o.maxKeys = max
+	}
+}
+
+// WithKeyTransform configures a Store to convert every key using the given
+// transform function before it is stored, such as to lowercase keys or to add
+// a tenant prefix.
+//
+// Keys returned by Store.List are converted back using the given inverse
+// function, and any key for which the inverse function returns an empty
+// string is omitted. If the inverse function is nil, keys are returned in
+// their stored form.
+func WithKeyTransform(transform, inverse func(string) string) Option {
+	return func(o *options) {
+		o.keyTransform = transform
+		o.keyInverse = inverse
+	}
+}
+
+// WithValueHooks configures a Store to call the given hooks for every value
+// that is written or read, so that policy such as stripping sensitive fields,
+// injecting defaults, or normalizing timestamps can be enforced in one place.
+//
+// The beforeSet hook is called by Store.Set with the given value, and returns
+// the value that is actually stored. The afterGet hook is called by Store.Get
+// with the value pointer after it has been unmarshalled, and may modify it in
+// place. Either hook may be nil, and an error returned by either hook is
+// returned to the caller. Atomic updates, such as those made by SetNX, call the
+// hooks with values decoded into an interface{} instead.
+func WithValueHooks(beforeSet func(key string, value interface{}) (interface{}, error), afterGet func(key string, value interface{}) error) Option {
+	return func(o *options) {
+		o.beforeSet = beforeSet
+		o.afterGet = afterGet
+	}
+}
+
+// WithConcurrency configures batch operations such as GetMulti, SetMany, and
+// DeleteMany to make up to the given number of concurrent calls, when used
+// with a Store that does not support the operation natively. By default, calls
+// are made sequentially.
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
+// WithUseNumber configures a Store to decode numbers as a json.Number, in
+// place of a float64, when reading values into an interface{}. This preserves
+// the precision of large integers.
+func WithUseNumber() Option {
+	return func(o *options) {
+		o.useNumber = true
+	}
+}
+
+// WithDisallowUnknownFields configures a Store to return an error when reading
+// a value containing an object key which does not match any field in the
+// destination struct.
+func WithDisallowUnknownFields() Option {
+	return func(o *options) {
+		o.disallowUnknownFields = true
+	}
+}
+
+// WithRedaction configures Dump to replace every secret value with a
+// placeholder that indicates only its size.
+func WithRedaction() Option {
+	return func(o *options) {
+		o.redact = true
+	}
+}
+
+// WithHMAC configures a Store to sign every value with an HMAC-SHA256 using the
+// given key, and to verify the signature when reading values. Values whose
+// signatures do not match, such as those modified directly by anyone with
+// write access to the backing resource, result in a TamperError.
+func WithHMAC(key []byte) Option {
+	return func(o *options) {
+		o.hmacKey = key
+	}
+}
+
+// WithAuditWebhook configures a Store to post an AuditRecord, encoded as JSON,
+// to the given endpoint after every change is made, for feeding changes into
+// an external audit pipeline. Records are posted synchronously using the given
+// client, or http.DefaultClient if nil. If a record could not be delivered,
+// an error is returned even though the change was made.
+func WithAuditWebhook(endpoint string, client *http.Client) Option {
+	return func(o *options) {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		o.auditEndpoint = endpoint
+		o.auditClient = client
+	}
+}
+
+// WithDeltaEncoding configures a Store to write only the difference between
+// the previous and new value of a key when calling Store.Set, which greatly
+// reduces the size of each write for large values that change frequently.
+//
+// The difference is written as a JSON merge patch in a separate key, named
+// <key>.delta.<generation>.<sequence>, so keys of that form should be avoided.
+// Values are reconstructed by applying every delta to the latest full
+// snapshot, and a new snapshot is written after the given number of deltas.
+// A snapshot is also written whenever a delta can not represent the change,
+// such as when the value is not a JSON object, or would not be smaller than
+// the value itself. Values that were written before delta encoding was
+// enabled are treated as a snapshot.
+//
+// As the previous value is read before each write, writes are only safe with
+// multiple writers if the Store supports atomic updates, in which case a
+// write is retried if another writer modified the value concurrently.
+// Otherwise, delta encoding is only suitable for a Store with a single writer.
+func WithDeltaEncoding(snapshotEvery int) Option {
+	return func(o *options) {
+		o.snapshotEvery = snapshotEvery
+	}
+}
+
+// WithSortedList configures a Store to always return the keys from Store.List
+// sorted lexicographically. Without this option, the order of keys depends on
+// the backend, and may differ between calls.
+func WithSortedList() Option {
+	return func(o *options) {
+		o.sortedList = true
+	}
+}
+
+// WithBatchBytes configures BulkLoad to limit the combined size of the keys and
+// values in a single batch to the given number of bytes. By default, batches
+// are limited to 512KiB.
+func WithBatchBytes(size int) Option {
+	return func(o *options) {
+		o.batchBytes = size
+	}
+}
+
+// WithRateLimit configures BulkLoad to write at most the given number of
+// batches per second, with bursts of up to the given number of batches, so
+// that loading a large number of entries does not overwhelm the Kubernetes
+// API.
+func WithRateLimit(qps float32, burst int) Option {
+	return func(o *options) {
+		if burst < 1 {
+			burst = 1
+		}
+		o.rateLimit = qps
+		o.rateBurst = burst
+	}
+}
+
+// WithStrictNames configures a Store to reject a name which is not valid for
+// the backing ConfigMap or Secret with an InvalidNameError, rather than
+// normalizing it.
+func WithStrictNames() Option {
+	return func(o *options) {
+		o.strictNames = true
+	}
+}
+
+// ReadConsistency controls the freshness of the values read by a Store.
+type ReadConsistency int
+
+const (
+	// ReadExact reads the latest version of the backing object, which is
+	// served from etcd. This is the default.
+	ReadExact ReadConsistency = iota
+
+	// ReadAny permits reads to be served from the watch cache of the
+	// Kubernetes API server, which may be slightly stale.
+	ReadAny
+)
+
+// WithReadConsistency configures the consistency of reads made by Store.Get,
+// Store.List, and GetMulti against a ConfigMap, Secret, or annotation Store.
+//
+// Using ReadAny greatly reduces the load on etcd for read-mostly stores, in
+// exchange for reads which may not observe the latest writes. Writes are
+// unaffected, and always act on the latest version of the backing object.
+func WithReadConsistency(consistency ReadConsistency) Option {
+	return func(o *options) {
+		o.readConsistency = consistency
+	}
+}
+
+// WithImpersonation configures a ConfigMap, Secret, or annotation Store to
+// impersonate the actor carried by the context of each request, as set with
+// WithActor, so that changes are attributed to the originating request in the
+// Kubernetes audit log, and are subject to the permissions of the actor rather
+// than those of the service account. Requests made with a context that does
+// not carry an actor are made as the service account.
+//
+// The service account must be granted permission to impersonate users.
+func WithImpersonation() Option {
+	return func(o *options) {
+		o.impersonate = true
+	}
+}
+
+// WithCanonicalJSON configures a Store to write every value as canonical JSON,
+// in which the keys of every object are sorted, and there is no insignificant
+// whitespace. Equivalent values are always stored identically, regardless of
+// the order of struct fields, so kubectl diff, GitOps tooling, and the
+// WithDeltaEncoding option see minimal, stable differences between writes.
+func WithCanonicalJSON() Option {
+	return func(o *options) {
+		o.canonicalJSON = true
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// maxFilenameLength is the maximum length of a ConfigMap key.
+const maxFilenameLength = 253
+
+// invalidFilenameChars matches characters which are not permitted in a
+// ConfigMap key.
+var invalidFilenameChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// Projector materializes the contents of a Store into a ConfigMap that is laid
+// out for mounting as a volume, so that other pods can consume the contents as
+// files without using this package.
+type Projector struct {
+	source Store
+	client v1.ConfigMapInterface
+	name   string
+}
+
+// NewProjector returns a Projector that materializes the contents of the given
+// Store into a ConfigMap with the given name.
+//
+// Each key is projected as a file holding its plain value, so values that are
+// JSON strings are written without quotes, and all other values are written
+// as JSON. Keys that are not valid filenames are sanitized, and suffixed with
+// a hash of the original key so that they remain unique.
+//
+// This Projector is intended to be used when running inside of a pod, as it
+// depends on the presence of a service account in order to interact with the
+// Kubernetes API. The projected ConfigMap is not a Store, and is not labeled
+// as being managed by one, as its values are not JSON encoded.
+func NewProjector(source Store, name string) (*Projector, error) {
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Lookup the current pod's namespace.
+	namespace, err := inClusterNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a set of Kubernetes clients.
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Projector{
+		source: source,
+		client: clientSet.CoreV1().ConfigMaps(namespace),
+		name:   name,
+	}, nil
+}
+
+// Sync performs a full projection, replacing the contents of the projected
+// ConfigMap with the current contents of the Store. The ConfigMap is created
+// if it does not exist, and is left untouched if it is already up to date.
+func (p *Projector) Sync(ctx context.Context) error {
+	keys, err := p.source.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	values, err := GetMulti(ctx, p.source, keys)
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string]string, len(values))
+	for key, value := range values {
+		data[projectionFilename(key)] = projectionValue(value)
+	}
+
+	for {
+		// Use the Kuberneties API to get the projected ConfigMap.
+		configMap, err := p.client.Get(ctx, p.name, metav1.GetOptions{})
+		switch {
+		case isResourceMissingError(err):
+			_, err = p.client.Create(ctx, &apiv1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: p.name,
+				},
+				Data: data,
+			}, metav1.CreateOptions{})
+		case err != nil:
+			return err
+		case len(configMap.Data) == 0 && len(data) == 0, reflect.DeepEqual(configMap.Data, data):
+			return nil
+		default:
+			configMap.Data = data
+			_, err = p.client.Update(ctx, configMap, metav1.UpdateOptions{})
+		}
+
+		// Try again if the ConfigMap was concurrently created or modified.
+		if isConflictError(err) {
+			continue
+		}
+		return err
+	}
+}
+
+// Run performs a full projection, and then projects again every time that the
+// Store is changed, until the given context is done. A full projection is also
+// performed at the given interval, in order to recover from any changes that
+// failed to project, or if the Store does not support subscriptions. Errors
+// encountered while projecting are disregarded, as projection will be retried
+// at the next interval.
+func (p *Projector) Run(ctx context.Context, interval time.Duration) {
+	_ = p.Sync(ctx)
+
+	// A nil channel is never ready, so a Store which does not support
+	// subscriptions is only projected at the given interval.
+	events, err := Subscribe(ctx, p.source)
+	if err != nil {
+		events = nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = p.Sync(ctx)
+		case _, ok := <-events:
+			if !ok {
+				// The subscription ended, so rely on projecting at the
+				// given interval.
+				events = nil
+				continue
+			}
+			// The whole ConfigMap is written at once, so every change
+			// results in a full projection.
+			_ = p.Sync(ctx)
+		}
+	}
+}
+
+// projectionFilename returns the name of the file that the given key is
+// projected as. Keys that are already valid filenames are used as-is.
+func projectionFilename(key string) string {
+	// Names beginning with ".." are reserved for the symlinks used by the
+	// kubelet when updating projected volumes.
+	valid := len(key) <= maxFilenameLength &&
+		!invalidFilenameChars.MatchString(key) &&
+		key != "" && key != "." && !strings.HasPrefix(key, "..")
+	if valid {
+		return key
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	suffix := "-" + hex.EncodeToString(sum[:])[:16]
+
+	name := invalidFilenameChars.ReplaceAllString(key, "_")
+	if strings.HasPrefix(name, ".") {
+		name = "_" + name[1:]
+	}
+	if len(name) > maxFilenameLength-len(suffix) {
+		name = name[:maxFilenameLength-len(suffix)]
+	}
+	return name + suffix
+}
+
+// projectionValue returns the plain contents of the file that the given value
+// is projected as.
+func projectionValue(value json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		return text
+	}
+	return string(value)
+}
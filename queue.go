@@ -0,0 +1,190 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// queueState is the contents of the key in which a Queue is stored.
+type queueState struct {
+	Items []queueEntry `json:"items"`
+}
+
+// queueEntry is a single item within a Queue.
+type queueEntry struct {
+	ID           string          `json:"id"`
+	Value        json.RawMessage `json:"value"`
+	Enqueued     time.Time       `json:"enqueued"`
+	Lease        string          `json:"lease,omitempty"`
+	ClaimedUntil time.Time       `json:"claimedUntil,omitempty"`
+}
+
+// QueueItem is an item that has been claimed from a Queue.
+type QueueItem struct {
+	// ID uniquely identifies the item.
+	ID string
+
+	// Value is the JSON encoded value of the item.
+	Value json.RawMessage
+
+	// lease identifies the claim on the item.
+	lease string
+}
+
+// Queue is a work queue built on top of a Store, which allows multiple
+// workers to coordinate through a single key.
+//
+// Items are claimed using a lease, so that an item is returned to the queue if
+// the worker that claimed it fails to complete it before the lease expires.
+// Every operation on a Queue is performed as an atomic update, so the Store
+// must implement the Updater interface.
+type Queue struct {
+	store Store
+	key   string
+}
+
+// NewQueue returns a Queue stored under the given key of the given Store.
+func NewQueue(store Store, key string) *Queue {
+	return &Queue{
+		store: store,
+		key:   key,
+	}
+}
+
+// Enqueue adds the given value to the back of the queue, and returns the ID of
+// the new item.
+func (q *Queue) Enqueue(ctx context.Context, value interface{}) (string, error) {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	err = q.update(ctx, func(state *queueState) error {
+		state.Items = append(state.Items, queueEntry{
+			ID:       id,
+			Value:    data,
+			Enqueued: time.Now().UTC(),
+		})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Claim claims the item nearest the front of the queue that is not already
+// claimed, for the given duration. The item must be completed using
+// Queue.Complete before the claim expires, after which it may be claimed by
+// another worker.
+//
+// If there are no items available to be claimed, the ErrorQueueEmpty sentinel
+// error is returned.
+func (q *Queue) Claim(ctx context.Context, ttl time.Duration) (QueueItem, error) {
+	lease, err := randomID()
+	if err != nil {
+		return QueueItem{}, err
+	}
+
+	var item QueueItem
+	err = q.update(ctx, func(state *queueState) error {
+		now := time.Now()
+		for i, entry := range state.Items {
+			// Skip items with a claim that has yet to expire.
+			if entry.Lease != "" && now.Before(entry.ClaimedUntil) {
+				continue
+			}
+
+			state.Items[i].Lease = lease
+			state.Items[i].ClaimedUntil = now.Add(ttl).UTC()
+			item = QueueItem{ID: entry.ID, Value: entry.Value, lease: lease}
+			return nil
+		}
+		return ErrorQueueEmpty
+	})
+	if err != nil {
+		return QueueItem{}, err
+	}
+
+	return item, nil
+}
+
+// Complete removes the given claimed item from the queue.
+//
+// If the claim on the item has expired, and the item has since been claimed by
+// another worker or completed, the ErrorConflict sentinel error is returned.
+func (q *Queue) Complete(ctx context.Context, item QueueItem) error {
+	return q.update(ctx, func(state *queueState) error {
+		for i, entry := range state.Items {
+			if entry.ID != item.ID {
+				continue
+			}
+			if entry.Lease != item.lease {
+				return fmt.Errorf("%w: item %s has been claimed by another worker", ErrorConflict, item.ID)
+			}
+			state.Items = append(state.Items[:i], state.Items[i+1:]...)
+			return nil
+		}
+		return fmt.Errorf("%w: item %s is no longer in the queue", ErrorConflict, item.ID)
+	})
+}
+
+// Len returns the number of items in the queue, including those that are
+// currently claimed.
+func (q *Queue) Len(ctx context.Context) (int, error) {
+	var state queueState
+	if err := q.store.Get(ctx, q.key, &state); err != nil {
+		if errors.Is(err, ErrorKeyNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return len(state.Items), nil
+}
+
+// update atomically modifies the queue state using the given function. The
+// backing key is removed once the queue is empty.
+func (q *Queue) update(ctx context.Context, fn func(state *queueState) error) error {
+	return Update(ctx, q.store, q.key, func(current json.RawMessage) (json.RawMessage, error) {
+		var state queueState
+		if current != nil {
+			if err := json.Unmarshal(current, &state); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := fn(&state); err != nil {
+			return nil, err
+		}
+
+		if len(state.Items) == 0 {
+			return nil, nil
+		}
+		return json.Marshal(state)
+	})
+}
+
+// randomID returns a random hex encoded identifier.
+func randomID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
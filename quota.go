@@ -0,0 +1,104 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Assert that quotaStore implements the Store interface.
+var _ Store = quotaStore{}
+
+type quotaStore struct {
+	store   Store
+	maxKeys int
+}
+
+// Get reads the named entry from the wrapped Store.
+func (s quotaStore) Get(ctx context.Context, key string, value interface{}) error {
+	return s.store.Get(ctx, key, value)
+}
+
+// Set writes the named entry and value into the wrapped Store.
+//
+// If the key does not already exist, and the wrapped Store already contains
+// the maximum number of keys, the ErrorQuotaExceeded sentinel error is
+// returned. The limit is enforced on a best-effort basis, as keys may be
+// created concurrently by other writers.
+func (s quotaStore) Set(ctx context.Context, key string, value interface{}) error {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Overwriting an existing key never exceeds the quota.
+	for _, existing := range keys {
+		if existing == key {
+			return s.store.Set(ctx, key, value)
+		}
+	}
+
+	if len(keys) >= s.maxKeys {
+		return fmt.Errorf("%w: store already contains %d keys, the maximum allowed", ErrorQuotaExceeded, len(keys))
+	}
+
+	return s.store.Set(ctx, key, value)
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s quotaStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s quotaStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Describe returns a description of the wrapped Store, including the maximum
+// number of keys.
+func (s quotaStore) Describe() Description {
+	description := Describe(s.store)
+	if description.Options == nil {
+		description.Options = make(map[string]string)
+	}
+	description.Options["maxKeys"] = strconv.Itoa(s.maxKeys)
+	return description
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s quotaStore) resourceVersion(ctx context.Context) (string, error) {
+	return resourceVersionOf(ctx, s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s quotaStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// Update atomically modifies the named entry in the wrapped Store.
+//
+// If the update would create the key, and the wrapped Store already contains
+// the maximum number of keys, the ErrorQuotaExceeded sentinel error is
+// returned.
+func (s quotaStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	full := len(keys) >= s.maxKeys
+
+	return Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		result, err := fn(current)
+		if err == nil && full && current == nil && result != nil {
+			return nil, fmt.Errorf("%w: store already contains %d keys, the maximum allowed", ErrorQuotaExceeded, len(keys))
+		}
+		return result, err
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// rateLimiterState is the contents of the key in which a RateLimiter is
+// stored.
+type rateLimiterState struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// RateLimiter is a token bucket rate limiter built on top of a Store, which
+// enforces a single rate limit across all processes that share the Store.
+//
+// Every operation on a RateLimiter is performed as an atomic update, so the
+// Store must implement the Updater interface. As each operation involves a
+// round trip to the Store, a RateLimiter is best suited to limiting relatively
+// infrequent events, such as calls to an external API.
+type RateLimiter struct {
+	store Store
+	key   string
+	rate  float64
+	burst int
+}
+
+// NewRateLimiter returns a RateLimiter stored under the given key of the given
+// Store, which allows events at the given rate per second, with bursts of up
+// to the given number of events. The rate must be positive, and the burst must
+// allow at least one event.
+func NewRateLimiter(store Store, key string, rate float64, burst int) (*RateLimiter, error) {
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return nil, fmt.Errorf("rate of %v events per second is not positive and finite", rate)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("burst of %d events is less than one", burst)
+	}
+
+	return &RateLimiter{
+		store: store,
+		key:   key,
+		rate:  rate,
+		burst: burst,
+	}, nil
+}
+
+// Allow reports whether a single event may happen now, consuming a token if
+// so.
+func (r *RateLimiter) Allow(ctx context.Context) (bool, error) {
+	return r.AllowN(ctx, 1)
+}
+
+// AllowN reports whether the given number of events may happen now, consuming
+// that many tokens if so.
+func (r *RateLimiter) AllowN(ctx context.Context, n int) (bool, error) {
+	wait, err := r.take(ctx, n)
+	return wait == 0, err
+}
+
+// Wait blocks until a single event may happen, consuming a token, or until the
+// given context is done.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	return r.WaitN(ctx, 1)
+}
+
+// WaitN blocks until the given number of events may happen, consuming that
+// many tokens, or until the given context is done.
+func (r *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if n > r.burst {
+		return fmt.Errorf("requested %d events exceeds the burst of %d", n, r.burst)
+	}
+
+	for {
+		wait, err := r.take(ctx, n)
+		if err != nil || wait == 0 {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// take attempts to consume the given number of tokens. If there are not enough
+// tokens available, none are consumed, and the estimated duration until enough
+// tokens are available is returned.
+func (r *RateLimiter) take(ctx context.Context, n int) (time.Duration, error) {
+	var wait time.Duration
+	err := Update(ctx, r.store, r.key, func(current json.RawMessage) (json.RawMessage, error) {
+		now := time.Now()
+
+		// A new bucket starts out full.
+		state := rateLimiterState{Tokens: float64(r.burst), Updated: now}
+		if current != nil {
+			if err := json.Unmarshal(current, &state); err != nil {
+				return nil, err
+			}
+		}
+
+		// Replenish tokens for the time that has elapsed since the bucket was
+		// last updated.
+		if elapsed := now.Sub(state.Updated); elapsed > 0 {
+			state.Tokens = math.Min(float64(r.burst), state.Tokens+elapsed.Seconds()*r.rate)
+		}
+		state.Updated = now.UTC()
+
+		if state.Tokens < float64(n) {
+			wait = time.Duration((float64(n) - state.Tokens) / r.rate * float64(time.Second))
+			if wait <= 0 {
+				wait = time.Nanosecond
+			}
+			// Leave the bucket untouched.
+			return current, nil
+		}
+
+		wait = 0
+		state.Tokens -= float64(n)
+		return json.Marshal(state)
+	})
+
+	return wait, err
+}
@@ -0,0 +1,211 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// initialReadyBackoff is the delay between the first and second readiness
+// checks made by WaitForReady.
+const initialReadyBackoff = 500 * time.Millisecond
+
+// readinessChecker represents a Store that is capable of checking whether it
+// is ready to be used.
+type readinessChecker interface {
+	checkReady(ctx context.Context) error
+}
+
+// WaitForReady blocks until the given Store is ready to be used, so that an
+// application can gate its readiness probe on the availability of the Store,
+// rather than discovering failures on first use.
+//
+// For Stores backed by a Kubernetes resource, the Store is ready once the
+// service account credentials are accepted, the backing namespace exists, and
+// the service account is permitted to make every kind of request that the
+// Store makes. Permissions are checked using dry-run requests, so the backing
+// object is never modified. Other Stores are ready once their keys can be
+// listed.
+//
+// Checks are retried with exponential backoff, up to 30 seconds apart, until
+// the Store is ready, or the given context is done, in which case the error
+// from the latest check is returned. A deadline should be set on the context.
+func WaitForReady(ctx context.Context, store Store) error {
+	backoff := initialReadyBackoff
+	for {
+		err := checkReady(ctx, store)
+		if err == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("store not ready: %w", err)
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// checkReady checks once whether the given Store is ready to be used. If the
+// Store does not implement the readinessChecker interface, its keys are
+// listed instead.
+func checkReady(ctx context.Context, store Store) error {
+	if checker, ok := store.(readinessChecker); ok {
+		return checker.checkReady(ctx)
+	}
+	_, err := store.List(ctx)
+	return err
+}
+
+// ignoreNotFound returns nil if the given error indicates that the targeted
+// resource did not exist, otherwise the error is returned.
+func ignoreNotFound(err error) error {
+	if isResourceMissingError(err) {
+		return nil
+	}
+	return err
+}
+
+// checkReady checks that the backing resource exists, and that its
+// annotations can be read and patched.
+func (c annotationStore) checkReady(ctx context.Context) error {
+	// Use the Kuberneties API to get the backing resource.
+	if _, err := c.client.Get(ctx, c.name, metav1.GetOptions{}); err != nil {
+		return err
+	}
+
+	_, err := c.client.Patch(ctx, c.name, types.MergePatchType, []byte("{}"), metav1.PatchOptions{
+		DryRun: []string{metav1.DryRunAll},
+	})
+	return err
+}
+
+// checkReady checks that the backing namespace exists, and that the backing
+// ConfigMap can be read, created, updated, patched, and deleted.
+func (c configMapStore) checkReady(ctx context.Context) error {
+	// Use the Kuberneties API to get the backing ConfigMap.
+	configMap, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if ignoreNotFound(err) != nil {
+		return err
+	}
+	exists := err == nil
+
+	// Creating the ConfigMap fails if the namespace does not exist.
+	_, err = c.client.Create(ctx, &apiv1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: c.name}}, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	// Updates can only be attempted against an existing ConfigMap.
+	if exists {
+		// A conflict indicates that the request was permitted, but that the
+		// ConfigMap was modified in the interim.
+		_, err = c.client.Update(ctx, configMap, metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}})
+		if err != nil && !isConflictError(err) {
+			return err
+		}
+	}
+
+	// Permissions are checked before the existence of the ConfigMap, so a
+	// not found error indicates that the request was permitted.
+	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, []byte("{}"), metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}})
+	if err := ignoreNotFound(err); err != nil {
+		return err
+	}
+	return ignoreNotFound(c.client.Delete(ctx, c.name, metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}))
+}
+
+// checkReady checks that the backing namespace exists, and that the backing
+// Secret can be read, created, updated, patched, and deleted.
+func (c secretStore) checkReady(ctx context.Context) error {
+	// Use the Kuberneties API to get the backing Secret.
+	secret, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	if ignoreNotFound(err) != nil {
+		return err
+	}
+	exists := err == nil
+
+	// Creating the Secret fails if the namespace does not exist.
+	_, err = c.client.Create(ctx, &apiv1.Secret{ObjectMeta: metav1.ObjectMeta{Name: c.name}}, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	// Updates can only be attempted against an existing Secret.
+	if exists {
+		// A conflict indicates that the request was permitted, but that the
+		// Secret was modified in the interim.
+		_, err = c.client.Update(ctx, secret, metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}})
+		if err != nil && !isConflictError(err) {
+			return err
+		}
+	}
+
+	// Permissions are checked before the existence of the Secret, so a not
+	// found error indicates that the request was permitted.
+	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, []byte("{}"), metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}})
+	if err := ignoreNotFound(err); err != nil {
+		return err
+	}
+	return ignoreNotFound(c.client.Delete(ctx, c.name, metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}))
+}
+
+// checkReady checks whether the wrapped Store is ready to be used.
+func (s hmacStore) checkReady(ctx context.Context) error {
+	return checkReady(ctx, s.store)
+}
+
+// checkReady checks whether the wrapped Store is ready to be used.
+func (s deltaStore) checkReady(ctx context.Context) error {
+	return checkReady(ctx, s.store)
+}
+
+// checkReady checks whether the wrapped Store is ready to be used.
+func (s canonicalStore) checkReady(ctx context.Context) error {
+	return checkReady(ctx, s.store)
+}
+
+// checkReady checks whether the wrapped Store is ready to be used.
+func (s decodeStore) checkReady(ctx context.Context) error {
+	return checkReady(ctx, s.store)
+}
+
+// checkReady checks whether the wrapped Store is ready to be used.
+func (s valueHookStore) checkReady(ctx context.Context) error {
+	return checkReady(ctx, s.store)
+}
+
+// checkReady checks whether the wrapped Store is ready to be used.
+func (s keyTransformStore) checkReady(ctx context.Context) error {
+	return checkReady(ctx, s.store)
+}
+
+// checkReady checks whether the wrapped Store is ready to be used.
+func (s quotaStore) checkReady(ctx context.Context) error {
+	return checkReady(ctx, s.store)
+}
+
+// checkReady checks whether the wrapped Store is ready to be used.
+func (s auditStore) checkReady(ctx context.Context) error {
+	return checkReady(ctx, s.store)
+}
+
+// checkReady checks whether the wrapped Store is ready to be used.
+func (s sortedStore) checkReady(ctx context.Context) error {
+	return checkReady(ctx, s.store)
+}
@@ -0,0 +1,185 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Reaper removes expired entries, as written by a TTLStore, from every
+// ConfigMap and Secret that was created on-demand by a Store. This allows
+// entries to expire even when the application that wrote them is not running.
+type Reaper struct {
+	clientSet kubernetes.Interface
+	namespace string
+
+	mu        sync.Mutex
+	callbacks []func(object ManagedObject, key string)
+}
+
+// NewReaper returns a Reaper for the ConfigMaps and Secrets in the given
+// namespace. An empty namespace reaps objects across all namespaces.
+//
+// This Reaper is intended to be used when running inside of a pod, as it
+// depends on the presence of a service account in order to interact with the
+// Kubernetes API. Reaping across all namespaces requires permission to list
+// and update ConfigMaps and Secrets cluster-wide.
+func NewReaper(namespace string) (*Reaper, error) {
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a set of Kubernetes clients.
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reaper{
+		clientSet: clientSet,
+		namespace: namespace,
+	}, nil
+}
+
+// OnExpire registers a callback that is called with the object and name of
+// every key that is reaped. Callbacks are called synchronously, in the order
+// that they were registered.
+func (r *Reaper) OnExpire(callback func(object ManagedObject, key string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.callbacks = append(r.callbacks, callback)
+}
+
+// Reap scans every managed ConfigMap and Secret once, and removes all expired
+// entries. Entries that were not written by a TTLStore are left untouched.
+// Each entry is re-checked atomically before it is removed, so entries that
+// were concurrently refreshed are not lost.
+func (r *Reaper) Reap(ctx context.Context) error {
+	options := metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(metav1.SetAsLabelSelector(managedLabels())),
+	}
+	now := time.Now()
+
+	// Use the Kuberneties API to list the managed ConfigMaps.
+	configMaps, err := r.clientSet.CoreV1().ConfigMaps(r.namespace).List(ctx, options)
+	if err != nil {
+		return err
+	}
+	for _, configMap := range configMaps.Items {
+		store := configMapStore{
+			client:    r.clientSet.CoreV1().ConfigMaps(configMap.Namespace),
+			namespace: configMap.Namespace,
+			name:      configMap.Name,
+		}
+		object := ManagedObject{Kind: "ConfigMap", Namespace: configMap.Namespace, Name: configMap.Name}
+
+		for key, value := range configMap.Data {
+			if expiredEnvelope([]byte(value), now) {
+				if err := r.reap(ctx, store, object, key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Use the Kuberneties API to list the managed Secrets.
+	secrets, err := r.clientSet.CoreV1().Secrets(r.namespace).List(ctx, options)
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets.Items {
+		store := secretStore{
+			client:    r.clientSet.CoreV1().Secrets(secret.Namespace),
+			namespace: secret.Namespace,
+			name:      secret.Name,
+		}
+		object := ManagedObject{Kind: "Secret", Namespace: secret.Namespace, Name: secret.Name}
+
+		for key, value := range secret.Data {
+			if expiredEnvelope(value, now) {
+				if err := r.reap(ctx, store, object, key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run calls Reaper.Reap at the given interval until the given context is done.
+// Errors encountered while reaping are disregarded, as reaping will be retried
+// at the next interval.
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = r.Reap(ctx)
+		}
+	}
+}
+
+// reap atomically removes the named entry from the given Store if it is still
+// expired, and calls any registered expiry callbacks.
+func (r *Reaper) reap(ctx context.Context, store Store, object ManagedObject, key string) error {
+	var reaped bool
+	err := Update(ctx, store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		// Record the outcome of the latest attempt, as the function is
+		// called again if the entry was concurrently modified.
+		reaped = current != nil && expiredEnvelope(current, time.Now())
+		if !reaped {
+			return current, nil
+		}
+		return nil, nil
+	})
+	if err != nil || !reaped {
+		return err
+	}
+
+	r.mu.Lock()
+	callbacks := append([]func(ManagedObject, string){}, r.callbacks...)
+	r.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(object, key)
+	}
+
+	return nil
+}
+
+// expiredEnvelope returns true if the given data is a ttlEnvelope, as written by
+// a TTLStore, that has expired as of the given time.
+func expiredEnvelope(data []byte, now time.Time) bool {
+	// Only consider values that consist of exactly the envelope fields, so
+	// that unrelated values which happen to contain an "expires" field are
+	// never removed.
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || len(fields) != 2 {
+		return false
+	}
+	if _, found := fields["value"]; !found {
+		return false
+	}
+
+	var envelope ttlEnvelope
+	if err := json.Unmarshal(fields["expires"], &envelope.Expires); err != nil {
+		return false
+	}
+	return envelope.expired(now)
+}
@@ -0,0 +1,146 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Replicator keeps a destination Store in sync with a source Store.
+type Replicator struct {
+	source      Store
+	destination Store
+	prefix      string
+}
+
+// NewReplicator returns a Replicator that copies keys, which begin with the
+// given prefix, from the given source Store into the given destination Store.
+// An empty prefix replicates every key.
+//
+// Replication is one-way, so changes made directly to the destination Store
+// for keys that begin with the prefix are overwritten or removed.
+func NewReplicator(source, destination Store, prefix string) *Replicator {
+	return &Replicator{
+		source:      source,
+		destination: destination,
+		prefix:      prefix,
+	}
+}
+
+// Sync performs a full resync, copying every matching key from the source
+// Store into the destination Store, and removing every matching key from the
+// destination Store that no longer exists in the source Store.
+func (r *Replicator) Sync(ctx context.Context) error {
+	keys, err := r.source.List(ctx)
+	if err != nil {
+		return err
+	}
+	keys = r.filter(keys)
+
+	values, err := GetMulti(ctx, r.source, keys)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range values {
+		if err := r.destination.Set(ctx, key, value); err != nil {
+			return err
+		}
+	}
+
+	existing, err := r.destination.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range r.filter(existing) {
+		if _, found := values[key]; found {
+			continue
+		}
+		if err := r.remove(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run performs a full resync, and then replicates individual changes as they
+// are made to the source Store, until the given context is done. A full resync
+// is also performed at the given interval, in order to recover from any
+// changes that failed to replicate, or if the source Store does not support
+// subscriptions. Errors encountered while replicating are disregarded, as
+// replication will be retried at the next interval.
+func (r *Replicator) Run(ctx context.Context, interval time.Duration) {
+	_ = r.Sync(ctx)
+
+	// A nil channel is never ready, so a source Store which does not
+	// support subscriptions is only resynced at the given interval.
+	events, err := Subscribe(ctx, r.source)
+	if err != nil {
+		events = nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = r.Sync(ctx)
+		case event, ok := <-events:
+			if !ok {
+				// The subscription ended, so rely on resyncing.
+				events = nil
+				continue
+			}
+			if !strings.HasPrefix(event.Key, r.prefix) {
+				continue
+			}
+			_ = r.replicate(ctx, event.Key)
+		}
+	}
+}
+
+// replicate copies the current value of the given key from the source Store
+// into the destination Store, or removes it if it no longer exists.
+func (r *Replicator) replicate(ctx context.Context, key string) error {
+	var value json.RawMessage
+	if err := r.source.Get(ctx, key, &value); err != nil {
+		if errors.Is(err, ErrorKeyNotFound) {
+			return r.remove(ctx, key)
+		}
+		return err
+	}
+
+	return r.destination.Set(ctx, key, value)
+}
+
+// remove deletes the given key from the destination Store, disregarding keys
+// that do not exist.
+func (r *Replicator) remove(ctx context.Context, key string) error {
+	err := r.destination.Delete(ctx, key)
+	if isKeyNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// filter returns only the given keys which begin with the configured prefix.
+func (r *Replicator) filter(keys []string) []string {
+	var filtered []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, r.prefix) {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered
+}
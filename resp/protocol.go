@@ -0,0 +1,157 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxMultibulkLength is the largest number of arguments that will be
+	// read for a single command, which matches the limit used by Redis.
+	maxMultibulkLength = 1024 * 1024
+
+	// maxBulkLength is the largest bulk string that will be read. This is
+	// lower than the limit used by Redis, but is still far larger than any
+	// value that can be stored in a Kubernetes object.
+	maxBulkLength = 16 * 1024 * 1024
+
+	// maxLineLength is the longest line that will be read, including inline
+	// commands, which matches the limit used by Redis.
+	maxLineLength = 64 * 1024
+)
+
+// errProtocol is returned when a client sends a malformed command.
+var errProtocol = errors.New("ERR protocol error")
+
+// readCommand reads a single command from the given reader. Commands are either
+// an array of bulk strings, or an inline command of space separated words as
+// sent by telnet and similar tools.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > maxMultibulkLength {
+		return nil, errProtocol
+	}
+
+	// The count is claimed by the client, so arguments are only allocated as
+	// they are read.
+	var args []string
+	for i := 0; i < count; i++ {
+		arg, err := readBulk(r)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// readBulk reads a single bulk string from the given reader.
+func readBulk(r *bufio.Reader) (string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "$") {
+		return "", errProtocol
+	}
+
+	length, err := strconv.Atoi(line[1:])
+	if err != nil || length < 0 || length > maxBulkLength {
+		return "", errProtocol
+	}
+
+	// Read the string along with its trailing CRLF. The length is claimed by
+	// the client, so the buffer only grows as data is actually read.
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(length)+2); err != nil {
+		if err == io.EOF {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\r\n")) {
+		return "", errProtocol
+	}
+
+	return string(buf.Bytes()[:length]), nil
+}
+
+// readLine reads a single CRLF (or LF) terminated line from the given reader,
+// without the line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLength {
+			return "", errProtocol
+		}
+		line = append(line, chunk...)
+
+		switch err {
+		case nil:
+			return strings.TrimRight(string(line), "\r\n"), nil
+		case bufio.ErrBufferFull:
+			// The line is longer than the buffer, so keep reading.
+			continue
+		case io.EOF:
+			if len(line) > 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		default:
+			return "", err
+		}
+	}
+}
+
+// writeSimple writes the given simple string reply.
+func writeSimple(w *bufio.Writer, message string) {
+	fmt.Fprintf(w, "+%s\r\n", message)
+}
+
+// writeError writes the given error reply.
+func writeError(w *bufio.Writer, message string) {
+	// Error replies can not span multiple lines.
+	message = strings.NewReplacer("\r", " ", "\n", " ").Replace(message)
+	fmt.Fprintf(w, "-%s\r\n", message)
+}
+
+// writeArity writes an error reply for a command given the wrong number of
+// arguments.
+func writeArity(w *bufio.Writer, command string) {
+	writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command)))
+}
+
+// writeInteger writes the given integer reply.
+func writeInteger(w *bufio.Writer, value int64) {
+	fmt.Fprintf(w, ":%d\r\n", value)
+}
+
+// writeBulk writes the given bulk string reply.
+func writeBulk(w *bufio.Writer, value string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+}
+
+// writeNull writes a null bulk string reply.
+func writeNull(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
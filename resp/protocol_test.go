@@ -0,0 +1,237 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/joshdk/kubestore"
+)
+
+func TestReadCommandLimits(t *testing.T) {
+	tests := []struct {
+		title    string
+		input    string
+		expected error
+	}{
+		{
+			title:    "oversized multibulk count",
+			input:    "*1099511627776\r\n",
+			expected: errProtocol,
+		},
+		{
+			title:    "oversized bulk length",
+			input:    "*1\r\n$1099511627776\r\n",
+			expected: errProtocol,
+		},
+		{
+			title:    "oversized inline command",
+			input:    strings.Repeat("A", maxLineLength+1) + "\r\n",
+			expected: errProtocol,
+		},
+		{
+			title:    "truncated bulk data",
+			input:    "*1\r\n$16777216\r\nabc",
+			expected: io.ErrUnexpectedEOF,
+		},
+		{
+			title:    "missing bulk terminator",
+			input:    "*1\r\n$3\r\nabcde",
+			expected: errProtocol,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := readCommand(bufio.NewReader(strings.NewReader(test.input)))
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	args, err := readCommand(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 2 || args[0] != "GET" || args[1] != "key" {
+		t.Fatalf("unexpected arguments %q", args)
+	}
+}
+
+func TestServerBinaryValues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := NewServer(kubestore.NewFileStore(t.TempDir()))
+	client, conn := net.Pipe()
+	defer client.Close()
+	go server.serveConn(ctx, conn)
+
+	// A value that is not valid UTF-8.
+	value := "\xff\xfe\x00binary\x80"
+	reader := bufio.NewReader(client)
+
+	command := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+	if _, err := io.WriteString(client, command); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := readLine(reader); err != nil || line != "+OK" {
+		t.Fatalf("unexpected reply %q: %v", line, err)
+	}
+
+	if _, err := io.WriteString(client, "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := readLine(reader); err != nil || line != "$"+strconv.Itoa(len(value)) {
+		t.Fatalf("unexpected reply %q: %v", line, err)
+	}
+	actual := make([]byte, len(value)+2)
+	if _, err := io.ReadFull(reader, actual); err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != value+"\r\n" {
+		t.Fatalf("expected %q, got %q", value, actual[:len(value)])
+	}
+}
+
+func TestServerStringValues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Strings which happen to be valid base64 are returned unchanged.
+	store := kubestore.NewFileStore(t.TempDir())
+	if err := store.Set(ctx, "key", "abcd"); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(store)
+	client, conn := net.Pipe()
+	defer client.Close()
+	go server.serveConn(ctx, conn)
+	reader := bufio.NewReader(client)
+
+	if _, err := io.WriteString(client, "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := readLine(reader); err != nil || line != "$4" {
+		t.Fatalf("unexpected reply %q: %v", line, err)
+	}
+	if line, err := readLine(reader); err != nil || line != "abcd" {
+		t.Fatalf("unexpected reply %q: %v", line, err)
+	}
+}
+
+func TestServerDeleteCount(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := kubestore.NewFileStore(t.TempDir())
+	if err := store.Set(ctx, "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(store)
+	client, conn := net.Pipe()
+	defer client.Close()
+	go server.serveConn(ctx, conn)
+	reader := bufio.NewReader(client)
+
+	// Only the key that exists is counted.
+	if _, err := io.WriteString(client, "*3\r\n$3\r\nDEL\r\n$3\r\nkey\r\n$7\r\nmissing\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := readLine(reader); err != nil || line != ":1" {
+		t.Fatalf("unexpected reply %q: %v", line, err)
+	}
+
+	if _, err := io.WriteString(client, "*2\r\n$3\r\nDEL\r\n$3\r\nkey\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := readLine(reader); err != nil || line != ":0" {
+		t.Fatalf("unexpected reply %q: %v", line, err)
+	}
+}
+
+func TestServeClosesConnections(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- NewServer(kubestore.NewFileStore(t.TempDir())).Serve(ctx, listener)
+	}()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	reader := bufio.NewReader(client)
+
+	// Wait until the connection is being served.
+	if _, err := io.WriteString(client, "*1\r\n$4\r\nPING\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := readLine(reader); err != nil || line != "+PONG" {
+		t.Fatalf("unexpected reply %q: %v", line, err)
+	}
+
+	// The idle connection is closed once the context is done.
+	cancel()
+	if err := <-served; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Fatalf("expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestServerExpireKeepsJSON(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	directory := t.TempDir()
+	store := kubestore.NewTTLStore(kubestore.NewFileStore(directory), 0)
+	if err := store.Set(ctx, "key", map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(store)
+	client, conn := net.Pipe()
+	defer client.Close()
+	go server.serveConn(ctx, conn)
+	reader := bufio.NewReader(client)
+
+	if _, err := io.WriteString(client, "*3\r\n$6\r\nEXPIRE\r\n$3\r\nkey\r\n$2\r\n60\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := readLine(reader); err != nil || line != ":1" {
+		t.Fatalf("unexpected reply %q: %v", line, err)
+	}
+
+	// The value must still be an object, rather than a string holding its
+	// JSON encoding.
+	var value map[string]int
+	if err := store.Get(ctx, "key", &value); err != nil {
+		t.Fatal(err)
+	}
+	if value["a"] != 1 {
+		t.Fatalf("expected %v, got %v", map[string]int{"a": 1}, value)
+	}
+}
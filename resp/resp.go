@@ -0,0 +1,398 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Package resp provides a server which speaks a subset of the Redis protocol
+// (RESP) backed by a kubestore.Store, so that existing Redis clients can use
+// Kubernetes backed storage.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joshdk/kubestore"
+)
+
+// ttlSetter represents a kubestore.Store that can expire individual keys, such
+// as a kubestore.TTLStore.
+type ttlSetter interface {
+	SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+}
+
+// Server serves the Redis protocol backed by a kubestore.Store.
+//
+// The GET, SET (with the EX and PX options), DEL, KEYS, EXPIRE, PING, and QUIT
+// commands are supported. Values are stored as JSON strings, so that they can
+// be read by other means, unless they are not valid UTF-8, in which case they
+// are stored in a binaryEnvelope so that they survive being encoded as JSON.
+// Values that were stored by other means are returned as-is if they are JSON
+// strings, or as their JSON encoding otherwise.
+//
+// Expiry, by way of SET EX or EXPIRE, is only supported if the Store can
+// expire individual keys, such as a kubestore.TTLStore.
+type Server struct {
+	store kubestore.Store
+}
+
+// NewServer returns a Server backed by the given Store.
+func NewServer(store kubestore.Store) *Server {
+	return &Server{
+		store: store,
+	}
+}
+
+// ListenAndServe listens on the given TCP address, and serves connections
+// until the given context is done.
+func (s *Server) ListenAndServe(ctx context.Context, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve accepts connections on the given listener, and serves each of them in
+// a separate goroutine, until the given context is done. Once the context is
+// done, every open connection is closed, and Serve returns after they have
+// all finished.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	// Close the listener once the context is done, in order to interrupt
+	// accepting connections.
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var conns sync.WaitGroup
+	defer conns.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn reads and executes commands from the given connection until it is
+// closed, the QUIT command is received, or the given context is done.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	// Close the connection once the context is done, in order to interrupt
+	// reading the next command.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if err != io.EOF {
+				writeError(writer, err.Error())
+				writer.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.execute(ctx, writer, args)
+		if err := writer.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// execute runs the given command, and writes its reply. It returns true if the
+// connection should be closed.
+func (s *Server) execute(ctx context.Context, w *bufio.Writer, args []string) bool {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		if len(args) > 1 {
+			writeBulk(w, args[1])
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "QUIT":
+		writeSimple(w, "OK")
+		return true
+	case "GET":
+		if len(args) != 2 {
+			writeArity(w, args[0])
+			break
+		}
+		s.get(ctx, w, args[1])
+	case "SET":
+		if len(args) != 3 && len(args) != 5 {
+			writeArity(w, args[0])
+			break
+		}
+		s.set(ctx, w, args[1], args[2], args[3:])
+	case "DEL":
+		if len(args) < 2 {
+			writeArity(w, args[0])
+			break
+		}
+		s.del(ctx, w, args[1:])
+	case "KEYS":
+		if len(args) != 2 {
+			writeArity(w, args[0])
+			break
+		}
+		s.keys(ctx, w, args[1])
+	case "EXPIRE":
+		if len(args) != 3 {
+			writeArity(w, args[0])
+			break
+		}
+		s.expire(ctx, w, args[1], args[2])
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+// get replies with the value of the given key, or a null reply if it does not
+// exist.
+func (s *Server) get(ctx context.Context, w *bufio.Writer, key string) {
+	value, found, err := s.load(ctx, key)
+	switch {
+	case err != nil:
+		writeError(w, "ERR "+err.Error())
+	case !found:
+		writeNull(w)
+	default:
+		writeBulk(w, value)
+	}
+}
+
+// set stores the given value under the given key, with an optional expiry.
+func (s *Server) set(ctx context.Context, w *bufio.Writer, key, value string, options []string) {
+	var ttl time.Duration
+	if len(options) == 2 {
+		amount, err := strconv.ParseInt(options[1], 10, 64)
+		if err != nil || amount <= 0 {
+			writeError(w, "ERR invalid expire time in 'set' command")
+			return
+		}
+
+		switch strings.ToUpper(options[0]) {
+		case "EX":
+			ttl = time.Duration(amount) * time.Second
+		case "PX":
+			ttl = time.Duration(amount) * time.Millisecond
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+
+	if err := s.write(ctx, key, value, ttl); err != nil {
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+	writeSimple(w, "OK")
+}
+
+// del removes the given keys, and replies with the number that existed.
+func (s *Server) del(ctx context.Context, w *bufio.Writer, keys []string) {
+	var deleted int64
+	for _, key := range keys {
+		existed, err := s.remove(ctx, key)
+		if err != nil {
+			writeError(w, "ERR "+err.Error())
+			return
+		}
+		if existed {
+			deleted++
+		}
+	}
+	writeInteger(w, deleted)
+}
+
+// remove deletes the given key, and returns true if it existed. Most Stores do
+// not report whether a deleted key existed, so the key is removed using
+// kubestore.GetDel when possible, or is otherwise read before being deleted.
+func (s *Server) remove(ctx context.Context, key string) (bool, error) {
+	var data json.RawMessage
+	err := kubestore.GetDel(ctx, s.store, key, &data)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, kubestore.ErrorKeyNotFound):
+		return false, nil
+	case !errors.Is(err, kubestore.ErrorNotSupported):
+		return false, err
+	}
+
+	if err := s.store.Get(ctx, key, &data); err != nil {
+		if errors.Is(err, kubestore.ErrorKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := s.store.Delete(ctx, key); err != nil && !errors.Is(err, kubestore.ErrorKeyNotFound) && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return true, nil
+}
+
+// keys replies with every key that matches the given glob pattern.
+func (s *Server) keys(ctx context.Context, w *bufio.Writer, pattern string) {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+
+	var matched []string
+	for _, key := range keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			matched = append(matched, key)
+		}
+	}
+
+	fmt.Fprintf(w, "*%d\r\n", len(matched))
+	for _, key := range matched {
+		writeBulk(w, key)
+	}
+}
+
+// expire sets an expiry on the given key, and replies with 1 if the key exists
+// or 0 if it does not.
+func (s *Server) expire(ctx context.Context, w *bufio.Writer, key, seconds string) {
+	amount, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		writeError(w, "ERR value is not an integer or out of range")
+		return
+	}
+
+	// The value is re-stored exactly as it was read, so that values which
+	// were stored by other means keep their original JSON encoding.
+	var data json.RawMessage
+	if err := s.store.Get(ctx, key, &data); err != nil {
+		if errors.Is(err, kubestore.ErrorKeyNotFound) {
+			writeInteger(w, 0)
+			return
+		}
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+
+	// A non-positive expiry deletes the key immediately.
+	if amount <= 0 {
+		err = s.store.Delete(ctx, key)
+	} else {
+		err = s.setWithTTL(ctx, key, data, time.Duration(amount)*time.Second)
+	}
+	if err != nil {
+		writeError(w, "ERR "+err.Error())
+		return
+	}
+	writeInteger(w, 1)
+}
+
+// load reads the value of the given key as a string.
+func (s *Server) load(ctx context.Context, key string) (string, bool, error) {
+	var data json.RawMessage
+	if err := s.store.Get(ctx, key, &data); err != nil {
+		if errors.Is(err, kubestore.ErrorKeyNotFound) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	// Values stored as JSON strings are returned as-is, and values stored in
+	// a binaryEnvelope are returned decoded. Any other value is returned as
+	// its JSON encoding.
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		return value, true, nil
+	}
+	if raw, ok := unwrapBinary(data); ok {
+		return string(raw), true, nil
+	}
+	return string(data), true, nil
+}
+
+// binaryEnvelope holds a value that is not valid UTF-8, and which would
+// otherwise be corrupted when encoded as a JSON string.
+type binaryEnvelope struct {
+	Binary []byte `json:"$binary"`
+}
+
+// unwrapBinary returns the value held by the given binaryEnvelope. Returns
+// false if the given data is not exactly a binaryEnvelope.
+func unwrapBinary(data json.RawMessage) ([]byte, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || len(fields) != 1 {
+		return nil, false
+	}
+	if _, found := fields["$binary"]; !found {
+		return nil, false
+	}
+
+	var envelope binaryEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, false
+	}
+	return envelope.Binary, true
+}
+
+// write stores the given value under the given key, expiring after the given
+// duration if it is non-zero.
+func (s *Server) write(ctx context.Context, key, value string, ttl time.Duration) error {
+	// Values that are not valid UTF-8 would be corrupted when encoded as JSON
+	// strings, so are stored in a binaryEnvelope instead.
+	var data interface{} = value
+	if !utf8.ValidString(value) {
+		data = binaryEnvelope{Binary: []byte(value)}
+	}
+	if ttl == 0 {
+		return s.store.Set(ctx, key, data)
+	}
+	return s.setWithTTL(ctx, key, data, ttl)
+}
+
+// setWithTTL stores the given value under the given key, expiring after the
+// given duration.
+func (s *Server) setWithTTL(ctx context.Context, key string, data interface{}, ttl time.Duration) error {
+	setter, ok := s.store.(ttlSetter)
+	if !ok {
+		return fmt.Errorf("%w: store does not support expiry", kubestore.ErrorNotSupported)
+	}
+	return setter.SetWithTTL(ctx, key, data, ttl)
+}
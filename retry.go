@@ -0,0 +1,206 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// maxRetryBackoff is the longest delay between attempts made by a RetryStore,
+// unless the API server requests a longer delay.
+const maxRetryBackoff = 30 * time.Second
+
+// RetryStats holds counters describing the retries made by a RetryStore,
+// since it was created.
+type RetryStats struct {
+	// Retries is the number of times that an operation was retried.
+	Retries uint64
+
+	// Throttled is the number of failed attempts that were rejected because
+	// the client was being throttled by the API server.
+	Throttled uint64
+
+	// Delay is the total time spent waiting between attempts.
+	Delay time.Duration
+}
+
+// Assert that RetryStore implements the Store interface.
+var _ Store = (*RetryStore)(nil)
+
+// RetryStore is a Store that wraps another Store, and retries operations that
+// fail because the Kubernetes API server was unavailable, overloaded, or
+// throttling the client.
+type RetryStore struct {
+	store    Store
+	attempts int
+	backoff  time.Duration
+
+	mu    sync.Mutex
+	stats RetryStats
+}
+
+// NewRetryStore returns a RetryStore that wraps the given Store, and makes up
+// to the given number of attempts at each operation.
+//
+// The delay between attempts starts at the given backoff, and doubles after
+// each attempt, with jitter, up to a maximum of 30 seconds. When the API
+// server rejects a request with a Retry-After header, such as when throttling
+// the client with a 429 response under API Priority and Fairness, the
+// requested delay is used if it is longer. This allows bulk operations to back
+// off gracefully, rather than amplifying an overload.
+//
+// Errors that do not indicate that the API server was unavailable, such as
+// ErrorKeyNotFound, are returned immediately.
+func NewRetryStore(store Store, attempts int, backoff time.Duration) *RetryStore {
+	return &RetryStore{
+		store:    store,
+		attempts: attempts,
+		backoff:  backoff,
+	}
+}
+
+// Get reads the named entry from the wrapped Store.
+func (s *RetryStore) Get(ctx context.Context, key string, value interface{}) error {
+	return s.retry(ctx, func() error {
+		return s.store.Get(ctx, key, value)
+	})
+}
+
+// Set writes the named entry and value into the wrapped Store.
+func (s *RetryStore) Set(ctx context.Context, key string, value interface{}) error {
+	return s.retry(ctx, func() error {
+		return s.store.Set(ctx, key, value)
+	})
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s *RetryStore) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := s.retry(ctx, func() error {
+		var err error
+		keys, err = s.store.List(ctx)
+		return err
+	})
+	return keys, err
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s *RetryStore) Delete(ctx context.Context, key string) error {
+	return s.retry(ctx, func() error {
+		return s.store.Delete(ctx, key)
+	})
+}
+
+// GetMulti reads the given keys from the wrapped Store.
+func (s *RetryStore) GetMulti(ctx context.Context, keys []string) (map[string]json.RawMessage, error) {
+	var values map[string]json.RawMessage
+	err := s.retry(ctx, func() error {
+		var err error
+		values, err = GetMulti(ctx, s.store, keys)
+		return err
+	})
+	return values, err
+}
+
+// SetMany writes the given entries and values into the wrapped Store.
+func (s *RetryStore) SetMany(ctx context.Context, values map[string]interface{}) error {
+	return s.retry(ctx, func() error {
+		return SetMany(ctx, s.store, values)
+	})
+}
+
+// DeleteMany removes the given entries from the wrapped Store.
+func (s *RetryStore) DeleteMany(ctx context.Context, keys []string) error {
+	return s.retry(ctx, func() error {
+		return DeleteMany(ctx, s.store, keys)
+	})
+}
+
+// Describe returns a description of the wrapped Store.
+func (s *RetryStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s *RetryStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	var events <-chan Event
+	err := s.retry(ctx, func() error {
+		var err error
+		events, err = Subscribe(ctx, s.store)
+		return err
+	})
+	return events, err
+}
+
+// Update atomically modifies the named entry in the wrapped Store. The given
+// function may be called again if the update is retried.
+func (s *RetryStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return s.retry(ctx, func() error {
+		return Update(ctx, s.store, key, fn)
+	})
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s *RetryStore) resourceVersion(ctx context.Context) (string, error) {
+	var version string
+	err := s.retry(ctx, func() error {
+		var err error
+		version, err = resourceVersionOf(ctx, s.store)
+		return err
+	})
+	return version, err
+}
+
+// Stats returns counters describing the retries made so far.
+func (s *RetryStore) Stats() RetryStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// retry calls the given function until it succeeds, fails with an error that
+// does not indicate that the API server was unavailable, or the configured
+// number of attempts have been made.
+func (s *RetryStore) retry(ctx context.Context, fn func() error) error {
+	backoff := s.backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= s.attempts || !isUnavailableError(err) {
+			return err
+		}
+
+		// Add up to 50% jitter, so that many clients which were throttled at
+		// once do not all retry at once.
+		delay := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+		if requested, ok := retryAfter(err); ok && requested > delay {
+			delay = requested
+		}
+
+		s.mu.Lock()
+		s.stats.Retries++
+		if isThrottledError(err) {
+			s.stats.Throttled++
+		}
+		s.stats.Delay += delay
+		s.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
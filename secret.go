@@ -13,6 +13,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 )
 
@@ -25,8 +26,17 @@ type secretPatch struct {
 var _ Store = secretStore{}
 
 type secretStore struct {
-	client v1.SecretInterface
-	name   string
+	client    v1.SecretInterface
+	metadata  metadata.ResourceInterface
+	namespace string
+	name      string
+
+	// getOptions are the options used when reading the backing Secret.
+	getOptions metav1.GetOptions
+
+	// original is the name that the Store was constructed with, if it was
+	// normalized in order to be a valid Secret name.
+	original string
 }
 
 // NewSecretStore returns a Store backed by a Secret with the given name.
@@ -39,7 +49,12 @@ type secretStore struct {
 // Secret as it will be created on-demand when calling Store.Set and
 // automatically deleted when calling Store.Delete (in the event that it is
 // empty).
-func NewSecretStore(name string) (Store, error) {
+//
+// A name which is not a valid Secret name, such as one that is too long or
+// contains uppercase characters, is normalized and suffixed with a hash, and
+// the original name is preserved in the kubestore/name annotation. Use the
+// WithStrictNames option to return an InvalidNameError instead.
+func NewSecretStore(name string, opts ...Option) (Store, error) {
 	// Lookup the current pod's service account details.
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -52,6 +67,15 @@ func NewSecretStore(name string) (Store, error) {
 		return nil, err
 	}
 
+	return newSecretStore(config, namespace, name, opts)
+}
+
+// newSecretStore returns a Store backed by a Secret with the given name in the
+// given namespace.
+func newSecretStore(config *rest.Config, namespace, name string, opts []Option) (Store, error) {
+	o := newOptions(opts)
+	config = impersonatedConfig(config, o)
+
 	// Create a set of Kubernetes clients.
 	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -61,19 +85,38 @@ func NewSecretStore(name string) (Store, error) {
 	// We're only interested in the Secrets client.
 	client := clientSet.CoreV1().Secrets(namespace)
 
-	return &secretStore{
-		client: client,
-		name:   name,
-	}, nil
+	// Create a metadata client, for cheaply checking the resource version of
+	// the backing Secret.
+	metadataClient, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Normalize the name of the backing Secret, if necessary.
+	name, original, err := resolveName(name, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrap(&secretStore{
+		client:     client,
+		metadata:   metadataClient.Resource(apiv1.SchemeGroupVersion.WithResource("secrets")).Namespace(namespace),
+		namespace:  namespace,
+		name:       name,
+		original:   original,
+		getOptions: o.getOptions(),
+	}, o), nil
 }
 
 // create is a helper for creating the backing Secret.
 func (c secretStore) create(ctx context.Context) error {
 	_, err := c.client.Create(ctx, &apiv1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: c.name,
+			Name:        c.name,
+			Labels:      managedLabels(),
+			Annotations: nameAnnotations(c.original),
 		},
-	}, metav1.CreateOptions{})
+	}, createOptions(ctx))
 	return err
 }
 
@@ -89,7 +132,7 @@ func (c secretStore) delete(ctx context.Context) error {
 // is returned.
 func (c secretStore) Get(ctx context.Context, key string, value interface{}) error {
 	// Use the Kuberneties API to get the backing Secret.
-	secret, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	secret, err := c.client.Get(ctx, c.name, c.getOptions)
 	if err != nil {
 		// If the backing Secret does not exist, then the key also does not
 		// exist, so return the not found sentinel error.
@@ -136,7 +179,7 @@ func (c secretStore) Set(ctx context.Context, key string, value interface{}) err
 	}
 
 	// Use the Kuberneties API to patch the backing Secret.
-	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, metav1.PatchOptions{})
+	_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
 	if err != nil {
 		if isResourceMissingError(err) {
 			// If the backing Secret does not exist, then create it
@@ -159,7 +202,7 @@ func (c secretStore) Set(ctx context.Context, key string, value interface{}) err
 // If the backing Secret does not exist, no keys are returned.
 func (c secretStore) List(ctx context.Context) ([]string, error) {
 	// Use the Kuberneties API to get the backing Secret.
-	secret, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+	secret, err := c.client.Get(ctx, c.name, c.getOptions)
 	if err != nil {
 		// If the backing Secret does not exist, then the keys also no not
 		// exist, so return an empty (nil) slice.
@@ -200,7 +243,7 @@ func (c secretStore) Delete(ctx context.Context, key string) error {
 	}
 
 	// Use the Kuberneties API to patch the backing Secret.
-	secret, err := c.client.Patch(ctx, c.name, types.MergePatchType, payload, metav1.PatchOptions{})
+	secret, err := c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
 	if err != nil {
 		// If the backing Secret does not exist, then the key also does not
 		// exist, so there's nothing else to do.
@@ -0,0 +1,171 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// semaphoreState is the contents of the key in which a Semaphore is stored.
+type semaphoreState struct {
+	// Holders maps the token of each slot holder to the time at which its
+	// slot expires, or the zero time if it never expires.
+	Holders map[string]time.Time `json:"holders"`
+}
+
+// Semaphore is a distributed counting semaphore built on top of a Store, which
+// bounds the number of holders across all processes that share the Store.
+//
+// Every operation on a Semaphore is performed as an atomic update, so the
+// Store must implement the Updater interface.
+type Semaphore struct {
+	store Store
+	key   string
+	size  int
+	ttl   time.Duration
+
+	// PollInterval is the interval at which Semaphore.Acquire retries
+	// acquiring a slot while all slots are held.
+	PollInterval time.Duration
+}
+
+// NewSemaphore returns a Semaphore with the given number of slots, stored under
+// the given key of the given Store.
+//
+// Slots that are not released within the given duration are considered to be
+// abandoned, such as by a process that has crashed, and are made available to
+// other holders. A duration of zero means that slots are held until released.
+func NewSemaphore(store Store, key string, size int, ttl time.Duration) *Semaphore {
+	return &Semaphore{
+		store:        store,
+		key:          key,
+		size:         size,
+		ttl:          ttl,
+		PollInterval: time.Second,
+	}
+}
+
+// TryAcquire attempts to acquire a slot without blocking. If a slot was
+// acquired, a token is returned that must be passed to Semaphore.Release.
+// Otherwise, false is returned.
+func (s *Semaphore) TryAcquire(ctx context.Context) (string, bool, error) {
+	token, err := randomID()
+	if err != nil {
+		return "", false, err
+	}
+
+	var acquired bool
+	err = s.update(ctx, func(state *semaphoreState) {
+		acquired = len(state.Holders) < s.size
+		if !acquired {
+			return
+		}
+
+		var expires time.Time
+		if s.ttl > 0 {
+			expires = time.Now().Add(s.ttl).UTC()
+		}
+		state.Holders[token] = expires
+	})
+	if err != nil || !acquired {
+		return "", false, err
+	}
+
+	return token, true, nil
+}
+
+// Acquire blocks until a slot is acquired, or until the given context is done.
+// A token is returned that must be passed to Semaphore.Release.
+func (s *Semaphore) Acquire(ctx context.Context) (string, error) {
+	for {
+		token, acquired, err := s.TryAcquire(ctx)
+		if err != nil || acquired {
+			return token, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(s.PollInterval):
+		}
+	}
+}
+
+// Release releases the slot identified by the given token.
+//
+// If the slot was not held, such as if it had expired and been made available
+// to another holder, the ErrorConflict sentinel error is returned.
+func (s *Semaphore) Release(ctx context.Context, token string) error {
+	var held bool
+	err := s.update(ctx, func(state *semaphoreState) {
+		_, held = state.Holders[token]
+		delete(state.Holders, token)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !held {
+		return fmt.Errorf("%w: semaphore slot is not held", ErrorConflict)
+	}
+	return nil
+}
+
+// Renew extends the expiry of the slot identified by the given token, so that
+// a holder can keep a slot for longer than the configured duration.
+//
+// If the slot was not held, such as if it had expired and been made available
+// to another holder, the ErrorConflict sentinel error is returned.
+func (s *Semaphore) Renew(ctx context.Context, token string) error {
+	var held bool
+	err := s.update(ctx, func(state *semaphoreState) {
+		if _, held = state.Holders[token]; held && s.ttl > 0 {
+			state.Holders[token] = time.Now().Add(s.ttl).UTC()
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if !held {
+		return fmt.Errorf("%w: semaphore slot is not held", ErrorConflict)
+	}
+	return nil
+}
+
+// update atomically modifies the semaphore state using the given function,
+// after discarding any expired holders. The backing key is removed once there
+// are no holders.
+func (s *Semaphore) update(ctx context.Context, fn func(state *semaphoreState)) error {
+	return Update(ctx, s.store, s.key, func(current json.RawMessage) (json.RawMessage, error) {
+		var state semaphoreState
+		if current != nil {
+			if err := json.Unmarshal(current, &state); err != nil {
+				return nil, err
+			}
+		}
+		if state.Holders == nil {
+			state.Holders = make(map[string]time.Time)
+		}
+
+		// Discard holders whose slots have expired.
+		now := time.Now()
+		for token, expires := range state.Holders {
+			if !expires.IsZero() && !now.Before(expires) {
+				delete(state.Holders, token)
+			}
+		}
+
+		fn(&state)
+
+		if len(state.Holders) == 0 {
+			return nil, nil
+		}
+		return json.Marshal(state)
+	})
+}
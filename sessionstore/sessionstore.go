@@ -0,0 +1,195 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+// Package sessionstore provides a gorilla/sessions Store backed by a
+// kubestore.Store, so that HTTP sessions can be shared across replicas.
+package sessionstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/joshdk/kubestore"
+)
+
+// keyPrefix is prepended to session IDs to form the key for each session.
+const keyPrefix = "session-"
+
+// ttlSetter represents a kubestore.Store that can expire individual keys, such
+// as a kubestore.TTLStore.
+type ttlSetter interface {
+	SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+}
+
+// Assert that Store implements the sessions.Store interface.
+var _ sessions.Store = (*Store)(nil)
+
+// Store is a sessions.Store that keeps session values in a kubestore.Store,
+// while only the session ID is kept in the client cookie.
+//
+// Session values are serialized and authenticated (and optionally encrypted)
+// using the given key pairs, so they are protected even when stored in a
+// ConfigMap. Backing the Store with a kubestore.Secret store is nonetheless
+// recommended. If the Store is a kubestore.TTLStore, sessions expire according
+// to their MaxAge.
+type Store struct {
+	store kubestore.Store
+
+	// Codecs are used to encode and decode both the session ID cookie and
+	// the stored session values.
+	Codecs []securecookie.Codec
+
+	// Options are the default options for new sessions.
+	Options *sessions.Options
+}
+
+// New returns a Store backed by the given kubestore.Store.
+//
+// Key pairs are given as in sessions.NewCookieStore, where each pair is an
+// authentication key followed by an optional encryption key.
+func New(store kubestore.Store, keyPairs ...[]byte) *Store {
+	s := &Store{
+		store:  store,
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+
+	s.MaxAge(s.Options.MaxAge)
+	return s
+}
+
+// MaxAge sets the maximum age, in seconds, of new sessions, and of the session
+// ID cookies and session values that are decoded by each of the codecs. Use
+// this instead of setting Options.MaxAge directly, as otherwise sessions which
+// are older than the default codec maximum age of 30 days can not be decoded.
+func (s *Store) MaxAge(age int) {
+	s.Options.MaxAge = age
+
+	// Set the maximum age of each codec, as is done by
+	// sessions.CookieStore.MaxAge.
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+// Get returns a cached session for the given request, or loads it from the
+// backing Store.
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns a session for the given name, loading its values from the
+// backing Store if the request carries a valid session cookie.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		// There is no session cookie, so this is a new session.
+		return session, nil
+	}
+
+	if err := securecookie.DecodeMulti(name, cookie.Value, &session.ID, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	if err := s.load(r.Context(), session); err != nil {
+		// The session has expired or was deleted, so start a new session,
+		// with a new ID.
+		if isNotFound(err) {
+			session.ID = ""
+			return session, nil
+		}
+		return session, err
+	}
+
+	session.IsNew = false
+	return session, nil
+}
+
+// Save persists the session values to the backing Store, and writes the
+// session ID cookie to the response. Sessions with a negative MaxAge are
+// deleted.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	ctx := r.Context()
+
+	// Delete the session if it has expired.
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.store.Delete(ctx, keyPrefix+session.ID); err != nil && !isNotFound(err) {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	// Generate an ID for new sessions.
+	if session.ID == "" {
+		id := make([]byte, 32)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(id), "=")
+	}
+
+	if err := s.save(ctx, session); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// load reads the session values from the backing Store.
+func (s *Store) load(ctx context.Context, session *sessions.Session) error {
+	var encoded string
+	if err := s.store.Get(ctx, keyPrefix+session.ID, &encoded); err != nil {
+		return err
+	}
+	return securecookie.DecodeMulti(session.Name(), encoded, &session.Values, s.Codecs...)
+}
+
+// save writes the session values to the backing Store, expiring them after
+// the session MaxAge if supported.
+func (s *Store) save(ctx context.Context, session *sessions.Session) error {
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	if setter, ok := s.store.(ttlSetter); ok && session.Options.MaxAge > 0 {
+		ttl := time.Duration(session.Options.MaxAge) * time.Second
+		return setter.SetWithTTL(ctx, keyPrefix+session.ID, encoded, ttl)
+	}
+
+	return s.store.Set(ctx, keyPrefix+session.ID, encoded)
+}
+
+// isNotFound returns true if the given error indicates that a key did not
+// exist.
+func isNotFound(err error) bool {
+	return errors.Is(err, kubestore.ErrorKeyNotFound) || errors.Is(err, os.ErrNotExist)
+}
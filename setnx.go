@@ -0,0 +1,47 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// SetNX writes the named entry and value into the given Store only if the
+// entry does not already exist, and returns true if it was written. This is
+// the building block for "first writer wins" claims, as exactly one of many
+// concurrent callers succeeds.
+//
+// The entry is created atomically using the preconditions of the Updater
+// interface, rather than being read and written separately. If the Store does
+// not implement the Updater interface, an error matching the
+// ErrorNotSupported sentinel error is returned.
+//
+// A caller that loses makes no write at all, as the existing contents are
+// returned unchanged to Update. Wrappers such as TTLStore and EncryptedStore
+// therefore neither extend the expiry of, nor re-encrypt, the existing entry.
+func SetNX(ctx context.Context, store Store, key string, value interface{}) (bool, error) {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	var created bool
+	err = Update(ctx, store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		// Record the outcome of the latest attempt, as the function is called
+		// again if the entry was concurrently modified.
+		created = current == nil
+		if !created {
+			return current, nil
+		}
+		return data, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return created, nil
+}
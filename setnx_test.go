@@ -0,0 +1,51 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetNXLoserMakesNoWrite(t *testing.T) {
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	identity := func(key string, value interface{}) (interface{}, error) {
+		return value, nil
+	}
+	encrypted, err := NewEncryptedStore(NewFileStore(directory, WithValueHooks(identity, nil)), make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewTTLStore(encrypted, time.Hour)
+
+	if created, err := SetNX(ctx, store, "key", "first"); err != nil || !created {
+		t.Fatalf("expected the entry to be created: %v", err)
+	}
+
+	filename := filepath.Join(directory, "key")
+	before, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created, err := SetNX(ctx, store, "key", "second"); err != nil || created {
+		t.Fatalf("expected the existing entry to be kept: %v", err)
+	}
+
+	// The backing file must be byte for byte identical, since a fresh nonce
+	// or expiry would have changed it.
+	after, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Fatalf("expected no write, but %s became %s", before, after)
+	}
+}
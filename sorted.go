@@ -0,0 +1,76 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+// ListSorted returns a list of all keys in the given Store, sorted
+// lexicographically, regardless of the order in which the Store returns them.
+func ListSorted(ctx context.Context, store Store) ([]string, error) {
+	keys, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Assert that sortedStore implements the Store interface.
+var _ Store = sortedStore{}
+
+type sortedStore struct {
+	store Store
+}
+
+// Get reads the named entry from the wrapped Store.
+func (s sortedStore) Get(ctx context.Context, key string, value interface{}) error {
+	return s.store.Get(ctx, key, value)
+}
+
+// Set writes the named entry and value into the wrapped Store.
+func (s sortedStore) Set(ctx context.Context, key string, value interface{}) error {
+	return s.store.Set(ctx, key, value)
+}
+
+// List returns a list of all keys in the wrapped Store, sorted
+// lexicographically.
+func (s sortedStore) List(ctx context.Context) ([]string, error) {
+	return ListSorted(ctx, s.store)
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s sortedStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// GetMulti reads the given keys from the wrapped Store.
+func (s sortedStore) GetMulti(ctx context.Context, keys []string) (map[string]json.RawMessage, error) {
+	return GetMulti(ctx, s.store, keys)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s sortedStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s sortedStore) resourceVersion(ctx context.Context) (string, error) {
+	return resourceVersionOf(ctx, s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s sortedStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// Update atomically modifies the named entry in the wrapped Store.
+func (s sortedStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return Update(ctx, s.store, key, fn)
+}
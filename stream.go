@@ -0,0 +1,205 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// streamChunkSeparator separates the name of a key from the generation and
+// index of each of its chunks, as in "<key>.chunk.<generation>.<index>".
+const streamChunkSeparator = ".chunk."
+
+// streamManifest is stored under the key of a streamed value, and describes
+// the chunks that hold its contents.
+type streamManifest struct {
+	Generation string `json:"generation"`
+	Chunks     int    `json:"chunks"`
+	Size       int64  `json:"size"`
+}
+
+// chunkKey returns the key for the given chunk of this manifest.
+func (m streamManifest) chunkKey(key string, index int) string {
+	return fmt.Sprintf("%s%s%s.%d", key, streamChunkSeparator, m.Generation, index)
+}
+
+// IsStreamChunk returns true if the given key holds a chunk of a value stored
+// using SetReader, so that chunks can be excluded from the keys returned by
+// Store.List.
+func IsStreamChunk(key string) bool {
+	index := strings.LastIndex(key, streamChunkSeparator)
+	if index < 0 {
+		return false
+	}
+
+	parts := strings.Split(key[index+len(streamChunkSeparator):], ".")
+	if len(parts) != 2 || parts[0] == "" {
+		return false
+	}
+	_, err := strconv.Atoi(parts[1])
+	return err == nil
+}
+
+// SetReader stores the contents of the given reader under the given key,
+// split into chunks of the given size, so that values larger than a single
+// backing object can be stored without buffering the entire value in memory.
+//
+// Each chunk is stored under its own key, named <key>.chunk.<generation>.<index>,
+// which is returned by Store.List and can be identified using IsStreamChunk.
+// A manifest describing the chunks is stored under the given key once all
+// chunks have been written, so readers never observe a partially written
+// value. Chunks of the value that the manifest replaced are removed
+// afterwards, as are the chunks written so far if storing the value fails.
+//
+// Chunks are stored as byte slices, which are base64 encoded, increasing
+// their size by a third. For a Store backed by a single Kubernetes object,
+// such as a ConfigMap or Secret, every chunk also counts towards the size
+// limit of that one object, so streamed values are limited to roughly 750KiB
+// in total. A Store backed by a directory, such as a file Store, has no such
+// limit.
+func SetReader(ctx context.Context, store Store, key string, r io.Reader, chunkSize int) (err error) {
+	if chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+
+	// Chunks are written under a new generation, so that they do not
+	// overwrite the chunks of the previously stored value.
+	generation, err := randomID()
+	if err != nil {
+		return err
+	}
+	manifest := streamManifest{Generation: generation}
+
+	// Remove any chunks that were written if the value could not be stored.
+	var published bool
+	defer func() {
+		if err != nil && !published {
+			deleteChunks(ctx, store, key, manifest)
+		}
+	}()
+
+	buffer := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buffer)
+		if n > 0 {
+			if err := store.Set(ctx, manifest.chunkKey(key, manifest.Chunks), buffer[:n]); err != nil {
+				return err
+			}
+			manifest.Chunks++
+			manifest.Size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	// Publish the new value by writing its manifest, while recording the
+	// manifest that it replaced, which may have been written concurrently.
+	var previous json.RawMessage
+	err = updateOrSet(ctx, store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		previous = current
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	published = true
+
+	// Remove the chunks of the previously stored value.
+	return deleteReplaced(ctx, store, key, previous)
+}
+
+// GetWriter writes the contents of the value stored under the given key using
+// SetReader to the given writer, one chunk at a time. Returns the number of
+// bytes written.
+//
+// If the key does not exist, the ErrorKeyNotFound sentinel error is returned.
+// If the value is replaced while it is being written, an error matching the
+// ErrorConflict sentinel error is returned, after some of the previous value
+// has already been written.
+func GetWriter(ctx context.Context, store Store, key string, w io.Writer) (int64, error) {
+	var manifest streamManifest
+	if err := store.Get(ctx, key, &manifest); err != nil {
+		return 0, err
+	}
+
+	var written int64
+	for i := 0; i < manifest.Chunks; i++ {
+		var chunk []byte
+		if err := store.Get(ctx, manifest.chunkKey(key, i), &chunk); err != nil {
+			// The chunk may have been removed after the value was replaced.
+			var current streamManifest
+			if errors.Is(err, ErrorKeyNotFound) && store.Get(ctx, key, &current) == nil && current.Generation != manifest.Generation {
+				return written, fmt.Errorf("%w: value %s was replaced while being read", ErrorConflict, key)
+			}
+			return written, err
+		}
+
+		n, err := w.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// DeleteStream removes the value stored under the given key using SetReader,
+// including all of its chunks.
+func DeleteStream(ctx context.Context, store Store, key string) error {
+	// Remove the manifest, while recording the manifest that was removed.
+	var previous json.RawMessage
+	err := updateOrSet(ctx, store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		previous = current
+		return nil, nil
+	})
+	if err != nil {
+		if isKeyNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	return deleteReplaced(ctx, store, key, previous)
+}
+
+// deleteReplaced removes all chunks described by the given manifest, which
+// was replaced or removed. A nil manifest describes no chunks.
+func deleteReplaced(ctx context.Context, store Store, key string, data json.RawMessage) error {
+	if data == nil {
+		return nil
+	}
+
+	var manifest streamManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	return deleteChunks(ctx, store, key, manifest)
+}
+
+// deleteChunks removes all chunks described by the given manifest.
+func deleteChunks(ctx context.Context, store Store, key string, manifest streamManifest) error {
+	for i := 0; i < manifest.Chunks; i++ {
+		if err := store.Delete(ctx, manifest.chunkKey(key, i)); err != nil && !isKeyNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Swap writes the named entry and value into the given Store, and stores the
+// previous contents of the entry into the given old value pointer, as a single
+// operation. It returns true if the entry previously existed, otherwise the
+// entry is created and the old value pointer is left untouched. This allows
+// handover logic to be implemented without racing between reading and writing.
+//
+// The entry is replaced atomically using the preconditions of the Updater
+// interface, rather than being read and written separately. If the Store does
+// not implement the Updater interface, an error matching the
+// ErrorNotSupported sentinel error is returned.
+func Swap(ctx context.Context, store Store, key string, value, old interface{}) (bool, error) {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	var previous json.RawMessage
+	err = Update(ctx, store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		// Record the value from the latest attempt, as the function is called
+		// again if the entry was concurrently modified.
+		previous = current
+		return data, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if previous == nil {
+		return false, nil
+	}
+
+	// Unmarshal the previous JSON data into the given old value pointer.
+	return true, json.Unmarshal(previous, old)
+}
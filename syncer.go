@@ -0,0 +1,255 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// SyncConflict describes a key that was changed concurrently in both of the
+// stores kept in sync by a Syncer.
+type SyncConflict struct {
+	// Key is the name of the conflicting key.
+	Key string
+
+	// Left is the current value in the left Store, or nil if the key does not
+	// exist there.
+	Left json.RawMessage
+
+	// Right is the current value in the right Store, or nil if the key does
+	// not exist there.
+	Right json.RawMessage
+}
+
+// Syncer keeps two stores in sync with each other, in both directions.
+type Syncer struct {
+	left  Store
+	right Store
+
+	// syncMu serializes syncing keys between the stores.
+	syncMu sync.Mutex
+
+	mu        sync.Mutex
+	versions  map[string]string
+	callbacks []func(conflict SyncConflict)
+}
+
+// NewSyncer returns a Syncer that keeps the given left and right stores in
+// sync with each other.
+//
+// The Syncer tracks the version of each key as of when it was last synced.
+// A key that was changed in only one of the stores since then has the change
+// copied to the other. A key that was changed in both of the stores is a
+// conflict, and is left untouched in both until resolved, for instance by
+// setting the same value in both stores.
+//
+// Versions are tracked in memory, so every key that differs between the
+// stores is considered to be a conflict when first synced, unless it is
+// missing from one of them.
+func NewSyncer(left, right Store) *Syncer {
+	return &Syncer{
+		left:     left,
+		right:    right,
+		versions: make(map[string]string),
+	}
+}
+
+// OnConflict registers a callback that is called with every conflict that is
+// detected while syncing. Callbacks are called synchronously, in the order
+// that they were registered.
+func (s *Syncer) OnConflict(callback func(conflict SyncConflict)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+}
+
+// Sync performs a full sync of every key in either Store.
+func (s *Syncer) Sync(ctx context.Context) error {
+	leftKeys, err := s.left.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	rightKeys, err := s.right.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Sync every key in either Store, as well as every key that was
+	// previously synced, in order to detect deletions from both stores.
+	keys := make(map[string]bool, len(leftKeys))
+	for _, key := range leftKeys {
+		keys[key] = true
+	}
+	for _, key := range rightKeys {
+		keys[key] = true
+	}
+	s.mu.Lock()
+	for key := range s.versions {
+		keys[key] = true
+	}
+	s.mu.Unlock()
+
+	for key := range keys {
+		if err := s.syncKey(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run performs a full sync, and then syncs individual keys as they are
+// changed in either Store, until the given context is done. A full sync is
+// also performed at the given interval, in order to recover from any changes
+// that failed to sync, or if either Store does not support subscriptions.
+// Errors encountered while syncing are disregarded, as syncing will be retried
+// at the next interval.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	_ = s.Sync(ctx)
+
+	// A nil channel is never ready, so a Store which does not support
+	// subscriptions is only synced at the given interval.
+	leftEvents, err := Subscribe(ctx, s.left)
+	if err != nil {
+		leftEvents = nil
+	}
+	rightEvents, err := Subscribe(ctx, s.right)
+	if err != nil {
+		rightEvents = nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.Sync(ctx)
+		case event, ok := <-leftEvents:
+			if !ok {
+				leftEvents = nil
+				continue
+			}
+			_ = s.syncKey(ctx, event.Key)
+		case event, ok := <-rightEvents:
+			if !ok {
+				rightEvents = nil
+				continue
+			}
+			_ = s.syncKey(ctx, event.Key)
+		}
+	}
+}
+
+// syncKey compares the current value of the given key in both stores against
+// the version as of when it was last synced, and copies the change from
+// whichever Store changed it into the other.
+func (s *Syncer) syncKey(ctx context.Context, key string) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	left, err := syncValue(ctx, s.left, key)
+	if err != nil {
+		return err
+	}
+
+	right, err := syncValue(ctx, s.right, key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	base, synced := s.versions[key]
+	s.mu.Unlock()
+
+	leftVersion := syncVersion(left)
+	rightVersion := syncVersion(right)
+
+	var version string
+	switch {
+	case leftVersion == rightVersion:
+		// Both stores already agree.
+		version = leftVersion
+	case !synced && right == nil, synced && rightVersion == base:
+		// Only the left Store changed.
+		if err := syncCopy(ctx, s.right, key, left); err != nil {
+			return err
+		}
+		version = leftVersion
+	case !synced && left == nil, synced && leftVersion == base:
+		// Only the right Store changed.
+		if err := syncCopy(ctx, s.left, key, right); err != nil {
+			return err
+		}
+		version = rightVersion
+	default:
+		// Both stores changed, so surface the conflict without changing
+		// either Store.
+		s.mu.Lock()
+		callbacks := s.callbacks
+		s.mu.Unlock()
+		for _, callback := range callbacks {
+			callback(SyncConflict{Key: key, Left: left, Right: right})
+		}
+		return nil
+	}
+
+	// Both stores now agree, so record the version of the key.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if version == "" {
+		delete(s.versions, key)
+	} else {
+		s.versions[key] = version
+	}
+
+	return nil
+}
+
+// syncValue reads the given key from the given Store, returning nil if the key
+// does not exist.
+func syncValue(ctx context.Context, store Store, key string) (json.RawMessage, error) {
+	var value json.RawMessage
+	if err := store.Get(ctx, key, &value); err != nil {
+		if errors.Is(err, ErrorKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return value, nil
+}
+
+// syncVersion returns the version of the given value, which is empty for a key
+// that does not exist.
+func syncVersion(value json.RawMessage) string {
+	if value == nil {
+		return ""
+	}
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+// syncCopy sets the given key in the given Store to the given value, or
+// deletes it if the value is nil.
+func syncCopy(ctx context.Context, store Store, key string, value json.RawMessage) error {
+	if value != nil {
+		return store.Set(ctx, key, value)
+	}
+
+	err := store.Delete(ctx, key)
+	if isKeyNotFound(err) {
+		return nil
+	}
+	return err
+}
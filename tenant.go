@@ -0,0 +1,131 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tenantSeparator separates the tenant ID from the key, in the keys stored in
+// the shared Store.
+const tenantSeparator = "."
+
+// tenantID matches valid tenant IDs, which must not contain the separator, and
+// must be valid within ConfigMap and Secret keys.
+var tenantID = regexp.MustCompile(`^[-_a-zA-Z0-9]+$`)
+
+// TenantConfig configures the isolation of a single tenant.
+type TenantConfig struct {
+	// MaxKeys is the maximum number of keys that the tenant may store, as
+	// with the WithMaxKeys option. A value of zero disables the limit.
+	MaxKeys int
+
+	// EncryptionKey is the key with which the tenant's values are encrypted,
+	// as with NewEncryptedStore. A nil key disables encryption.
+	EncryptionKey []byte
+}
+
+// Tenancy divides a single shared Store between multiple tenants, such as
+// when one backing object is shared by many customers of a platform.
+type Tenancy struct {
+	store Store
+
+	mu      sync.Mutex
+	configs map[string]TenantConfig
+}
+
+// NewTenancy returns a Tenancy that divides the given Store between tenants.
+//
+// Each tenant's keys are stored in the shared Store prefixed with the ID of
+// the tenant, as in "<tenant>.<key>", so tenant IDs may only contain letters,
+// digits, dashes, and underscores.
+func NewTenancy(store Store) *Tenancy {
+	return &Tenancy{
+		store:   store,
+		configs: make(map[string]TenantConfig),
+	}
+}
+
+// Configure sets the isolation configuration for the given tenant, which
+// applies to Stores subsequently returned by Tenancy.ForTenant.
+func (t *Tenancy) Configure(id string, config TenantConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.configs[id] = config
+}
+
+// ForTenant returns a Store for the given tenant. Keys are transparently
+// prefixed with the ID of the tenant, so the tenant can only read, list, and
+// modify its own keys. The tenant's quota and encryption key are enforced, if
+// configured.
+func (t *Tenancy) ForTenant(id string) (Store, error) {
+	if !tenantID.MatchString(id) {
+		return nil, fmt.Errorf("invalid tenant ID %q", id)
+	}
+
+	t.mu.Lock()
+	config := t.configs[id]
+	t.mu.Unlock()
+
+	store := t.store
+	if config.EncryptionKey != nil {
+		// Keys are prefixed before values are encrypted, since the key
+		// transform wraps the encrypted Store, so that each value is bound to
+		// the tenant as well as to its key.
+		encrypted, err := NewEncryptedStore(store, config.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		store = encrypted
+	}
+
+	prefix := id + tenantSeparator
+	opts := []Option{
+		WithKeyTransform(
+			func(key string) string {
+				return prefix + key
+			},
+			func(key string) string {
+				if !strings.HasPrefix(key, prefix) {
+					return ""
+				}
+				return strings.TrimPrefix(key, prefix)
+			},
+		),
+	}
+	if config.MaxKeys > 0 {
+		opts = append(opts, WithMaxKeys(config.MaxKeys))
+	}
+
+	return Wrap(store, opts...), nil
+}
+
+// Tenants returns the IDs of every tenant that has at least one key in the
+// shared Store, in sorted order.
+func (t *Tenancy) Tenants(ctx context.Context) ([]string, error) {
+	keys, err := t.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var tenants []string
+	for _, key := range keys {
+		parts := strings.SplitN(key, tenantSeparator, 2)
+		if len(parts) != 2 || !tenantID.MatchString(parts[0]) || seen[parts[0]] {
+			continue
+		}
+		seen[parts[0]] = true
+		tenants = append(tenants, parts[0])
+	}
+	sort.Strings(tenants)
+
+	return tenants, nil
+}
@@ -0,0 +1,301 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ttlEnvelope wraps a stored value along with the time at which it expires.
+type ttlEnvelope struct {
+	Expires time.Time       `json:"expires"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// expired returns true if the envelope has expired as of the given time.
+func (e ttlEnvelope) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+// Assert that TTLStore implements the Store interface.
+var _ Store = (*TTLStore)(nil)
+
+// TTLStore is a Store that wraps another Store, and expires keys once a
+// configured duration has passed since they were last set.
+type TTLStore struct {
+	store Store
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	callbacks []func(key string)
+}
+
+// NewTTLStore returns a TTLStore that wraps the given Store, and expires keys
+// once the given duration has passed since they were last set.
+//
+// Expired keys are hidden from Store.Get and Store.List immediately, but
+// remain in the wrapped Store until they are reaped by calling TTLStore.Reap,
+// or by running a janitor with TTLStore.Run.
+func NewTTLStore(store Store, ttl time.Duration) *TTLStore {
+	return &TTLStore{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// OnExpire registers a callback that is called with the name of every key
+// that is reaped after expiring. Callbacks are called synchronously by the
+// janitor, in the order that they were registered.
+func (s *TTLStore) OnExpire(callback func(key string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+}
+
+// Get reads the named entry from the wrapped Store and stores the contents
+// into the given value pointer.
+//
+// If the entry has expired, the ErrorKeyNotFound sentinel error is returned.
+func (s *TTLStore) Get(ctx context.Context, key string, value interface{}) error {
+	var envelope ttlEnvelope
+	if err := s.store.Get(ctx, key, &envelope); err != nil {
+		return err
+	}
+
+	if envelope.expired(time.Now()) {
+		return ErrorKeyNotFound
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(envelope.Value, value)
+}
+
+// Set writes the named entry and value into the wrapped Store, expiring after
+// the configured duration.
+func (s *TTLStore) Set(ctx context.Context, key string, value interface{}) error {
+	return s.SetWithTTL(ctx, key, value, s.ttl)
+}
+
+// SetWithTTL writes the named entry and value into the wrapped Store,
+// expiring after the given duration rather than the configured duration. A
+// duration of zero disables expiry for the entry.
+func (s *TTLStore) SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	envelope := ttlEnvelope{Value: data}
+	if ttl > 0 {
+		envelope.Expires = time.Now().Add(ttl).UTC()
+	}
+
+	return s.store.Set(ctx, key, envelope)
+}
+
+// Touch extends the expiry of the named entry by the configured duration from
+// now, without rewriting its value, such as for maintaining a heartbeat or
+// lease.
+//
+// If the entry does not exist, or has already expired, the ErrorKeyNotFound
+// sentinel error is returned.
+func (s *TTLStore) Touch(ctx context.Context, key string) error {
+	return s.TouchWithTTL(ctx, key, s.ttl)
+}
+
+// TouchWithTTL extends the expiry of the named entry by the given duration
+// from now, rather than the configured duration, without rewriting its value.
+// A duration of zero disables expiry for the entry.
+//
+// The expiry is modified atomically if the wrapped Store implements the
+// Updater interface, so that a concurrent change to the value is not lost.
+func (s *TTLStore) TouchWithTTL(ctx context.Context, key string, ttl time.Duration) error {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl).UTC()
+	}
+
+	return updateOrSet(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		if current == nil {
+			return nil, ErrorKeyNotFound
+		}
+
+		var envelope ttlEnvelope
+		if err := json.Unmarshal(current, &envelope); err != nil {
+			return nil, err
+		}
+
+		if envelope.expired(time.Now()) {
+			return nil, ErrorKeyNotFound
+		}
+
+		envelope.Expires = expires
+		return json.Marshal(envelope)
+	})
+}
+
+// List returns a list of all keys in the wrapped Store that have not expired.
+func (s *TTLStore) List(ctx context.Context) ([]string, error) {
+	live, _, err := s.partition(ctx)
+	return live, err
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s *TTLStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Describe returns a description of the wrapped Store.
+func (s *TTLStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s *TTLStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
+
+// Reap removes all expired entries from the wrapped Store, and calls any
+// registered expiry callbacks for each one.
+//
+// Each entry is re-checked before it is removed, atomically if the wrapped
+// Store implements the Updater interface, so that an entry which was
+// concurrently set or touched is neither removed nor reported as expired.
+func (s *TTLStore) Reap(ctx context.Context) error {
+	_, expired, err := s.partition(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	callbacks := append([]func(string){}, s.callbacks...)
+	s.mu.Unlock()
+
+	for _, key := range expired {
+		var reaped bool
+		err := updateOrSet(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+			// Record the outcome of the latest attempt, as the function is
+			// called again if the entry was concurrently modified.
+			reaped = false
+			if current == nil {
+				return nil, nil
+			}
+
+			var envelope ttlEnvelope
+			if err := json.Unmarshal(current, &envelope); err != nil {
+				return nil, err
+			}
+			if !envelope.expired(time.Now()) {
+				return current, nil
+			}
+
+			reaped = true
+			return nil, nil
+		})
+		if err != nil {
+			return err
+		}
+		if !reaped {
+			continue
+		}
+
+		for _, callback := range callbacks {
+			callback(key)
+		}
+	}
+
+	return nil
+}
+
+// Run acts as a janitor, calling TTLStore.Reap at the given interval until the
+// given context is done. Errors encountered while reaping are disregarded, as
+// reaping will be retried at the next interval.
+func (s *TTLStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.Reap(ctx)
+		}
+	}
+}
+
+// partition lists all keys in the wrapped Store, and separates them into those
+// that are live, and those that have expired.
+func (s *TTLStore) partition(ctx context.Context) ([]string, []string, error) {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values, err := GetMulti(ctx, s.store, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	var live, expired []string
+	for _, key := range keys {
+		data, found := values[key]
+		if !found {
+			// The key was removed in the interim.
+			continue
+		}
+
+		var envelope ttlEnvelope
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return nil, nil, err
+		}
+
+		if envelope.expired(now) {
+			expired = append(expired, key)
+		} else {
+			live = append(live, key)
+		}
+	}
+
+	return live, expired, nil
+}
+
+// Update atomically modifies the named entry in the wrapped Store. Expired
+// entries are treated as if they do not exist, and the updated entry expires
+// after the configured duration. If the given function returns the current
+// contents unchanged, then nothing is written, and the expiry is kept.
+func (s *TTLStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		var value json.RawMessage
+		if current != nil {
+			var envelope ttlEnvelope
+			if err := json.Unmarshal(current, &envelope); err != nil {
+				return nil, err
+			}
+			if !envelope.expired(time.Now()) {
+				value = envelope.Value
+			}
+		}
+
+		result, err := fn(value)
+		if err != nil || result == nil {
+			return nil, err
+		}
+		if unchanged(value, result) {
+			return current, nil
+		}
+
+		envelope := ttlEnvelope{Value: result}
+		if s.ttl > 0 {
+			envelope.Expires = time.Now().Add(s.ttl).UTC()
+		}
+		return json.Marshal(envelope)
+	})
+}
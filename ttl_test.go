@@ -0,0 +1,47 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTTLSetNXKeepsExpiry(t *testing.T) {
+	ctx := context.Background()
+	directory := t.TempDir()
+	store := NewTTLStore(NewFileStore(directory), time.Hour)
+
+	if err := store.SetWithTTL(ctx, "key", "first", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var before ttlEnvelope
+	if err := NewFileStore(directory).Get(ctx, "key", &before); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := SetNX(ctx, store, "key", "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Fatal("expected the existing entry to be kept")
+	}
+
+	// A losing SetNX must not extend the expiry of the existing entry.
+	var after ttlEnvelope
+	if err := NewFileStore(directory).Get(ctx, "key", &after); err != nil {
+		t.Fatal(err)
+	}
+	if !after.Expires.Equal(before.Expires) {
+		t.Fatalf("expected expiry %v, got %v", before.Expires, after.Expires)
+	}
+	if !jsonEqual(after.Value, json.RawMessage(`"first"`)) {
+		t.Fatalf("expected %s, got %s", `"first"`, after.Value)
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// UpdateFunc is a function that is given the current contents of a key, or
+// nil if the key does not exist, and returns the new contents for the key, or
+// nil if the key should be deleted.
+//
+// An UpdateFunc may be called multiple times for a single update, if the key
+// is concurrently modified by another writer, so it should not have side
+// effects. Returning an error aborts the update.
+type UpdateFunc func(current json.RawMessage) (json.RawMessage, error)
+
+// Updater represents a Store that is capable of atomically modifying the
+// contents of a single key, without the risk of overwriting a concurrent
+// modification made by another writer.
+type Updater interface {
+	// Update atomically replaces the contents of the given key with the
+	// result of calling the given function with its current contents.
+	Update(ctx context.Context, key string, fn UpdateFunc) error
+}
+
+// Update atomically replaces the contents of the given key with the result of
+// calling the given function with its current contents.
+//
+// If the Store does not implement the Updater interface, an error matching the
+// ErrorNotSupported sentinel error is returned.
+func Update(ctx context.Context, store Store, key string, fn UpdateFunc) error {
+	if updater, ok := store.(Updater); ok {
+		return updater.Update(ctx, key, fn)
+	}
+	return fmt.Errorf("%w: %s does not support atomic updates", ErrorNotSupported, Describe(store).Backend)
+}
+
+// unchanged returns true if the given update result is identical to the given
+// current contents.
+func unchanged(current, result json.RawMessage) bool {
+	return (current == nil) == (result == nil) && bytes.Equal(current, result)
+}
+
+// Assert that the various stores implement the Updater interface.
+var (
+	_ Updater = annotationStore{}
+	_ Updater = configMapStore{}
+	_ Updater = fileStore{}
+	_ Updater = secretStore{}
+)
+
+// Update atomically modifies the named annotation on the backing resource,
+// using the resource version of the backing resource as a precondition.
+//
+// If the backing resource does not exist, an error matching the
+// ErrorResourceMissing sentinel error is returned.
+func (c annotationStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	for {
+		// Use the Kuberneties API to get the backing resource.
+		resource, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+		if err != nil {
+			// The backing resource is not created on-demand, so report that
+			// it does not exist.
+			if isResourceMissingError(err) {
+				return resourceMissingError{err: err}
+			}
+			// Some other kind of error was encountered.
+			return err
+		}
+		existing := resource.GetAnnotations()
+
+		var current json.RawMessage
+		if data, found := readAnnotation(existing, key); found {
+			current = json.RawMessage(data)
+		}
+
+		result, err := fn(current)
+		if err != nil {
+			return err
+		}
+		if unchanged(current, result) {
+			return nil
+		}
+
+		// Determine the annotation changes needed to store the result.
+		annotations := make(map[string]interface{})
+		if result != nil {
+			c.setChanges(existing, key, result, annotations)
+			if err := checkAnnotationSize(existing, annotations); err != nil {
+				return err
+			}
+		} else {
+			for _, name := range keyAnnotations(existing, key) {
+				annotations[name] = nil
+			}
+		}
+
+		// Construct a merge patch that only applies if the backing resource
+		// has not been modified since it was read.
+		patch := annotationPatch{
+			Metadata: metadataPatch{
+				Annotations:     annotations,
+				ResourceVersion: resource.GetResourceVersion(),
+			},
+		}
+
+		// Convert the patch to JSON.
+		payload, err := json.Marshal(patch)
+		if err != nil {
+			return err
+		}
+
+		// Use the Kuberneties API to patch the backing resource.
+		_, err = c.client.Patch(ctx, c.name, types.MergePatchType, payload, patchOptions(ctx))
+		switch {
+		case isConflictError(err):
+			// The backing resource was modified concurrently, so try again.
+			continue
+		case isResourceMissingError(err):
+			// The backing resource was deleted in the interim.
+			return resourceMissingError{err: err}
+		default:
+			return err
+		}
+	}
+}
+
+// Update atomically modifies the named entry in the backing ConfigMap, using
+// the resource version of the backing ConfigMap as a precondition.
+//
+// If the backing ConfigMap does not exist, it is created on-demand. If the
+// backing ConfigMap is left empty, it is then deleted.
+func (c configMapStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	for {
+		// Use the Kuberneties API to get the backing ConfigMap.
+		configMap, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+		if err != nil {
+			if !isResourceMissingError(err) {
+				// Some other kind of error was encountered.
+				return err
+			}
+			configMap = nil
+		}
+
+		var current json.RawMessage
+		if configMap != nil {
+			if data, found := configMap.Data[key]; found {
+				current = json.RawMessage(data)
+			}
+		}
+
+		result, err := fn(current)
+		if err != nil {
+			return err
+		}
+		if unchanged(current, result) {
+			return nil
+		}
+
+		switch {
+		case configMap == nil:
+			// Create the backing ConfigMap on-demand, which fails if it has
+			// been created concurrently.
+			_, err = c.client.Create(ctx, &apiv1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        c.name,
+					Labels:      managedLabels(),
+					Annotations: nameAnnotations(c.original),
+				},
+				Data: map[string]string{
+					key: string(result),
+				},
+			}, createOptions(ctx))
+		default:
+			// Update the backing ConfigMap, which fails if it has been
+			// modified concurrently.
+			configMap = configMap.DeepCopy()
+			if result == nil {
+				delete(configMap.Data, key)
+			} else {
+				if configMap.Data == nil {
+					configMap.Data = make(map[string]string)
+				}
+				configMap.Data[key] = string(result)
+			}
+			configMap, err = c.client.Update(ctx, configMap, updateOptions(ctx))
+		}
+		if isConflictError(err) {
+			// The backing ConfigMap was modified concurrently, so try again.
+			continue
+		}
+		if err != nil {
+			// Some other kind of error was encountered.
+			return err
+		}
+
+		// Is the backing ConfigMap now empty?
+		if configMap != nil && len(configMap.Data) == 0 {
+			// Delete the backing ConfigMap in order to clean up after
+			// ourselves, but only if it has not been modified in the interim.
+			// Intentionally ignore any errors, as this is non-essential.
+			_ = c.client.Delete(ctx, c.name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &configMap.ResourceVersion},
+			})
+		}
+
+		return nil
+	}
+}
+
+// Update atomically modifies the named entry in the backing Secret, using the
+// resource version of the backing Secret as a precondition.
+//
+// If the backing Secret does not exist, it is created on-demand. If the
+// backing Secret is left empty, it is then deleted.
+func (c secretStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	for {
+		// Use the Kuberneties API to get the backing Secret.
+		secret, err := c.client.Get(ctx, c.name, metav1.GetOptions{})
+		if err != nil {
+			if !isResourceMissingError(err) {
+				// Some other kind of error was encountered.
+				return err
+			}
+			secret = nil
+		}
+
+		var current json.RawMessage
+		if secret != nil {
+			if data, found := secret.Data[key]; found {
+				current = json.RawMessage(data)
+			}
+		}
+
+		result, err := fn(current)
+		if err != nil {
+			return err
+		}
+		if unchanged(current, result) {
+			return nil
+		}
+
+		switch {
+		case secret == nil:
+			// Create the backing Secret on-demand, which fails if it has been
+			// created concurrently.
+			_, err = c.client.Create(ctx, &apiv1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        c.name,
+					Labels:      managedLabels(),
+					Annotations: nameAnnotations(c.original),
+				},
+				Data: map[string][]byte{
+					key: result,
+				},
+			}, createOptions(ctx))
+		default:
+			// Update the backing Secret, which fails if it has been modified
+			// concurrently.
+			secret = secret.DeepCopy()
+			if result == nil {
+				delete(secret.Data, key)
+			} else {
+				if secret.Data == nil {
+					secret.Data = make(map[string][]byte)
+				}
+				secret.Data[key] = result
+			}
+			secret, err = c.client.Update(ctx, secret, updateOptions(ctx))
+		}
+		if isConflictError(err) {
+			// The backing Secret was modified concurrently, so try again.
+			continue
+		}
+		if err != nil {
+			// Some other kind of error was encountered.
+			return err
+		}
+
+		// Is the backing Secret now empty?
+		if secret != nil && len(secret.Data) == 0 {
+			// Delete the backing Secret in order to clean up after ourselves,
+			// but only if it has not been modified in the interim.
+			// Intentionally ignore any errors, as this is non-essential.
+			_ = c.client.Delete(ctx, c.name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &secret.ResourceVersion},
+			})
+		}
+
+		return nil
+	}
+}
+
+// fileLocks holds a mutex for each backing directory, which serializes
+// modifications made to that directory by file Stores within this process.
+var fileLocks sync.Map
+
+// lock acquires the mutex for the backing directory, and returns a function
+// for releasing it.
+func (s fileStore) lock() func() {
+	mu, _ := fileLocks.LoadOrStore(filepath.Clean(s.directory), &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// Update atomically modifies the named file in the backing directory.
+//
+// Modifications are only serialized against other file Stores within the same
+// process, so the backing directory must not be shared between processes if
+// atomicity is required.
+func (s fileStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	defer s.lock()()
+
+	// Determine the name of the backing file.
+	filename := filepath.Join(s.directory, key)
+
+	var current json.RawMessage
+	data, err := readFile(ctx, filename)
+	switch {
+	case err == nil:
+		current = data
+	case !os.IsNotExist(err):
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	result, err := fn(current)
+	if err != nil {
+		return err
+	}
+	if unchanged(current, result) {
+		return nil
+	}
+
+	if result == nil {
+		return s.delete(ctx, key)
+	}
+	return s.set(ctx, key, result)
+}
@@ -0,0 +1,86 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+)
+
+// maxObjectSize is the maximum size of a single ConfigMap or Secret, as
+// enforced by the Kubernetes API.
+const maxObjectSize = 1024 * 1024
+
+// UsageReport describes the space used by a Store.
+type UsageReport struct {
+	// Keys is the number of keys in the Store.
+	Keys int `json:"keys"`
+
+	// Bytes is the combined size of all keys and values in the Store.
+	Bytes int `json:"bytes"`
+
+	// Sizes holds the combined size of each key and its value.
+	Sizes map[string]int `json:"sizes"`
+
+	// Limit is the maximum combined size of all keys and values permitted by
+	// the backing medium, or zero if the backing medium has no known limit.
+	Limit int `json:"limit,omitempty"`
+
+	// Headroom is the number of bytes remaining before the limit is reached,
+	// or zero if the backing medium has no known limit.
+	Headroom int `json:"headroom"`
+}
+
+// Usage reports the space used by the given Store, along with the remaining
+// headroom against the size limit of the backing medium, so that applications
+// can alert before a Store reaches the limit and writes begin to fail.
+//
+// The limit is 1MiB for Stores backed by a ConfigMap or Secret, and 256KiB for
+// Stores backed by annotations. The size of each entry is measured as read
+// through the given Store, so values that are stored with additional overhead,
+// such as encryption or signatures, use slightly more space than reported.
+// Similarly, the metadata of the backing object also counts against the limit.
+func Usage(ctx context.Context, store Store) (UsageReport, error) {
+	keys, err := store.List(ctx)
+	if err != nil {
+		return UsageReport{}, err
+	}
+
+	values, err := GetMulti(ctx, store, keys)
+	if err != nil {
+		return UsageReport{}, err
+	}
+
+	report := UsageReport{
+		Keys:  len(values),
+		Sizes: make(map[string]int, len(values)),
+		Limit: usageLimit(Describe(store)),
+	}
+	for key, value := range values {
+		size := len(key) + len(value)
+		report.Sizes[key] = size
+		report.Bytes += size
+	}
+
+	if report.Limit > 0 {
+		if report.Headroom = report.Limit - report.Bytes; report.Headroom < 0 {
+			report.Headroom = 0
+		}
+	}
+
+	return report, nil
+}
+
+// usageLimit returns the size limit of the medium with the given description,
+// or zero if the limit is unknown.
+func usageLimit(description Description) int {
+	switch description.Backend {
+	case "configmap", "secret":
+		return maxObjectSize
+	case "annotation":
+		return maxAnnotationSize
+	default:
+		return 0
+	}
+}
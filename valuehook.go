@@ -0,0 +1,123 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Assert that valueHookStore implements the Store interface.
+var _ Store = valueHookStore{}
+
+type valueHookStore struct {
+	store     Store
+	beforeSet func(key string, value interface{}) (interface{}, error)
+	afterGet  func(key string, value interface{}) error
+}
+
+// Get reads the named entry from the wrapped Store, and then calls the
+// afterGet hook with the resulting value.
+func (s valueHookStore) Get(ctx context.Context, key string, value interface{}) error {
+	if err := s.store.Get(ctx, key, value); err != nil {
+		return err
+	}
+
+	if s.afterGet == nil {
+		return nil
+	}
+	return s.afterGet(key, value)
+}
+
+// Set calls the beforeSet hook with the given value, and then writes the
+// resulting value into the wrapped Store.
+func (s valueHookStore) Set(ctx context.Context, key string, value interface{}) error {
+	if s.beforeSet != nil {
+		var err error
+		if value, err = s.beforeSet(key, value); err != nil {
+			return err
+		}
+	}
+
+	return s.store.Set(ctx, key, value)
+}
+
+// List returns a list of all keys in the wrapped Store.
+func (s valueHookStore) List(ctx context.Context) ([]string, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes the named entry from the wrapped Store.
+func (s valueHookStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Update atomically modifies the named entry in the wrapped Store. The current
+// contents are decoded into an interface{} value, with numbers decoded as a
+// json.Number so that they are not rounded, and passed through the afterGet
+// hook before the given function is called. Unlike Store.Get, the afterGet hook
+// is therefore given a *interface{} rather than the caller's typed pointer.
+// The result of the function is likewise decoded and passed through the
+// beforeSet hook before it is written.
+//
+// Neither hook is called for a missing entry or a deletion, and if the given
+// function returns the contents that it was given unchanged, then nothing is
+// written.
+func (s valueHookStore) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	return Update(ctx, s.store, key, func(current json.RawMessage) (json.RawMessage, error) {
+		hooked := current
+		if current != nil && s.afterGet != nil {
+			var value interface{}
+			if err := unmarshalNumber(current, &value); err != nil {
+				return nil, err
+			}
+			if err := s.afterGet(key, &value); err != nil {
+				return nil, err
+			}
+
+			var err error
+			if hooked, err = json.Marshal(value); err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := fn(hooked)
+		if err != nil || result == nil {
+			return result, err
+		}
+		if unchanged(hooked, result) {
+			return current, nil
+		}
+		if s.beforeSet == nil {
+			return result, nil
+		}
+
+		var value interface{}
+		if err := unmarshalNumber(result, &value); err != nil {
+			return nil, err
+		}
+		value, err = s.beforeSet(key, value)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(value)
+	})
+}
+
+// Describe returns a description of the wrapped Store.
+func (s valueHookStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// resourceVersion returns the resource version of the object backing the
+// wrapped Store.
+func (s valueHookStore) resourceVersion(ctx context.Context) (string, error) {
+	return resourceVersionOf(ctx, s.store)
+}
+
+// Subscribe watches the wrapped Store for changes.
+func (s valueHookStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	return Subscribe(ctx, s.store)
+}
@@ -0,0 +1,115 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValueHookSetNX(t *testing.T) {
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	upper := func(key string, value interface{}) (interface{}, error) {
+		if s, ok := value.(string); ok {
+			return strings.ToUpper(s), nil
+		}
+		return value, nil
+	}
+	store := NewFileStore(directory, WithValueHooks(upper, nil))
+
+	created, err := SetNX(ctx, store, "key", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Fatal("expected the entry to be created")
+	}
+
+	created, err = SetNX(ctx, store, "key", "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Fatal("expected the existing entry to be kept")
+	}
+
+	// The beforeSet hook applies to values written by SetNX.
+	var value string
+	if err := NewFileStore(directory).Get(ctx, "key", &value); err != nil {
+		t.Fatal(err)
+	}
+	if value != "FIRST" {
+		t.Fatalf("expected %q, got %q", "FIRST", value)
+	}
+}
+
+func TestValueHookUpdatePreservesNumbers(t *testing.T) {
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	identity := func(key string, value interface{}) (interface{}, error) {
+		return value, nil
+	}
+	inspect := func(key string, value interface{}) error {
+		return nil
+	}
+	store := NewFileStore(directory, WithValueHooks(identity, inspect))
+
+	// A number that cannot be represented exactly as a float64.
+	large := json.RawMessage(`{"id":9007199254740993}`)
+	if err := NewFileStore(directory).Set(ctx, "key", large); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SetNX(ctx, store, "key", "other"); err != nil {
+		t.Fatal(err)
+	}
+	err := Update(ctx, store, "key", func(current json.RawMessage) (json.RawMessage, error) {
+		return append(json.RawMessage(nil), current...), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual json.RawMessage
+	if err := NewFileStore(directory).Get(ctx, "key", &actual); err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != string(large) {
+		t.Fatalf("expected %s, got %s", large, actual)
+	}
+}
+
+func TestValueHookUpdateBeforeSetPreservesNumbers(t *testing.T) {
+	ctx := context.Background()
+	directory := t.TempDir()
+
+	identity := func(key string, value interface{}) (interface{}, error) {
+		return value, nil
+	}
+	store := NewFileStore(directory, WithValueHooks(identity, nil))
+
+	// The beforeSet hook is called with the result, which must not lose
+	// precision.
+	expected := json.RawMessage(`{"id":9007199254740993}`)
+	err := Update(ctx, store, "key", func(json.RawMessage) (json.RawMessage, error) {
+		return expected, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual json.RawMessage
+	if err := NewFileStore(directory).Get(ctx, "key", &actual); err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != string(expected) {
+		t.Fatalf("expected %s, got %s", expected, actual)
+	}
+}
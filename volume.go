@@ -0,0 +1,238 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// volumeDataLink is the symlink within a mounted ConfigMap or Secret volume
+// which kubelet atomically swaps to point at a new directory whenever the
+// projected contents are refreshed.
+const volumeDataLink = "..data"
+
+// Assert that volumeStore implements the Store and Subscriber interfaces.
+var (
+	_ Store      = volumeStore{}
+	_ Subscriber = volumeStore{}
+)
+
+type volumeStore struct {
+	directory string
+	interval  time.Duration
+}
+
+// NewVolumeStore returns a read-only Store backed by a ConfigMap or Secret
+// that is mounted as a volume in the given directory. Each key is the name of
+// a file in the volume, and each value is the contents of the file decoded as
+// JSON.
+//
+// This Store does not depend on access to the Kubernetes API. Kubelet
+// periodically refreshes the contents of the volume, and calls to
+// Store.Subscribe emit an event for every key that changed, as detected by
+// checking the volume at the given interval. Volumes mounted using a subPath
+// are never refreshed by kubelet, and so can not be used.
+//
+// Calls to Store.Set and Store.Delete return an error matching the
+// ErrorNotSupported sentinel error.
+func NewVolumeStore(directory string, interval time.Duration, opts ...Option) Store {
+	return wrap(&volumeStore{
+		directory: directory,
+		interval:  interval,
+	}, newOptions(opts))
+}
+
+// Get reads the named file from the mounted volume and stores the contents
+// into the given value pointer.
+//
+// If the file does not exist, the ErrorKeyNotFound sentinel error is returned.
+func (s volumeStore) Get(ctx context.Context, key string, value interface{}) error {
+	// Reject keys that would refer to kubelet's internal files and
+	// directories, or to files outside of the volume.
+	if strings.HasPrefix(key, ".") || strings.ContainsRune(key, filepath.Separator) {
+		return ErrorKeyNotFound
+	}
+
+	data, err := readFile(ctx, filepath.Join(s.directory, key))
+	if err != nil {
+		// If the file does not exist, then return the not found sentinel
+		// error.
+		if os.IsNotExist(err) {
+			return ErrorKeyNotFound
+		}
+		// Some other kind of error was encountered.
+		return err
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(data, value)
+}
+
+// Set always returns an error, as a mounted volume is read-only.
+func (s volumeStore) Set(ctx context.Context, key string, value interface{}) error {
+	return fmt.Errorf("%w: mounted volumes are read-only", ErrorNotSupported)
+}
+
+// List returns the names of all files in the mounted volume, excluding
+// kubelet's internal files and directories.
+func (s volumeStore) List(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(s.directory)
+	if err != nil {
+		// If the volume does not exist, then the keys also no not exist, so
+		// return an empty (nil) slice.
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		// Some other kind of error was encountered.
+		return nil, err
+	}
+
+	var keys []string
+	for _, info := range infos {
+		// Kubelet's internal files and directories, such as ..data, are all
+		// prefixed with a dot.
+		if !strings.HasPrefix(info.Name(), ".") {
+			keys = append(keys, info.Name())
+		}
+	}
+
+	return keys, nil
+}
+
+// Delete always returns an error, as a mounted volume is read-only.
+func (s volumeStore) Delete(ctx context.Context, key string) error {
+	return fmt.Errorf("%w: mounted volumes are read-only", ErrorNotSupported)
+}
+
+// Describe returns a description of the mounted volume.
+func (s volumeStore) Describe() Description {
+	return Description{
+		Backend: "volume",
+		Name:    s.directory,
+	}
+}
+
+// Subscribe checks the mounted volume at the configured interval, and emits an
+// event for every key that changed whenever kubelet refreshes the volume.
+func (s volumeStore) Subscribe(ctx context.Context) (<-chan Event, error) {
+	revision := s.revision()
+	snapshot, err := s.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// Kubelet refreshes the volume by swapping the ..data symlink,
+			// so the contents only need to be read when it has changed.
+			current := s.revision()
+			if current == revision {
+				continue
+			}
+
+			updated, err := s.snapshot(ctx)
+			if err != nil {
+				// Try again at the next interval.
+				continue
+			}
+
+			for _, event := range snapshotEvents(snapshot, updated) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			revision, snapshot = current, updated
+		}
+	}()
+
+	return events, nil
+}
+
+// revision returns the target of the ..data symlink, which identifies the
+// current contents of the mounted volume. An empty string is returned if the
+// volume is not managed by kubelet.
+func (s volumeStore) revision() string {
+	target, _ := os.Readlink(filepath.Join(s.directory, volumeDataLink))
+	return target
+}
+
+// snapshot reads the contents of every file in the mounted volume.
+func (s volumeStore) snapshot(ctx context.Context) (map[string][]byte, error) {
+	keys, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		data, err := readFile(ctx, filepath.Join(s.directory, key))
+		if err != nil {
+			// The file may have been removed in the interim.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		snapshot[key] = data
+	}
+
+	return snapshot, nil
+}
+
+// snapshotEvents returns events for every key whose contents differ between the
+// given old and new snapshots, in a stable order.
+func snapshotEvents(oldSnapshot, newSnapshot map[string][]byte) []Event {
+	var events []Event
+
+	// Find all keys that were created or changed.
+	for key, newData := range newSnapshot {
+		if oldData, found := oldSnapshot[key]; !found || !bytes.Equal(oldData, newData) {
+			events = append(events, Event{Type: EventSet, Key: key})
+		}
+	}
+
+	// Find all keys that were removed.
+	for key := range oldSnapshot {
+		if _, found := newSnapshot[key]; !found {
+			events = append(events, Event{Type: EventDelete, Key: key})
+		}
+	}
+
+	// Emit events in a stable order.
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+
+	return events
+}
@@ -0,0 +1,51 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"fmt"
+)
+
+// EventType describes the kind of change that was made to a key.
+type EventType string
+
+const (
+	// EventSet indicates that a key was created or had its value changed.
+	EventSet EventType = "set"
+
+	// EventDelete indicates that a key was removed.
+	EventDelete EventType = "delete"
+)
+
+// Event describes a change that was made to a single key.
+type Event struct {
+	// Type is the kind of change that was made.
+	Type EventType
+
+	// Key is the name of the key that was changed.
+	Key string
+}
+
+// Subscriber represents a Store that is capable of notifying callers about
+// changes made to its keys, including changes made by other processes.
+type Subscriber interface {
+	// Subscribe returns a channel which receives an event for every change
+	// made to a key. The channel is closed once the given context is done.
+	Subscribe(ctx context.Context) (<-chan Event, error)
+}
+
+// Subscribe returns a channel which receives an event for every change made
+// to a key in the given Store. The channel is closed once the given context is
+// done.
+//
+// If the Store does not implement the Subscriber interface, an error matching
+// the ErrorNotSupported sentinel error is returned.
+func Subscribe(ctx context.Context, store Store) (<-chan Event, error) {
+	if subscriber, ok := store.(Subscriber); ok {
+		return subscriber.Subscribe(ctx)
+	}
+	return nil, fmt.Errorf("%w: %s does not support subscriptions", ErrorNotSupported, Describe(store).Backend)
+}
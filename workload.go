@@ -0,0 +1,124 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// NewDeploymentAnnotationStore returns a Store backed by the annotations on
+// the named Deployment in the current pod's namespace.
+func NewDeploymentAnnotationStore(name string, opts ...Option) (Store, error) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	return newWellKnownAnnotationStore(gvr, true, name, opts)
+}
+
+// NewStatefulSetAnnotationStore returns a Store backed by the annotations on
+// the named StatefulSet in the current pod's namespace.
+func NewStatefulSetAnnotationStore(name string, opts ...Option) (Store, error) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}
+	return newWellKnownAnnotationStore(gvr, true, name, opts)
+}
+
+// NewDaemonSetAnnotationStore returns a Store backed by the annotations on the
+// named DaemonSet in the current pod's namespace.
+func NewDaemonSetAnnotationStore(name string, opts ...Option) (Store, error) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}
+	return newWellKnownAnnotationStore(gvr, true, name, opts)
+}
+
+// NewCronJobAnnotationStore returns a Store backed by the annotations on the
+// named CronJob in the current pod's namespace.
+//
+// CronJobs are served as batch/v1 by Kubernetes 1.21 and later, and only as
+// batch/v1beta1 by earlier versions, so API discovery is used to select the
+// version that is served by the cluster.
+func NewCronJobAnnotationStore(name string, opts ...Option) (Store, error) {
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Map the CronJob kind to a resource using API discovery, preferring the
+	// stable version.
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: "batch", Kind: "CronJob"}, "v1", "v1beta1")
+	if err != nil {
+		return nil, err
+	}
+
+	// Lookup the current pod's namespace.
+	namespace, err := inClusterNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	return newAnnotationStore(config, mapping.Resource, namespace, name, opts)
+}
+
+// NewPodAnnotationStore returns a Store backed by the annotations on the named
+// Pod in the current pod's namespace.
+func NewPodAnnotationStore(name string, opts ...Option) (Store, error) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	return newWellKnownAnnotationStore(gvr, true, name, opts)
+}
+
+// NewServiceAnnotationStore returns a Store backed by the annotations on the
+// named Service in the current pod's namespace.
+func NewServiceAnnotationStore(name string, opts ...Option) (Store, error) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "services"}
+	return newWellKnownAnnotationStore(gvr, true, name, opts)
+}
+
+// NewNodeAnnotationStore returns a Store backed by the annotations on the named
+// Node.
+func NewNodeAnnotationStore(nodeName string, opts ...Option) (Store, error) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+	return newWellKnownAnnotationStore(gvr, false, nodeName, opts)
+}
+
+// NewNamespaceAnnotationStore returns a Store backed by the annotations on the
+// current pod's Namespace.
+func NewNamespaceAnnotationStore(opts ...Option) (Store, error) {
+	// Lookup the current pod's namespace.
+	namespace, err := inClusterNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	return newWellKnownAnnotationStore(gvr, false, namespace, opts)
+}
+
+// newWellKnownAnnotationStore returns a Store backed by the annotations on the
+// named resource. Namespaced resources are assumed to reside in the current
+// pod's namespace.
+func newWellKnownAnnotationStore(gvr schema.GroupVersionResource, namespaced bool, name string, opts []Option) (Store, error) {
+	// Lookup the current pod's service account details.
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Lookup the current pod's namespace, if needed.
+	var namespace string
+	if namespaced {
+		namespace, err = inClusterNamespace()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newAnnotationStore(config, gvr, namespace, name, opts)
+}
@@ -0,0 +1,50 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+// Wrap returns a Store that wraps the given Store with any of the behaviors
+// configured by the given options that apply to every kind of Store, such as
+// WithKeyTransform or WithMaxKeys. Options that only apply to a specific kind of Store are
+// disregarded.
+//
+// Stores returned by this package's constructors are already wrapped in this
+// way, so Wrap is only needed for other implementations of Store.
+func Wrap(store Store, opts ...Option) Store {
+	return wrap(store, newOptions(opts))
+}
+
+// wrap returns a Store that wraps the given Store with any of the behaviors
+// configured by the given options that apply to every kind of Store.
+func wrap(store Store, o options) Store {
+	base := store
+	if o.hmacKey != nil {
+		store = &hmacStore{store: store, key: o.hmacKey}
+	}
+	if o.snapshotEvery > 0 {
+		store = &deltaStore{store: store, snapshotEvery: o.snapshotEvery}
+	}
+	if o.canonicalJSON {
+		store = &canonicalStore{store: store}
+	}
+	if o.useNumber || o.disallowUnknownFields {
+		store = &decodeStore{store: store, useNumber: o.useNumber, disallowUnknownFields: o.disallowUnknownFields}
+	}
+	if o.beforeSet != nil || o.afterGet != nil {
+		store = &valueHookStore{store: store, beforeSet: o.beforeSet, afterGet: o.afterGet}
+	}
+	if o.keyTransform != nil {
+		store = &keyTransformStore{store: store, transform: o.keyTransform, inverse: o.keyInverse}
+	}
+	if o.maxKeys > 0 {
+		store = &quotaStore{store: store, maxKeys: o.maxKeys}
+	}
+	if o.auditEndpoint != "" {
+		store = &auditStore{store: store, base: base, endpoint: o.auditEndpoint, client: o.auditClient}
+	}
+	if o.sortedList {
+		store = &sortedStore{store: store}
+	}
+	return store
+}
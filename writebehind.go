@@ -0,0 +1,234 @@
+// Copyright Josh Komoroske. All rights reserved.
+// Use of this source code is governed by the MIT license,
+// a copy of which can be found in the LICENSE.txt file.
+
+package kubestore
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// pendingWrite is a change that has yet to be persisted to the wrapped Store.
+type pendingWrite struct {
+	data       json.RawMessage
+	deleted    bool
+	generation uint64
+	modified   time.Time
+}
+
+// Assert that WriteBehindStore implements the Store interface.
+var _ Store = (*WriteBehindStore)(nil)
+
+// WriteBehindStore is a Store that wraps another Store, and persists changes
+// to it asynchronously.
+type WriteBehindStore struct {
+	store Store
+	retry time.Duration
+
+	// persistMu serializes persisting changes to the wrapped Store.
+	persistMu sync.Mutex
+
+	mu         sync.Mutex
+	pending    map[string]pendingWrite
+	generation uint64
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWriteBehindStore returns a WriteBehindStore that wraps the given Store.
+//
+// Calls to Store.Set and Store.Delete return immediately after recording the
+// change in memory, and a background worker persists the change to the
+// wrapped Store, retrying at the given interval if persisting fails. Reads
+// observe any changes that have yet to be persisted.
+//
+// Changes that have yet to be persisted are lost if the process exits without
+// calling WriteBehindStore.Close, so this Store should only be used when losing
+// the most recent changes is acceptable.
+func NewWriteBehindStore(store Store, retry time.Duration) *WriteBehindStore {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &WriteBehindStore{
+		store:   store,
+		retry:   retry,
+		pending: make(map[string]pendingWrite),
+		wake:    make(chan struct{}, 1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go s.run(ctx)
+
+	return s
+}
+
+// Get reads the named entry, preferring any change that has yet to be
+// persisted, and stores the contents into the given value pointer.
+func (s *WriteBehindStore) Get(ctx context.Context, key string, value interface{}) error {
+	s.mu.Lock()
+	write, found := s.pending[key]
+	s.mu.Unlock()
+
+	if !found {
+		return s.store.Get(ctx, key, value)
+	}
+	if write.deleted {
+		return ErrorKeyNotFound
+	}
+
+	// Unmarshal the JSON data into the given value pointer.
+	return json.Unmarshal(write.data, value)
+}
+
+// Set records the named entry and value in memory, to be persisted to the
+// wrapped Store asynchronously.
+func (s *WriteBehindStore) Set(_ context.Context, key string, value interface{}) error {
+	// Marshal the the given value as JSON.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.record(key, pendingWrite{data: data})
+	return nil
+}
+
+// List returns a list of all keys in the wrapped Store, including any changes
+// that have yet to be persisted.
+func (s *WriteBehindStore) List(ctx context.Context) ([]string, error) {
+	keys, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Disregard keys that are pending deletion.
+	listed := make(map[string]bool, len(keys))
+	filtered := keys[:0]
+	for _, key := range keys {
+		listed[key] = true
+		if write, found := s.pending[key]; found && write.deleted {
+			continue
+		}
+		filtered = append(filtered, key)
+	}
+
+	// Include keys that are pending creation.
+	for key, write := range s.pending {
+		if !write.deleted && !listed[key] {
+			filtered = append(filtered, key)
+		}
+	}
+
+	return filtered, nil
+}
+
+// Delete records the removal of the named entry in memory, to be persisted to
+// the wrapped Store asynchronously.
+func (s *WriteBehindStore) Delete(_ context.Context, key string) error {
+	s.record(key, pendingWrite{deleted: true})
+	return nil
+}
+
+// Describe returns a description of the wrapped Store.
+func (s *WriteBehindStore) Describe() Description {
+	return Describe(s.store)
+}
+
+// Flush synchronously persists all pending changes to the wrapped Store.
+func (s *WriteBehindStore) Flush(ctx context.Context) error {
+	return s.persist(ctx)
+}
+
+// Close stops the background worker, and persists all pending changes to the
+// wrapped Store.
+func (s *WriteBehindStore) Close(ctx context.Context) error {
+	s.cancel()
+	<-s.done
+	return s.Flush(ctx)
+}
+
+// record stores the given change, and wakes the background worker.
+func (s *WriteBehindStore) record(key string, write pendingWrite) {
+	s.mu.Lock()
+	s.generation++
+	write.generation = s.generation
+	s.pending[key] = write
+	s.mu.Unlock()
+
+	// Wake the background worker, if it is not already awake.
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run persists changes whenever woken, until the given context is done.
+func (s *WriteBehindStore) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+
+		// Keep retrying until all pending changes have been persisted.
+		for s.persist(ctx) != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.retry):
+			}
+		}
+	}
+}
+
+// persist writes all pending changes to the wrapped Store. Changes that are
+// persisted successfully are forgotten, unless they have since been replaced
+// by a newer change.
+func (s *WriteBehindStore) persist(ctx context.Context) error {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	// Take a snapshot of the pending changes.
+	s.mu.Lock()
+	snapshot := make(map[string]pendingWrite, len(s.pending))
+	for key, write := range s.pending {
+		snapshot[key] = write
+	}
+	s.mu.Unlock()
+
+	for key, write := range snapshot {
+		var err error
+		if write.deleted {
+			err = s.store.Delete(ctx, key)
+			// The key may have never been persisted in the first place.
+			if isKeyNotFound(err) {
+				err = nil
+			}
+		} else {
+			err = s.store.Set(ctx, key, write.data)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Forget the change, unless it was replaced in the interim.
+		s.mu.Lock()
+		if current, found := s.pending[key]; found && current.generation == write.generation {
+			delete(s.pending, key)
+		}
+		s.mu.Unlock()
+	}
+
+	return nil
+}